@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// --encrypt-to 與 --decrypt 使用 age（age-encryption.org/v1）格式：下載時以 age.Encrypt 加密後才寫入磁碟，
+// 產生的檔案可以用 age -d 解開；age 加密的本機檔案也可以解密後上傳。
+
+// encryptedName 回傳 --encrypt-to 時實際寫入的檔名：加上 .age。
+func encryptedName(local string) string {
+	if strings.HasSuffix(local, ".age") {
+		return local
+	}
+	return local + ".age"
+}
+
+// loadAgeRecipients 解析 --encrypt-to：age1... 收件者，或每行一個收件者的檔案（# 開頭為註解）。
+func loadAgeRecipients(arg string) ([]age.Recipient, error) {
+	var r io.Reader = strings.NewReader(arg)
+	if !strings.HasPrefix(arg, "age1") {
+		f, err := os.Open(arg)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	keys, err := age.ParseRecipients(r)
+	if err != nil {
+		return nil, fmt.Errorf("無效的 age 收件者 %q: %v", arg, err)
+	}
+	return keys, nil
+}
+
+// loadAgeIdentities 讀取 age-keygen 產生的身分檔（AGE-SECRET-KEY-1...）。
+func loadAgeIdentities(path string) ([]age.Identity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	keys, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: 無效的 age 身分: %v", path, err)
+	}
+	return keys, nil
+}
+
+// ageDecryptFile 以 identities 解開 age 加密的檔案 f（大小為 size），回傳解密後的內容與原始大小。
+// 原始大小由加密檔的大小算出，不必先解密一次；最後一段在返回前就先驗證，截斷的檔案在上傳前即被拒絕。
+func ageDecryptFile(f *os.File, size int64, identities []age.Identity) (io.Reader, int64, error) {
+	plain, n, err := age.DecryptReaderAt(f, size, identities...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("age 解密失敗: %w", err)
+	}
+	return io.NewSectionReader(plain, 0, n), n, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+)
+
+// ageFixture 產生一個身分，寫出身分檔與收件者檔（含註解與空行），回傳身分與兩個檔案的路徑。
+func ageFixture(t *testing.T) (id *age.X25519Identity, idFile, recipientFile string) {
+	t.Helper()
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	idFile = filepath.Join(dir, "key.txt")
+	recipientFile = filepath.Join(dir, "recipients.txt")
+	os.WriteFile(idFile, []byte("# created: now\n"+id.String()+"\n"), 0o600)
+	os.WriteFile(recipientFile, []byte("# team\n\n"+id.Recipient().String()+"\n"), 0o644)
+	return id, idFile, recipientFile
+}
+
+// encryptFile 以 recipients 加密 data 寫到 dir 中的檔案，回傳路徑。
+func encryptFile(t *testing.T, dir string, data []byte, recipients []age.Recipient) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(dir, "data.age")
+	os.WriteFile(p, buf.Bytes(), 0o644)
+	return p
+}
+
+func decryptFile(t *testing.T, path string, ids []age.Identity) ([]byte, error) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, _ := f.Stat()
+	r, n, err := ageDecryptFile(f, info.Size(), ids)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err == nil && int64(len(data)) != n {
+		t.Errorf("plaintext size %d, read %d bytes", n, len(data))
+	}
+	return data, err
+}
+
+func TestLoadAge(t *testing.T) {
+	id, idFile, recipientFile := ageFixture(t)
+	for _, arg := range []string{id.Recipient().String(), recipientFile} {
+		keys, err := loadAgeRecipients(arg)
+		if err != nil || len(keys) != 1 {
+			t.Errorf("loadAgeRecipients(%q) = %v, %v", arg, keys, err)
+		}
+	}
+	if _, err := loadAgeRecipients("age1notavalidrecipient"); err == nil {
+		t.Error("loadAgeRecipients accepted an invalid recipient")
+	}
+	ids, err := loadAgeIdentities(idFile)
+	if err != nil || len(ids) != 1 {
+		t.Errorf("loadAgeIdentities = %v, %v", ids, err)
+	}
+	if _, err := loadAgeIdentities(recipientFile); err == nil {
+		t.Error("loadAgeIdentities accepted a recipients file")
+	}
+	if got := encryptedName("a.bin"); got != "a.bin.age" {
+		t.Errorf("encryptedName(a.bin) = %q", got)
+	}
+}
+
+func TestAgeRoundTrip(t *testing.T) {
+	id, _, _ := ageFixture(t)
+	other, _ := age.GenerateX25519Identity()
+	dir := t.TempDir()
+	// 空檔案、剛好一段（64 KiB）、跨越多段
+	for _, size := range []int{0, 1, 64 << 10, 64<<10 + 1, 300_000} {
+		data := make([]byte, size)
+		rand.Read(data)
+		p := encryptFile(t, dir, data, []age.Recipient{other.Recipient(), id.Recipient()})
+		got, err := decryptFile(t, p, []age.Identity{id})
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("size %d: round trip returned %d bytes", size, len(got))
+		}
+	}
+}
+
+func TestAgeRejectsDamage(t *testing.T) {
+	id, _, _ := ageFixture(t)
+	dir := t.TempDir()
+	data := make([]byte, 200_000)
+	rand.Read(data)
+	p := encryptFile(t, dir, data, []age.Recipient{id.Recipient()})
+	enc, _ := os.ReadFile(p)
+
+	stranger, _ := age.GenerateX25519Identity()
+	if _, err := decryptFile(t, p, []age.Identity{stranger}); err == nil {
+		t.Error("decrypted with the wrong identity")
+	}
+	flipped := bytes.Clone(enc)
+	flipped[len(flipped)/2] ^= 1
+	damaged := map[string][]byte{
+		"truncated":          enc[:len(enc)-1000],
+		"last chunk missing": enc[:len(enc)-(200_000-3<<16)-16],
+		"flipped byte":       flipped,
+		"not age":            []byte("plain text\n"),
+	}
+	for name, b := range damaged {
+		os.WriteFile(p, b, 0o644)
+		if got, err := decryptFile(t, p, []age.Identity{id}); err == nil {
+			t.Errorf("%s: decrypted %d bytes without an error", name, len(got))
+		}
+	}
+}
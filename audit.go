@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// auditEntry 是稽核紀錄中的一筆：每次執行的命令、對象伺服器與結果。
+// Hash 是 SHA-256(Prev 與其餘欄位的 JSON)，每筆都鏈結到前一筆，竄改或刪除任一筆都會讓之後的驗證失敗。
+type auditEntry struct {
+	Seq    int64     `json:"seq"`
+	Time   time.Time `json:"time"`
+	User   string    `json:"user,omitempty"`
+	Argv   []string  `json:"argv"`
+	Peer   string    `json:"peer,omitempty"`
+	Result string    `json:"result"` // ok | error
+	Error  string    `json:"error,omitempty"`
+	Prev   string    `json:"prev"`
+	Hash   string    `json:"hash,omitempty"`
+}
+
+// auditPeer 是本次命令連線的伺服器，由 run 在解析出位址後設定。
+var auditPeer string
+
+func auditPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "audit.jsonl"), nil
+}
+
+func (e auditEntry) computeHash() string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordAudit 在稽核紀錄尾端附加一筆；以鎖定檔避免多個行程同時附加而打斷雜湊鏈。
+func recordAudit(argv []string, runErr error) error {
+	path, err := auditPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	last, err := lastAuditEntry(f)
+	if err != nil {
+		return err
+	}
+	e := auditEntry{Seq: 1, Time: time.Now(), User: os.Getenv("USER"), Argv: argv, Peer: auditPeer, Result: "ok"}
+	if last != nil {
+		e.Seq, e.Prev = last.Seq+1, last.Hash
+	}
+	if runErr != nil {
+		e.Result, e.Error = "error", runErr.Error()
+	}
+	e.Hash = e.computeHash()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// lastAuditEntry 只讀取檔案結尾來找出最後一筆紀錄。
+func lastAuditEntry(f *os.File) (*auditEntry, error) {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return nil, err
+	}
+	start := max(info.Size()-64<<10, 0)
+	buf := make([]byte, info.Size()-start)
+	if _, err := f.ReadAt(buf, start); err != nil && err != io.EOF {
+		return nil, err
+	}
+	buf = bytes.TrimRight(buf, "\n")
+	line := buf[bytes.LastIndexByte(buf, '\n')+1:]
+	var e auditEntry
+	if err := json.Unmarshal(line, &e); err != nil {
+		return nil, fmt.Errorf("稽核紀錄最後一筆損毀: %v", err)
+	}
+	return &e, nil
+}
+
+// lockFile 以 O_EXCL 建立鎖定檔，超過 10 秒的鎖定檔視為殘留並移除。
+func lockFile(path string) (unlock func(), err error) {
+	for i := 0; ; i++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, serr := os.Stat(path); serr == nil && time.Since(info.ModTime()) > 10*time.Second {
+			os.Remove(path)
+			continue
+		}
+		if i > 200 {
+			return nil, fmt.Errorf("無法取得 %s", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// runAudit 實作 `audit [verify]`：列出稽核紀錄，或從頭驗證雜湊鏈。
+func runAudit(args []string) error {
+	path, err := auditPath()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return errors.New("沒有稽核紀錄")
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	verify := len(args) > 0 && args[0] == "verify"
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	var prev string
+	var n int64
+	for scanner.Scan() {
+		n++
+		var e auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("第 %d 行無法解析: %v", n, err)
+		}
+		if verify {
+			if e.Seq != n || e.Prev != prev || e.computeHash() != e.Hash {
+				return fmt.Errorf("稽核紀錄在第 %d 行 (seq %d) 遭到竄改或缺漏", n, e.Seq)
+			}
+			prev = e.Hash
+			continue
+		}
+		con.Result(e)
+		con.Printf("%6d  %s  %-5s  %-21s  %v\n", e.Seq, e.Time.Local().Format(time.DateTime), e.Result, e.Peer, e.Argv)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if verify {
+		con.Printf("稽核紀錄完整: %d 筆，最後雜湊 %s\n", n, prev)
+	}
+	return nil
+}
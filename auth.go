@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// authOptions 是 --token 與 --token-file：連線後以 bearer token 向伺服器認證。
+type authOptions struct {
+	token     string
+	tokenFile string
+}
+
+var clientAuth authOptions
+
+// validate 讀取 --token-file；指定時優先於 --token（例如環境變數提供的 token）。
+func (o *authOptions) validate() error {
+	if o.tokenFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(o.tokenFile)
+	if err != nil {
+		return fmt.Errorf("無法讀取 --token-file: %v", err)
+	}
+	if o.token = strings.TrimSpace(string(data)); o.token == "" {
+		return fmt.Errorf("--token-file %s 是空的", o.tokenFile)
+	}
+	return nil
+}
+
+// authenticate 在新建立的連線上送出 token；沒有設定 token 時不做任何事。
+func (o *authOptions) authenticate(ctx context.Context, session *quic.Conn, server string) error {
+	if o.token == "" {
+		return nil
+	}
+	if err := client.New(session).Authenticate(ctx, o.token); err != nil {
+		return fmt.Errorf("無法向 %s 認證: %w", server, err)
+	}
+	return nil
+}
+
+// explainAuthError 在伺服器拒絕認證時補充說明，不把錯誤當成一般的伺服器錯誤。
+func explainAuthError(err error) error {
+	if !errors.Is(err, client.ErrUnauthorized) {
+		return err
+	}
+	if clientAuth.token == "" {
+		return fmt.Errorf("%w（伺服器要求認證，請以 --token 或 --token-file 提供 token）", err)
+	}
+	return fmt.Errorf("%w（token 無效或已過期）", err)
+}
+
+// redactArgv 回傳把 --token 的值換成 *** 的命令列，避免 token 寫進稽核紀錄。
+func redactArgv(argv []string) []string {
+	out := make([]string, len(argv))
+	copy(out, argv)
+	for i, a := range out {
+		name := strings.TrimLeft(a, "-")
+		switch {
+		case !strings.HasPrefix(a, "-"):
+		case name == "token" && i+1 < len(out):
+			out[i+1] = "***"
+		case strings.HasPrefix(name, "token="):
+			out[i] = a[:len(a)-len(name)] + "token=***"
+		}
+	}
+	return out
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-client/client"
+)
+
+// batchCommand 是 batch 模式的一個指令。每行可以是文字：
+//
+//	get [--force|--no-clobber|--backup] [--resume] <remote> [local]
+//	put <local> [remote]
+//	rm <remote>
+//
+// 或一個 JSON 物件，例如 {"id":"a1","op":"get","remote":"x.bin","local":"/tmp/x.bin","force":true}；
+// 路徑含空白時必須使用 JSON。id 原樣放回結果中，省略時為行號。
+type batchCommand struct {
+	ID        string `json:"id,omitempty"`
+	Op        string `json:"op"`
+	Remote    string `json:"remote,omitempty"`
+	Local     string `json:"local,omitempty"`
+	Force     bool   `json:"force,omitempty"`
+	NoClobber bool   `json:"no_clobber,omitempty"`
+	Backup    bool   `json:"backup,omitempty"`
+	Resume    bool   `json:"resume,omitempty"`
+}
+
+// batchResult 是每個指令的結果，以一行 JSON 寫到 stdout。
+type batchResult struct {
+	ID       string        `json:"id"`
+	Op       string        `json:"op,omitempty"`
+	Remote   string        `json:"remote,omitempty"`
+	Local    string        `json:"local,omitempty"`
+	OK       bool          `json:"ok"`
+	Bytes    int64         `json:"bytes,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+	ExitCode int           `json:"exit_code,omitempty"`
+}
+
+// parseBatchCommand 解析一行指令；line 以 { 開頭時視為 JSON。
+func parseBatchCommand(line string) (batchCommand, error) {
+	var c batchCommand
+	if strings.HasPrefix(line, "{") {
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			return c, fmt.Errorf("無效的 JSON 指令: %v", err)
+		}
+		return c, nil
+	}
+	fields := strings.Fields(line)
+	c.Op = fields[0]
+	flags := flag.NewFlagSet(c.Op, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	if c.Op == "get" {
+		flags.BoolVar(&c.Force, "force", false, "")
+		flags.BoolVar(&c.NoClobber, "no-clobber", false, "")
+		flags.BoolVar(&c.Backup, "backup", false, "")
+		flags.BoolVar(&c.Resume, "resume", false, "")
+	}
+	if err := flags.Parse(fields[1:]); err != nil {
+		return c, err
+	}
+	args := flags.Args()
+	switch {
+	case c.Op == "put" && len(args) >= 1 && len(args) <= 2:
+		c.Local = args[0]
+		if len(args) == 2 {
+			c.Remote = args[1]
+		}
+	case c.Op == "rm" && len(args) == 1:
+		c.Remote = args[0]
+	case c.Op == "get" && len(args) >= 1 && len(args) <= 2:
+		c.Remote = args[0]
+		if len(args) == 2 {
+			c.Local = args[1]
+		}
+	case c.Op != "put" && c.Op != "rm" && c.Op != "get":
+		return c, fmt.Errorf("不支援的指令 %q，可用 get、put 或 rm", c.Op)
+	default:
+		return c, fmt.Errorf("參數數量不正確: %s", line)
+	}
+	return c, nil
+}
+
+// runBatch 實作 `batch -`：從 in 逐行讀取 get、put 與 rm 指令，在同一條連線上依序執行
+// （連線中斷時下一個指令重新連線），每個指令結束後立即寫出一行結果，讓其他程式不必每個檔案啟動一個行程。
+// 失敗的指令不會中止批次；有任何指令失敗時最後回傳錯誤。stdout 只有結果，其他訊息改寫到 stderr。
+func runBatch(ctx context.Context, live *liveSession, in io.Reader, opts getOptions) error {
+	con.data = true
+	if con.events == os.Stdout {
+		con.events = nil
+	}
+	// batch 自己讀取 stdin，傳輸時不監聽按鍵
+	opts.noKeys = true
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+
+	total, failed := 0, 0
+	scanner := bufio.NewScanner(in)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		total++
+		start := time.Now()
+		c, err := parseBatchCommand(text)
+		if c.ID == "" {
+			c.ID = strconv.Itoa(line)
+		}
+		if c.Op == "put" && c.Remote == "" && c.Local != "" {
+			c.Remote = filepath.Base(c.Local)
+		}
+		res := batchResult{ID: c.ID, Op: c.Op, Remote: c.Remote, Local: c.Local}
+		if err == nil {
+			res.Local, res.Bytes, err = execBatch(ctx, live, c, opts)
+		}
+		res.Duration = time.Since(start)
+		res.OK = err == nil
+		if err != nil {
+			failed++
+			res.Error, res.ExitCode = err.Error(), exitCode(err)
+		}
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 個指令中有 %d 個失敗", total, failed)
+	}
+	return nil
+}
+
+// execBatch 執行一個指令，回傳本機路徑與傳輸的位元組數。
+func execBatch(ctx context.Context, live *liveSession, c batchCommand, opts getOptions) (string, int64, error) {
+	session, err := live.get(ctx)
+	if err != nil {
+		return c.Local, 0, err
+	}
+	switch c.Op {
+	case "get":
+		if c.Remote == "" {
+			return c.Local, 0, errors.New("get 需要 remote")
+		}
+		local := c.Local
+		if local == "" {
+			if local, err = destination(c.Remote, opts.output, opts.parents); err != nil {
+				return "", 0, err
+			}
+		}
+		o := opts
+		o.local = local
+		o.resume = opts.resume || c.Resume
+		if c.Force || c.NoClobber || c.Backup {
+			if o.clobber, err = parseClobber(c.NoClobber, c.Backup, c.Force); err != nil {
+				return local, 0, err
+			}
+		}
+		if err := runGet(ctx, session, c.Remote, o); err != nil {
+			return local, 0, err
+		}
+		if len(o.encryptTo) > 0 {
+			local = encryptedName(local)
+		}
+		info, err := os.Stat(local)
+		if err != nil {
+			return local, 0, err
+		}
+		return local, info.Size(), nil
+	case "put":
+		if c.Local == "" {
+			return "", 0, errors.New("put 需要 local")
+		}
+		n, err := sendFile(ctx, session, c.Local, c.Remote, opts)
+		return c.Local, n, err
+	case "rm":
+		if c.Remote == "" {
+			return "", 0, errors.New("rm 需要 remote")
+		}
+		return "", 0, client.New(session).Remove(ctx, c.Remote)
+	}
+	return c.Local, 0, fmt.Errorf("不支援的指令 %q，可用 get、put 或 rm", c.Op)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// benchResult 是 bench 的結果；只測一個方向時另一個方向為 nil。
+type benchResult struct {
+	Server   string      `json:"server"`
+	Streams  int         `json:"streams"`
+	Download *benchStats `json:"download,omitempty"`
+	Upload   *benchStats `json:"upload,omitempty"`
+}
+
+// benchStats 是一個方向的結果。遺失取自本端的封包統計，因此只計入本端送出的封包：
+// 上傳時是資料封包，下載時只有 ACK。
+type benchStats struct {
+	Bytes       int64         `json:"bytes"`
+	Duration    time.Duration `json:"duration"`
+	Goodput     float64       `json:"goodput"` // bytes/s
+	RTT         rttSummary    `json:"rtt"`
+	PacketsSent int64         `json:"packets_sent"`
+	PacketsLost int64         `json:"packets_lost"`
+	LossRate    float64       `json:"loss_rate"`
+}
+
+type rttSummary struct {
+	Samples int           `json:"samples"`
+	Min     time.Duration `json:"min"`
+	P50     time.Duration `json:"p50"`
+	P90     time.Duration `json:"p90"`
+	P99     time.Duration `json:"p99"`
+	Max     time.Duration `json:"max"`
+}
+
+// benchWorker 在 ctx 結束前持續傳輸，把傳輸的位元組數累加到 n。
+type benchWorker func(ctx context.Context, i int, n *atomic.Int64) error
+
+const (
+	benchSampleInterval = 20 * time.Millisecond
+	benchBufferSize     = 64 << 10
+)
+
+// runBench 量測連線的吞吐量：下載時在 N 個 stream 上重複下載同一個遠端檔案，上傳時重複上傳
+// 隨機內容到暫存檔（結束後刪除），每秒印出速度，最後回報 goodput、RTT 百分位數與封包遺失。
+func runBench(ctx context.Context, server string, args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	direction := flags.String("d", "down", "方向：down、up 或 both（依序測試）")
+	duration := flags.Duration("t", 10*time.Second, "每個方向的測試時間")
+	streams := flags.Int("P", 4, "平行的 stream 數")
+	file := flags.String("file", "", "下載測試重複下載的遠端檔案，越大越好")
+	chunk := flags.String("size", "64M", "上傳測試每次上傳的大小")
+	flags.Parse(args)
+
+	down := *direction == "down" || *direction == "both"
+	up := *direction == "up" || *direction == "both"
+	if !down && !up {
+		return fmt.Errorf("無效的方向 %q（可用 down、up、both）", *direction)
+	}
+	if *streams < 1 || *duration <= 0 {
+		return errors.New("-P 至少為 1，-t 必須大於 0")
+	}
+	if down && *file == "" {
+		return errors.New("下載測試需要以 -file 指定遠端檔案")
+	}
+	size, err := parseSize(*chunk)
+	if err != nil || size <= 0 {
+		return fmt.Errorf("無效的 -size %q", *chunk)
+	}
+
+	session, err := dial(ctx, server, nil)
+	if err != nil {
+		return err
+	}
+	defer session.CloseWithError(0, "")
+	con.Printf("已連線到 %s (%s)，%d 個 stream，每個方向 %v\n", server, session.RemoteAddr(), *streams, *duration)
+
+	res := benchResult{Server: server, Streams: *streams}
+	if down {
+		if res.Download, err = benchRun(ctx, session, "下載", *duration, *streams, benchDownload(session, *file)); err != nil {
+			return err
+		}
+	}
+	if up {
+		if res.Upload, err = benchRun(ctx, session, "上傳", *duration, *streams, benchUpload(ctx, session, size)); err != nil {
+			return err
+		}
+	}
+	con.Result(res)
+	return nil
+}
+
+// benchDownload 重複下載 name；每次讀到檔尾就重新開始。
+func benchDownload(session *quic.Conn, name string) benchWorker {
+	c := client.New(session)
+	return func(ctx context.Context, _ int, n *atomic.Int64) error {
+		buf := make([]byte, benchBufferSize)
+		for ctx.Err() == nil {
+			d, err := c.Open(ctx, name, client.GetOptions{NoVerify: true})
+			if err != nil {
+				return err
+			}
+			if d.Size == 0 {
+				d.Close()
+				return fmt.Errorf("%s 是空檔案", name)
+			}
+			stop := context.AfterFunc(ctx, d.Cancel)
+			for err == nil {
+				var m int
+				m, err = d.Read(buf)
+				n.Add(int64(m))
+			}
+			stop()
+			d.Close()
+			if err != io.EOF {
+				return err
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// benchUpload 重複上傳 size 個隨機位元組到 .bench-<pid>-<i>；測試結束時進行中的上傳被取消，
+// 已完成的暫存檔在結束後刪除。
+func benchUpload(parent context.Context, session *quic.Conn, size int64) benchWorker {
+	c := client.New(session)
+	return func(ctx context.Context, i int, n *atomic.Int64) error {
+		name := fmt.Sprintf(".bench-%d-%d", os.Getpid(), i)
+		buf := make([]byte, benchBufferSize)
+		rand.Read(buf)
+		uploaded := false
+		defer func() {
+			if uploaded {
+				if err := c.Remove(parent, name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					con.Printf("無法刪除暫存檔 %s: %v\n", name, err)
+				}
+			}
+		}()
+		for ctx.Err() == nil {
+			u, err := c.OpenUpload(ctx, name, client.PutOptions{Size: size})
+			if err != nil {
+				return err
+			}
+			stop := context.AfterFunc(ctx, u.Cancel)
+			for written := int64(0); written < size && err == nil; {
+				var m int
+				m, err = u.Write(buf[:min(int64(len(buf)), size-written)])
+				written += int64(m)
+				n.Add(int64(m))
+			}
+			stop()
+			if err != nil {
+				u.Cancel()
+				return err
+			}
+			if err := u.Close(); err != nil {
+				return err
+			}
+			uploaded = true
+		}
+		return ctx.Err()
+	}
+}
+
+// benchRun 以 streams 個 worker 傳輸 d 的時間，每秒印出一行速度並取樣 RTT。
+func benchRun(ctx context.Context, session *quic.Conn, label string, d time.Duration, streams int, work benchWorker) (*benchStats, error) {
+	metrics := metricsOf(session)
+	before := metrics.Snapshot()
+	runCtx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	var n atomic.Int64
+	errc := make(chan error, streams)
+	start := time.Now()
+	for i := range streams {
+		go func() { errc <- work(runCtx, i, &n) }()
+	}
+	sample := time.NewTicker(benchSampleInterval)
+	defer sample.Stop()
+	report := time.NewTicker(time.Second)
+	defer report.Stop()
+
+	var (
+		rtts     []time.Duration
+		last     int64
+		lastAt   = start
+		stats    = &benchStats{}
+		firstErr error
+		done     = runCtx.Done()
+	)
+	for running := streams; running > 0; {
+		select {
+		case <-sample.C:
+			if rtt := metrics.Snapshot().LatestRTT; rtt > 0 {
+				rtts = append(rtts, rtt)
+			}
+		case now := <-report.C:
+			cur := n.Load()
+			con.Printf("[%3.0fs] %s %s/s  rtt %v\n", now.Sub(start).Seconds(), label,
+				humanSize(int64(float64(cur-last)/now.Sub(lastAt).Seconds())), metrics.Snapshot().SmoothedRTT.Round(100*time.Microsecond))
+			last, lastAt = cur, now
+		case <-done:
+			// 時間到的那一刻為準，之後取消中的傳輸不計入
+			stats.Bytes, stats.Duration = n.Load(), time.Since(start)
+			done = nil
+		case err := <-errc:
+			running--
+			if err != nil && runCtx.Err() == nil && firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+		}
+	}
+	if firstErr != nil {
+		return nil, fmt.Errorf("%s測試失敗: %w", label, firstErr)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	after := metrics.Snapshot()
+	stats.Goodput = float64(stats.Bytes) / stats.Duration.Seconds()
+	stats.RTT = summarizeRTT(rtts)
+	stats.PacketsSent = after.PacketsSent - before.PacketsSent
+	stats.PacketsLost = after.PacketsLost - before.PacketsLost
+	if stats.PacketsSent > 0 {
+		stats.LossRate = float64(stats.PacketsLost) / float64(stats.PacketsSent)
+	}
+	con.Printf("%s: 共 %s，耗時 %v，平均 %s/s (%.1f Mbit/s)\n", label, humanSize(stats.Bytes), stats.Duration.Round(time.Millisecond),
+		humanSize(int64(stats.Goodput)), stats.Goodput*8/1e6)
+	r := stats.RTT
+	con.Printf("  rtt min/p50/p90/p99/max = %v/%v/%v/%v/%v（%d 個樣本）\n", r.Min.Round(time.Microsecond), r.P50.Round(time.Microsecond),
+		r.P90.Round(time.Microsecond), r.P99.Round(time.Microsecond), r.Max.Round(time.Microsecond), r.Samples)
+	con.Printf("  本端送出 %d 個封包，遺失 %d 個 (%.2f%%)\n", stats.PacketsSent, stats.PacketsLost, stats.LossRate*100)
+	return stats, nil
+}
+
+// summarizeRTT 回傳 RTT 樣本的最小值、百分位數與最大值。
+func summarizeRTT(rtts []time.Duration) rttSummary {
+	if len(rtts) == 0 {
+		return rttSummary{}
+	}
+	slices.Sort(rtts)
+	at := func(p float64) time.Duration { return rtts[min(int(p*float64(len(rtts))), len(rtts)-1)] }
+	return rttSummary{Samples: len(rtts), Min: rtts[0], P50: at(0.5), P90: at(0.9), P99: at(0.99), Max: rtts[len(rtts)-1]}
+}
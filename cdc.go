@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+// 內容定義切塊（gear hash，類似 FastCDC）的參數：平均約 64 KiB，介於 16 KiB 與 256 KiB 之間。
+// 切點只取決於內容，檔案中間插入或刪除資料時其餘 chunk 不受影響。
+const (
+	cdcMinSize = 16 << 10
+	cdcMaxSize = 256 << 10
+	cdcMask    = 1<<16 - 1
+)
+
+// gearTable 是固定種子產生的 256 個亂數；改變它會讓既有的 chunk 索引全部失效。
+var gearTable = func() (t [256]uint64) {
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range t {
+		// splitmix64
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		t[i] = z ^ z>>31
+	}
+	return t
+}()
+
+// chunk 是檔案中的一段內容，以 SHA-256 定址。
+type chunk struct {
+	Offset int64
+	Size   int64
+	Hash   string
+}
+
+// cutPoint 回傳 data 中第一個 chunk 的長度。
+func cutPoint(data []byte) int {
+	if len(data) <= cdcMinSize {
+		return len(data)
+	}
+	var h uint64
+	for i := cdcMinSize; i < len(data); i++ {
+		h = h<<1 + gearTable[data[i]]
+		if h&cdcMask == 0 {
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// chunkReader 把 r 切成 chunk 並依序呼叫 fn；data 只在 fn 執行期間有效。
+func chunkReader(r io.Reader, fn func(c chunk, data []byte) error) error {
+	br := bufio.NewReaderSize(r, cdcMaxSize)
+	buf := make([]byte, cdcMaxSize)
+	var n int
+	var offset int64
+	eof := false
+	for {
+		if !eof {
+			m, err := io.ReadFull(br, buf[n:])
+			n += m
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+		}
+		if n == 0 {
+			return nil
+		}
+		cut := cutPoint(buf[:n])
+		sum := sha256.Sum256(buf[:cut])
+		c := chunk{Offset: offset, Size: int64(cut), Hash: hex.EncodeToString(sum[:])}
+		if err := fn(c, buf[:cut]); err != nil {
+			return err
+		}
+		offset += int64(cut)
+		n = copy(buf, buf[cut:n])
+	}
+}
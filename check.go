@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go-client/client"
+)
+
+// fetchHash 向伺服器送出 `hash <path>`，回傳十六進位的雜湊值。
+func fetchHash(ctx context.Context, server, path string) (string, error) {
+	session, err := dial(ctx, server, nil)
+	if err != nil {
+		return "", err
+	}
+	defer session.CloseWithError(0, "")
+
+	sum, err := client.New(session).Hash(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	return sum.Sum, nil
+}
+
+type checkResult struct {
+	Server string `json:"server"`
+	Path   string `json:"path"`
+	Status string `json:"status"` // ok | diverged | error
+	Hash   string `json:"hash,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// runCheck 同時向多台鏡像查詢同一路徑的雜湊值，以多數結果為基準回報不一致的伺服器。
+func runCheck(ctx context.Context, servers []string, path string) error {
+	hashes := make([]string, len(servers))
+	errs := make([]error, len(servers))
+
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hashes[i], errs[i] = fetchHash(ctx, server, path)
+		}()
+	}
+	wg.Wait()
+
+	counts := make(map[string]int)
+	for i, h := range hashes {
+		if errs[i] == nil {
+			counts[h]++
+		}
+	}
+	var reference string
+	for h, n := range counts {
+		if n > counts[reference] || (n == counts[reference] && h < reference) {
+			reference = h
+		}
+	}
+
+	bad := 0
+	for i, server := range servers {
+		res := checkResult{Server: server, Path: path, Hash: hashes[i], Status: "ok"}
+		switch {
+		case errs[i] != nil:
+			bad++
+			res.Status, res.Error = "error", errs[i].Error()
+			con.Printf("%-24s ERROR    %v\n", server, errs[i])
+		case hashes[i] != reference:
+			bad++
+			res.Status = "diverged"
+			con.Printf("%-24s DIVERGED %s\n", server, hashes[i])
+		default:
+			con.Printf("%-24s OK       %s\n", server, hashes[i])
+		}
+		con.Result(res)
+	}
+	if bad > 0 {
+		return fmt.Errorf("%s: %d/%d 台鏡像不一致", path, bad, len(servers))
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/hex"
+	"hash"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// checkpointInterval 是下載中寫入檢查點的間隔。
+const checkpointInterval = 2 * time.Second
+
+// checkpoint 記錄一個下載已經安全寫入磁碟的位置，讓被中斷的行程能精確續傳。
+type checkpoint struct {
+	Remote string `json:"remote"`
+	Local  string `json:"local"`
+	Size   int64  `json:"size"`
+	Offset int64  `json:"offset"`
+}
+
+// countingWriter 計算實際寫入輸出檔的位元組數。寫入時持有鎖，snapshot 取得的位置與雜湊一致。
+type countingWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+	n  int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// snapshot 回傳目前寫入的位元組數，以及 h（經由 w 寫入的雜湊）在這個位置的值。
+func (cw *countingWriter) snapshot(h hash.Hash) (int64, string) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.n, hex.EncodeToString(h.Sum(nil))
+}
+
+// partialPath 是下載進行中寫入的暫存檔。內容完整並通過校驗後才改名為 local，
+// 傳輸失敗時不會覆蓋原本的檔案，留下的暫存檔可供續傳。
+func partialPath(local string) string {
+	return local + ".partial"
+}
+
+// commitOutput 關閉暫存檔並以 rename 原子地取代 local，既有的 local 依 clobber 處理。
+func commitOutput(out *os.File, local string, clobber clobberPolicy) error {
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return clobber.replace(out.Name(), local)
+}
+
+// openOutput 開啟下載目的檔；offset > 0 時保留前 offset 個位元組並從該處續寫，
+// 超過檢查點的部分可能未完整寫入，一律截斷。
+func openOutput(local string, offset int64) (*os.File, error) {
+	if offset == 0 {
+		return os.Create(local)
+	}
+	f, err := os.OpenFile(local, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// hashPrefix 把本機檔案前 n 個位元組寫入 h。
+func hashPrefix(h io.Writer, local string, n int64) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.CopyN(h, f, n)
+	return err
+}
+
+// resumePoint 回傳此傳輸對 remote 的檢查點；沒有檢查點或本機檔案已不符時回傳 nil。
+func (j *job) resumePoint(remote string) *checkpoint {
+	if j == nil || j.Checkpoint == nil || j.Checkpoint.Remote != remote {
+		return nil
+	}
+	info, err := os.Stat(j.Checkpoint.Local)
+	if err != nil || info.Size() < j.Checkpoint.Offset {
+		return nil
+	}
+	return j.Checkpoint
+}
+
+// checkpointEvery 每隔 interval 先 fsync 輸出檔，再把已寫入的位置存進傳輸紀錄與 st（.state 旁檔）；
+// hasher 是經由 written 寫入、包含續傳前已下載部分的 SHA-256。
+// 回傳的函式停止定期寫入，並記錄最後一次檢查點。
+func (j *job) checkpointEvery(interval time.Duration, out *os.File, written *countingWriter, hasher hash.Hash, cp *checkpoint, st *transferState, local string) func() {
+	base := cp.Offset
+	if j != nil {
+		j.Checkpoint = cp
+	}
+	return saveEvery(interval, func() {
+		// 先讀取計數再 fsync，確保記錄的位置一定已經落在磁碟上
+		n, sum := written.snapshot(hasher)
+		if err := out.Sync(); err != nil {
+			return
+		}
+		if j != nil {
+			cp.Offset = base + n
+			if err := j.save(); err != nil {
+				slog.Warn("無法寫入檢查點", "err", err)
+			}
+		}
+		st.Written, st.SHA256 = base+n, sum
+		if err := st.save(local); err != nil {
+			slog.Warn("無法寫入 .state", "file", statePath(local), "err", err)
+		}
+	})
+}
+
+// saveEvery 每隔 interval 呼叫 save；回傳的函式停止定期呼叫，並在結束前再呼叫一次。
+func saveEvery(interval time.Duration, save func()) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				save()
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+		wg.Wait()
+		save()
+	}
+}
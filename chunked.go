@@ -0,0 +1,427 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// chunksPerConnection 是 --connections 時每條連線平均分到的區段數；區段多一些，
+// 快的連線做完自己的部分後還有區段可以接手。
+const chunksPerConnection = 4
+
+// byteRange 是分段下載的一段 [start, end)。pos 隨讀取前進，done 之前的部分已寫入輸出檔，h 是這部分的 SHA-256；
+// end 可能被閒置的連線縮短，後半段改由該連線下載。
+type byteRange struct {
+	start, pos, done, end int64
+	h                     hash.Hash
+}
+
+func newByteRange(start, end int64) *byteRange {
+	return &byteRange{start: start, pos: start, done: start, end: end, h: sha256.New()}
+}
+
+// chunkScheduler 把區段分配給各條連線。沒有待下載的區段時，閒置的連線從剩餘最多的進行中區段
+// （通常是最慢的連線）接手後半段，類似 aria2 的做法，避免整個下載等一條慢的連線。
+type chunkScheduler struct {
+	mu      sync.Mutex
+	pending []*byteRange
+	active  map[*byteRange]bool
+	all     []*byteRange // 包括已完成的區段，寫入 .state 用
+}
+
+func newChunkScheduler(size int64, n int) *chunkScheduler {
+	s := &chunkScheduler{active: make(map[*byteRange]bool)}
+	part := max(size/int64(n), minRangeSize)
+	for off := int64(0); off < size; off += part {
+		c := newByteRange(off, min(off+part, size))
+		s.pending = append(s.pending, c)
+		s.all = append(s.all, c)
+	}
+	return s
+}
+
+// resumeChunkScheduler 依 .state 記錄的區段建立排程，並回傳驗證通過、不必重新下載的位元組數。
+// 已寫入的部分與記錄的 SHA-256 不符（當機前沒有完整落在磁碟上）時，該區段從頭下載。
+func resumeChunkScheduler(st *transferState, f io.ReaderAt) (*chunkScheduler, int64) {
+	s := &chunkScheduler{active: make(map[*byteRange]bool)}
+	var verified int64
+	for _, sc := range st.Chunks {
+		c := newByteRange(sc.Off, sc.End)
+		if sc.Off <= sc.Pos && sc.Pos <= sc.End {
+			if h, ok := verifyPrefix(f, sc.Off, sc.Pos, sc.SHA256); ok {
+				c.pos, c.done, c.h = sc.Pos, sc.Pos, h
+				verified += sc.Pos - sc.Off
+			}
+		}
+		if c.pos < c.end {
+			s.pending = append(s.pending, c)
+		}
+		s.all = append(s.all, c)
+	}
+	return s, verified
+}
+
+// first 取出第一個區段給已經開始傳送檔案開頭的 stream。
+func (s *chunkScheduler) first() *byteRange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.pending[0]
+	s.pending = s.pending[1:]
+	s.active[c] = true
+	return c
+}
+
+// next 回傳下一個要下載的區段，沒有可以分配的區段時回傳 nil。
+func (s *chunkScheduler) next() *byteRange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) > 0 {
+		c := s.pending[0]
+		s.pending = s.pending[1:]
+		s.active[c] = true
+		return c
+	}
+	var slow *byteRange
+	for c := range s.active {
+		if slow == nil || c.end-c.pos > slow.end-slow.pos {
+			slow = c
+		}
+	}
+	// 剩下的部分太小時，多開一個請求不會比較快
+	if slow == nil || slow.end-slow.pos < 2*minRangeSize {
+		return nil
+	}
+	mid := slow.pos + (slow.end-slow.pos)/2
+	c := newByteRange(mid, slow.end)
+	slow.end = mid
+	s.active[c] = true
+	s.all = append(s.all, c)
+	return c
+}
+
+// claim 保留 c 接下來最多 n 個位元組，回傳寫入位置與長度；長度為 0 表示這個區段已經完成。
+func (s *chunkScheduler) claim(c *byteRange, n int) (int64, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	off := c.pos
+	n = int(min(int64(n), c.end-c.pos))
+	c.pos += int64(n)
+	return off, n
+}
+
+// wrote 記錄 c 接續寫入了 p。
+func (s *chunkScheduler) wrote(c *byteRange, p []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.h.Write(p)
+	c.done += int64(len(p))
+}
+
+// snapshot 依位置排序回傳各區段的進度，寫入 .state。
+func (s *chunkScheduler) snapshot() []stateChunk {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chunks := make([]stateChunk, 0, len(s.all))
+	for _, c := range s.all {
+		chunks = append(chunks, stateChunk{Off: c.start, Pos: c.done, End: c.end, SHA256: hex.EncodeToString(c.h.Sum(nil))})
+	}
+	slices.SortFunc(chunks, func(a, b stateChunk) int { return cmp.Compare(a.Off, b.Off) })
+	return chunks
+}
+
+// span 回傳 c 目前的範圍。
+func (s *chunkScheduler) span(c *byteRange) (pos, end int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return c.pos, c.end
+}
+
+// finish 結束 c；失敗時把還沒下載的部分放回佇列，由其他連線接手。
+func (s *chunkScheduler) finish(c *byteRange, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.active, c)
+	// 已讀取但沒有寫入的部分要重新下載
+	c.pos = c.done
+	if failed && c.pos < c.end {
+		s.pending = append(s.pending, c)
+	}
+}
+
+// remaining 回傳尚未下載的位元組數。
+func (s *chunkScheduler) remaining() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, c := range s.pending {
+		n += c.end - c.pos
+	}
+	for c := range s.active {
+		n += c.end - c.pos
+	}
+	return n
+}
+
+// dialExtra 另外建立 n 條連線；部分失敗時只用成功的連線，並在下載結束時關閉它們。
+func dialExtra(ctx context.Context, server string, n int) []*quic.Conn {
+	var (
+		mu    sync.Mutex
+		conns []*quic.Conn
+		wg    sync.WaitGroup
+	)
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := dial(ctx, server, nil)
+			if err != nil {
+				slog.Warn("無法建立額外的連線", "server", server, "err", err)
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return conns
+}
+
+// getChunked 以 --connections 條 QUIC 連線平行下載不同區段並直接寫入輸出檔的對應位置，
+// 適用於每條連線各自限速的伺服器。first 是主連線上已讀過標頭、從檔案開頭開始傳送的下載；
+// st 不為 nil 時依 .state 接續先前中斷的分段下載。
+func getChunked(ctx context.Context, session *quic.Conn, filename, local string, first *client.Download, st *transferState, opts getOptions) error {
+	conns := append([]*quic.Conn{session}, dialExtra(ctx, opts.server, opts.connections-1)...)
+	defer func() {
+		for _, conn := range conns[1:] {
+			conn.CloseWithError(0, "")
+		}
+	}()
+	if len(conns) < opts.connections {
+		con.Printf("只建立了 %d 條連線（要求 %d 條）\n", len(conns), opts.connections)
+	}
+	return getParallel(ctx, conns, chunksPerConnection, filename, local, first, st, opts)
+}
+
+// getParallel 把檔案分成 len(conns)*perConn 個區段，由 conns 上的 stream 平行下載（同一條連線可以重複出現），
+// 先做完的 stream 接手其他區段的後半段。各區段的進度定期寫入 .state，中斷後可以從各區段的位置續傳。
+func getParallel(ctx context.Context, conns []*quic.Conn, perConn int, filename, local string, first *client.Download, st *transferState, opts getOptions) error {
+	size, checksum, meta := first.Size, first.Checksum, first.FileMeta
+	var (
+		out      *os.File
+		sched    *chunkScheduler
+		verified int64
+		saved    atomic.Bool // .state 至少寫入過一次，失敗時保留暫存檔供續傳
+	)
+	if st != nil {
+		// 沿用先前預先配置的暫存檔；不存在或大小不符時重新下載
+		f, err := os.OpenFile(partialPath(local), os.O_RDWR, 0)
+		if info, serr := os.Stat(partialPath(local)); err == nil && serr == nil && info.Size() == size {
+			out = f
+			sched, verified = resumeChunkScheduler(st, f)
+			con.Printf("%s: 依 %s 續傳，已驗證 %s\n", local, statePath(local), humanSize(verified))
+		} else if err == nil {
+			f.Close()
+		}
+	}
+	if out == nil {
+		st = &transferState{Remote: filename, Size: size, Mtime: first.Mtime}
+		f, err := os.Create(partialPath(local))
+		if err != nil {
+			first.Close()
+			return err
+		}
+		out = f
+		if err := opts.perms.applyFile(out); err != nil {
+			out.Close()
+			os.Remove(out.Name())
+			first.Close()
+			return err
+		}
+		if err := out.Truncate(size); err != nil {
+			out.Close()
+			os.Remove(out.Name())
+			first.Close()
+			return err
+		}
+		sched = newChunkScheduler(size, len(conns)*perConn)
+	}
+	defer out.Close()
+	defer func() {
+		if !saved.Load() {
+			os.Remove(out.Name())
+		}
+	}()
+	if st.Chunks != nil {
+		// first 從檔案開頭開始傳送，續傳時用不到
+		first.Close()
+		first = nil
+	}
+	saveState := func() {
+		chunks := sched.snapshot()
+		if err := out.Sync(); err != nil {
+			return
+		}
+		st.Chunks = chunks
+		if err := st.save(local); err != nil {
+			slog.Warn("無法寫入 .state", "file", statePath(local), "err", err)
+			return
+		}
+		saved.Store(true)
+	}
+	if st.Chunks != nil {
+		saved.Store(true)
+	}
+	saveState()
+	// `resume <id>` 時依 .state 接續
+	if opts.job != nil {
+		opts.job.Checkpoint = &checkpoint{Remote: filename, Local: out.Name(), Size: size}
+		opts.job.save()
+	}
+	stopState := saveEvery(checkpointInterval, saveState)
+
+	progress := NewProgressReader(nil, size)
+	progress.name = filename
+	if m := metricsOf(conns[0]); opts.verbose && m != nil {
+		progress.suffix = m.progressSuffix
+	}
+	progress.readBytes.Store(verified)
+	progress.StartMonitor()
+	defer progress.Stop()
+
+	// 所有連線共用同一個令牌桶，整個檔案一起受速度上限與權重限制
+	bucket := newTokenBucket()
+	opts.limiter.Join(bucket, opts.weight)
+	defer opts.limiter.Leave(bucket)
+
+	con.Event(transferEvent{Event: "start", File: filename, Bytes: verified, Total: size, Local: local})
+	start := time.Now()
+	errs := make(chan error, len(conns))
+	var wg sync.WaitGroup
+	for i, conn := range conns {
+		d, c := (*client.Download)(nil), (*byteRange)(nil)
+		if i == 0 && first != nil {
+			d, c = first, sched.first()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ; ; d, c = nil, nil {
+				if c == nil {
+					if c = sched.next(); c == nil {
+						return
+					}
+				}
+				err := getChunk(ctx, conn, filename, d, c, size, out, sched, progress, bucket, opts)
+				sched.finish(c, err != nil)
+				if err != nil {
+					// 這條連線不再使用，剩下的部分由其他連線接手
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	progress.Stop()
+	stopState()
+	close(errs)
+	if left := sched.remaining(); left > 0 || ctx.Err() != nil {
+		if interrupted.Load() {
+			con.Printf("已中斷，已下載的部分保存在 %s\n", out.Name())
+			return errInterrupted
+		}
+		err := <-errs
+		if err == nil {
+			err = ctx.Err()
+		}
+		return fmt.Errorf("下載失敗（尚有 %d bytes 未完成）: %w", left, err)
+	}
+	for err := range errs {
+		slog.Warn("部分連線失敗，已由其他連線完成", "file", filename, "err", err)
+	}
+	sum, err := fileSHA256(out.Name())
+	if err != nil {
+		return err
+	}
+	if checksum != nil && !opts.noVerify {
+		if err := verifyFile(out.Name(), sum, checksum); err != nil {
+			return quarantine(out, local, err)
+		}
+	}
+	if err := commitOutput(out, local, opts.clobber); err != nil {
+		return err
+	}
+	if opts.preserve {
+		opts.perms.preserveMeta(local, meta)
+	}
+	finishGet(conns[0], filename, local, size, sum, start, opts)
+	return nil
+}
+
+// getChunk 下載區段 c 並寫到 out 的相同位置，直到 c 完成（包括後半段被其他連線接手）；
+// d 為 nil 時在 conn 上開新的 stream 請求該段。
+func getChunk(ctx context.Context, conn *quic.Conn, filename string, d *client.Download, c *byteRange, size int64, out *os.File, sched *chunkScheduler, progress *ProgressReader, bucket *tokenBucket, opts getOptions) error {
+	if d == nil {
+		pos, end := sched.span(c)
+		var err error
+		d, err = client.New(conn).Open(ctx, filename, client.GetOptions{Offset: pos, Length: end - pos})
+		if err != nil {
+			return err
+		}
+		if d.Size != size {
+			d.Close()
+			return fmt.Errorf("下載期間遠端檔案大小改變 (%d -> %d)", size, d.Size)
+		}
+	}
+	defer d.Close()
+	stop := context.AfterFunc(ctx, d.Cancel)
+	defer stop()
+
+	prioritized := NewPrioritizedReader(opts.stats.Track(int64(d.StreamID()), filename, d), opts.priority, streamPriorities)
+	defer prioritized.Close()
+	reader := bucket.Reader(prioritized)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := reader.Read(buf)
+		for p := buf[:n]; len(p) > 0; {
+			off, m := sched.claim(c, len(p))
+			if m == 0 {
+				// 伺服器多送的部分屬於其他區段，已由別的連線負責
+				return nil
+			}
+			if _, err := out.WriteAt(p[:m], off); err != nil {
+				return err
+			}
+			sched.wrote(c, p[:m])
+			progress.readBytes.Add(int64(m))
+			p = p[m:]
+		}
+		pos, end := sched.span(c)
+		if pos >= end {
+			return nil
+		}
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("區段提早結束（位置 %d）", pos)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
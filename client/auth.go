@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/quic-go/quic-go"
+)
+
+// ErrUnauthorized 表示伺服器要求認證，或拒絕了提供的 token。可以用 errors.Is 與 *ServerError 比對。
+var ErrUnauthorized = errors.New("伺服器拒絕認證")
+
+var (
+	tokenMu sync.Mutex
+	tokens  = make(map[*quic.Conn]string)
+)
+
+// Authenticate 以 bearer token 向伺服器認證這條連線。data-transfer 協定在新的 stream 上送出
+// `auth <token>`，伺服器回覆 OK 後同一條連線上的請求都視為已認證；token 無效時回傳
+// Code 為 StatusUnauthorized 的 *ServerError。HTTP/3 模式沒有連線層級的認證，
+// token 改為之後每個請求的 Authorization 標頭。
+func (c *Client) Authenticate(ctx context.Context, token string) error {
+	if token == "" || strings.ContainsAny(token, " \t\r\n") {
+		return errors.New("token 不能是空的，也不能包含空白或換行")
+	}
+	if h3Conn(c.conn) != nil {
+		tokenMu.Lock()
+		defer tokenMu.Unlock()
+		if _, ok := tokens[c.conn]; !ok {
+			context.AfterFunc(c.conn.Context(), func() {
+				tokenMu.Lock()
+				delete(tokens, c.conn)
+				tokenMu.Unlock()
+			})
+		}
+		tokens[c.conn] = token
+		return nil
+	}
+	_, err := c.Request(ctx, "auth "+token)
+	return err
+}
+
+// setAuthorization 在 HTTP/3 請求加上 Authenticate 設定的 token。
+func (c *Client) setAuthorization(req *http.Request) {
+	tokenMu.Lock()
+	token, ok := tokens[c.conn]
+	tokenMu.Unlock()
+	if ok {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
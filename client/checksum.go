@@ -0,0 +1,68 @@
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"slices"
+	"strings"
+)
+
+// checksumAlgorithms 是可用來驗證下載內容的雜湊演算法。
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// ChecksumAlgorithms 回傳支援的演算法名稱。
+func ChecksumAlgorithms() []string {
+	names := make([]string, 0, len(checksumAlgorithms))
+	for name := range checksumAlgorithms {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Checksum 是伺服器隨回應標頭送出的完整檔案雜湊。
+type Checksum struct {
+	Algorithm string
+	Sum       string // 小寫十六進位
+}
+
+func parseChecksum(alg, sum string) (*Checksum, error) {
+	newHash, ok := checksumAlgorithms[alg]
+	if !ok {
+		return nil, fmt.Errorf("不支援的雜湊演算法 %q", alg)
+	}
+	raw, err := hex.DecodeString(sum)
+	if err != nil || len(raw) != newHash().Size() {
+		return nil, fmt.Errorf("無效的 %s 雜湊 %q", alg, Snippet(sum))
+	}
+	return &Checksum{Algorithm: alg, Sum: strings.ToLower(sum)}, nil
+}
+
+// New 建立對應演算法的 hash.Hash。
+func (c *Checksum) New() hash.Hash {
+	return checksumAlgorithms[c.Algorithm]()
+}
+
+// Verify 比對 h 算出的雜湊，不符時回傳 *ChecksumError。
+func (c *Checksum) Verify(h hash.Hash) error {
+	if got := hex.EncodeToString(h.Sum(nil)); got != c.Sum {
+		return &ChecksumError{Algorithm: c.Algorithm, Want: c.Sum, Got: got}
+	}
+	return nil
+}
+
+// ChecksumError 表示下載內容與伺服器提供的雜湊不符。
+type ChecksumError struct {
+	Algorithm string
+	Want, Got string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s 校驗失敗: 伺服器為 %s，收到的內容為 %s", e.Algorithm, e.Want, e.Got)
+}
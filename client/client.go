@@ -0,0 +1,94 @@
+// Package client 實作 data-transfer 協定的用戶端，可嵌入其他 Go 程式；
+// data_cli 命令列工具建立在它之上。
+//
+// 每個請求使用一個新的 QUIC stream：先送出一行指令，再讀取伺服器的回應。
+// 連線協商的 ALPN 是 h3 時，下載與上傳改以 HTTP/3 的 GET 與 PUT 進行，其他操作回傳 ErrHTTP3Unsupported。
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"github.com/quic-go/quic-go"
+)
+
+// ALPN 是協定在 TLS 交握中使用的應用層協定名稱。
+const ALPN = "data-transfer"
+
+// Client 是連到一個伺服器的 QUIC 連線。多個 goroutine 可同時使用。
+type Client struct {
+	conn *quic.Conn
+}
+
+// New 以既有的連線建立 Client。
+func New(conn *quic.Conn) *Client {
+	return &Client{conn: conn}
+}
+
+// Dial 連線到 addr。tlsConf 的 NextProtos 為空時使用 DefaultALPN。
+func Dial(ctx context.Context, addr string, tlsConf *tls.Config, conf *quic.Config) (*Client, error) {
+	if tlsConf == nil {
+		tlsConf = &tls.Config{}
+	}
+	if len(tlsConf.NextProtos) == 0 {
+		tlsConf = tlsConf.Clone()
+		tlsConf.NextProtos = DefaultALPN
+	}
+	conn, err := quic.DialAddr(ctx, addr, tlsConf, conf)
+	if err != nil {
+		return nil, err
+	}
+	return New(conn), nil
+}
+
+// Conn 回傳底層的 QUIC 連線。
+func (c *Client) Conn() *quic.Conn {
+	return c.conn
+}
+
+// Close 關閉連線。
+func (c *Client) Close() error {
+	return c.conn.CloseWithError(0, "")
+}
+
+// rejected0RTT 回報 err 是否因伺服器拒絕 0-RTT 而失敗；是的話等交握完成，之後可以重送請求。
+// 以 quic.DialAddrEarly 建立的連線在交握完成前送出的 stream 都會以 quic.Err0RTTRejected 失敗。
+func (c *Client) rejected0RTT(ctx context.Context, err error) bool {
+	if !errors.Is(err, quic.Err0RTTRejected) {
+		return false
+	}
+	_, err = c.conn.NextConnection(ctx)
+	return err == nil
+}
+
+// Request 在新的 stream 上送出一行指令並讀取一行回應；回應以 ERR 開頭時回傳 *ServerError。
+func (c *Client) Request(ctx context.Context, line string) (string, error) {
+	reply, err := c.request(ctx, line)
+	if c.rejected0RTT(ctx, err) {
+		return c.request(ctx, line)
+	}
+	return reply, err
+}
+
+func (c *Client) request(ctx context.Context, line string) (string, error) {
+	if h3Conn(c.conn) != nil {
+		return "", ErrHTTP3Unsupported
+	}
+	stream, err := c.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+	fmt.Fprintln(stream, line)
+	reply, err := ReadHeaderLine(bufio.NewReader(stream))
+	if err != nil {
+		return "", fmt.Errorf("無法讀取回應: %w", err)
+	}
+	if err := CheckServerError(reply); err != nil {
+		return "", err
+	}
+	return reply, nil
+}
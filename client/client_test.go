@@ -0,0 +1,320 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-client/client"
+	"go-client/testserver"
+)
+
+func dial(t *testing.T, opts testserver.Options) (*testserver.Server, *client.Client) {
+	t.Helper()
+	srv, err := testserver.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	c, err := srv.Dial(testContext(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return srv, c
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func writeFile(t *testing.T, srv *testserver.Server, name string, size int) []byte {
+	t.Helper()
+	data := make([]byte, size)
+	rand.Read(data)
+	p := filepath.Join(srv.Root, filepath.FromSlash(name))
+	os.MkdirAll(filepath.Dir(p), 0o755)
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestList(t *testing.T) {
+	srv, c := dial(t, testserver.Options{})
+	writeFile(t, srv, "a.bin", 10)
+	writeFile(t, srv, "sub/b.bin", 20)
+	ctx := testContext(t)
+
+	entries, err := c.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name != "a.bin" || entries[0].Size != 10 || entries[0].Type != "file" ||
+		entries[1].Name != "sub" || entries[1].Type != "dir" {
+		t.Fatalf("List = %+v", entries)
+	}
+	if perm, ok := entries[0].Perm(); !ok || perm != 0o644 {
+		t.Errorf("Perm = %v, %v", perm, ok)
+	}
+
+	var names []string
+	err = c.ListFunc(ctx, "sub", false, func(e client.Entry) error {
+		names = append(names, e.Name)
+		return nil
+	})
+	if err != nil || len(names) != 1 || names[0] != "b.bin" {
+		t.Errorf("ListFunc(sub) = %q, %v", names, err)
+	}
+
+	if _, err := c.List(ctx, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("List(missing): err = %v, want 404", err)
+	}
+}
+
+func TestListStop(t *testing.T) {
+	srv, c := dial(t, testserver.Options{PageSize: 2})
+	for _, n := range []string{"a", "b", "c", "d"} {
+		writeFile(t, srv, n, 1)
+	}
+	stop := errors.New("stop")
+	var n int
+	err := c.ListFunc(testContext(t), "", true, func(client.Entry) error {
+		if n++; n == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 3 {
+		t.Errorf("ListFunc: err = %v after %d entries", err, n)
+	}
+}
+
+func TestGet(t *testing.T) {
+	srv, c := dial(t, testserver.Options{})
+	data := writeFile(t, srv, "dir/file name.bin", 200_000)
+	ctx := testContext(t)
+
+	var buf bytes.Buffer
+	n, err := c.Get(ctx, "dir/file name.bin", &buf, client.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("Get returned %d bytes, want %d", n, len(data))
+	}
+
+	// 部分下載不驗證完整檔案的雜湊
+	buf.Reset()
+	if _, err := c.Get(ctx, "dir/file name.bin", &buf, client.GetOptions{Offset: 1000, Length: 500}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data[1000:1500]) {
+		t.Error("ranged Get returned the wrong bytes")
+	}
+
+	if _, err := c.Get(ctx, "missing", io.Discard, client.GetOptions{}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Get(missing): err = %v, want 404", err)
+	}
+	if _, err := c.Get(ctx, "dir/file name.bin", io.Discard, client.GetOptions{Checksum: "md5"}); err == nil {
+		t.Error("Get accepted an unsupported checksum algorithm")
+	}
+}
+
+func TestGetChecksumMismatch(t *testing.T) {
+	srv, c := dial(t, testserver.Options{Corrupt: true})
+	writeFile(t, srv, "a.bin", 50_000)
+	ctx := testContext(t)
+
+	_, err := c.Get(ctx, "a.bin", io.Discard, client.GetOptions{Checksum: "sha512"})
+	var ce *client.ChecksumError
+	if !errors.As(err, &ce) {
+		t.Fatalf("err = %v, want *client.ChecksumError", err)
+	}
+	if ce.Algorithm != "sha512" || ce.Want == ce.Got {
+		t.Errorf("ChecksumError = %+v", ce)
+	}
+	if _, err := c.Get(ctx, "a.bin", io.Discard, client.GetOptions{NoVerify: true}); err != nil {
+		t.Errorf("NoVerify: %v", err)
+	}
+}
+
+func TestGetShortRead(t *testing.T) {
+	srv, c := dial(t, testserver.Options{Missing: 1000})
+	writeFile(t, srv, "a.bin", 5000)
+
+	n, err := c.Get(testContext(t), "a.bin", io.Discard, client.GetOptions{})
+	if err == nil || !strings.Contains(err.Error(), "5000/6000") {
+		t.Errorf("err = %v, want a short-read error", err)
+	}
+	if n != 5000 {
+		t.Errorf("n = %d, want 5000", n)
+	}
+}
+
+func TestGetMultiTerabyte(t *testing.T) {
+	// 宣告 4 TiB 但只送 5000 bytes：Open 不依宣告的大小配置記憶體，Get 在內容結束時回報不足
+	srv, c := dial(t, testserver.Options{Missing: 4 << 40})
+	writeFile(t, srv, "huge.bin", 5000)
+	ctx := testContext(t)
+
+	d, err := c.Open(ctx, "huge.bin", client.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+	if d.Size != 4<<40+5000 {
+		t.Errorf("Size = %d", d.Size)
+	}
+	n, err := c.Get(ctx, "huge.bin", io.Discard, client.GetOptions{Offset: 1000, Length: 3 << 40})
+	if err == nil || n != 4000 {
+		t.Errorf("ranged Get = %d, %v; want 4000 bytes and a short-read error", n, err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	srv, c := dial(t, testserver.Options{})
+	data := writeFile(t, srv, "a.bin", 100_000)
+	mtime := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(filepath.Join(srv.Root, "a.bin"), mtime, mtime)
+
+	d, err := c.Open(testContext(t), "a.bin", client.GetOptions{Offset: 40_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if d.Size != int64(len(data)) || d.Checksum == nil || d.Checksum.Algorithm != "sha256" || d.Codec != "" {
+		t.Errorf("Download = size %d checksum %+v codec %q", d.Size, d.Checksum, d.Codec)
+	}
+	if !d.Mtime.Equal(mtime) || d.Mode != 0o644 {
+		t.Errorf("FileMeta = %+v", d.FileMeta)
+	}
+	got, err := io.ReadAll(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data[40_000:]) {
+		t.Errorf("read %d bytes from offset 40000, want %d", len(got), len(data)-40_000)
+	}
+	if d.WireBytes() < int64(len(got)) {
+		t.Errorf("WireBytes = %d, less than the %d bytes read", d.WireBytes(), len(got))
+	}
+}
+
+func TestOpenCancel(t *testing.T) {
+	srv, c := dial(t, testserver.Options{})
+	writeFile(t, srv, "a.bin", 4<<20)
+	d, err := c.Open(testContext(t), "a.bin", client.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.Cancel()
+	if _, err := io.Copy(io.Discard, d); err == nil {
+		t.Error("reading a canceled download succeeded")
+	}
+	// 連線不受影響
+	if _, err := c.Stat(testContext(t), "a.bin"); err != nil {
+		t.Errorf("Stat after Cancel: %v", err)
+	}
+}
+
+func TestPut(t *testing.T) {
+	srv, c := dial(t, testserver.Options{})
+	ctx := testContext(t)
+	data := make([]byte, 300_000)
+	rand.Read(data)
+
+	n, err := c.Put(ctx, "up/new file.bin", bytes.NewReader(data), client.PutOptions{Meta: client.FileMeta{Mode: 0o640}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("n = %d, want %d", n, len(data))
+	}
+	p := filepath.Join(srv.Root, "up", "new file.bin")
+	if got, _ := os.ReadFile(p); !bytes.Equal(got, data) {
+		t.Errorf("server has %d bytes, want %d", len(got), len(data))
+	}
+	if info, _ := os.Stat(p); info.Mode().Perm() != 0o640 {
+		t.Errorf("mode = %v, want 0640", info.Mode().Perm())
+	}
+
+	// 伺服器的雜湊與上傳的內容一致
+	sum, err := c.Hash(ctx, "up/new file.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sum.New()
+	h.Write(data)
+	if err := sum.Verify(h); err != nil {
+		t.Error(err)
+	}
+
+	// 從 *os.File 取得大小
+	local := filepath.Join(t.TempDir(), "f")
+	os.WriteFile(local, data[:1234], 0o644)
+	f, _ := os.Open(local)
+	defer f.Close()
+	if n, err := c.Put(ctx, "from-file.bin", f, client.PutOptions{}); err != nil || n != 1234 {
+		t.Errorf("Put(*os.File) = %d, %v", n, err)
+	}
+	if _, err := c.Put(ctx, "unknown-size.bin", io.LimitReader(bytes.NewReader(data), 10), client.PutOptions{}); err == nil {
+		t.Error("Put without a size succeeded")
+	}
+}
+
+func TestUploadShort(t *testing.T) {
+	srv, c := dial(t, testserver.Options{})
+	u, err := c.OpenUpload(testContext(t), "short.bin", client.PutOptions{Size: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Write(make([]byte, 101)); err == nil {
+		t.Error("Write past the declared size succeeded")
+	}
+	u.Write(make([]byte, 60))
+	if err := u.Close(); err == nil || !strings.Contains(err.Error(), "60/100") {
+		t.Errorf("Close: err = %v, want an incomplete-upload error", err)
+	}
+	if _, err := os.Stat(filepath.Join(srv.Root, "short.bin")); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("incomplete upload left a file on the server")
+	}
+}
+
+func TestEncodePath(t *testing.T) {
+	for _, proto := range []string{client.ALPNv2, "data-transfer"} {
+		t.Run(proto, func(t *testing.T) {
+			srv, err := testserver.New(testserver.Options{Protocols: []string{proto}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer srv.Close()
+			c, err := srv.Dial(testContext(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			writeFile(t, srv, "100%.bin", 10)
+
+			_, err = c.Get(testContext(t), "100%.bin", io.Discard, client.GetOptions{})
+			if c.Version() >= 2 && err != nil {
+				t.Errorf("v%d: %v", c.Version(), err)
+			}
+			_, err = c.Put(testContext(t), "with space", bytes.NewReader([]byte("x")), client.PutOptions{})
+			if (err == nil) != (c.Version() >= 2) {
+				t.Errorf("v%d: put with a space: err = %v", c.Version(), err)
+			}
+		})
+	}
+}
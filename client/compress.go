@@ -0,0 +1,67 @@
+package client
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// decompress 依伺服器回應的 codec 解壓縮 r。
+func decompress(codec string, r io.Reader) (io.ReadCloser, error) {
+	switch codec {
+	case "gzip":
+		return gzip.NewReader(r)
+	case "zstd":
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("伺服器使用了不支援的壓縮方式 %q", codec)
+}
+
+// compressor 以 codec 壓縮寫入 w 的內容；level 為 0 時使用 codec 的預設等級。
+func compressor(codec string, level int, w io.Writer) (io.WriteCloser, error) {
+	switch codec {
+	case "gzip":
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case "zstd":
+		var opts []zstd.EOption
+		if level != 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, opts...)
+	}
+	return nil, fmt.Errorf("不支援的壓縮方式 %q", codec)
+}
+
+// countingReader 計算從 stream 讀到的原始位元組數。
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n.Add(int64(n))
+	return n, err
+}
+
+// countingWriter 計算寫入 stream 的原始位元組數。
+type countingWriter struct {
+	w io.Writer
+	n atomic.Int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// controlResend 是重送控制狀態的間隔。datagram 不保證送達，暫停與限速的狀態會定期重送，
+// 伺服器收到重複的訊息不影響結果。
+const controlResend = time.Second
+
+// Control 是以 QUIC DATAGRAM（RFC 9221）傳送的控制通道，不必在資料 stream 中夾帶控制訊息。
+// 每個 datagram 是一行文字 `<verb> <stream-id> [args...]`：用戶端送出 pause、resume 與
+// limit <bytes/s>（0 表示不限速），要求伺服器暫停、繼續或降速傳送某個 stream；
+// 伺服器推送 status <sent> 回報已送出的位元組數，以及 abort <code> <message> 中止下載。
+type Control struct {
+	conn *quic.Conn
+
+	mu      sync.Mutex
+	streams map[quic.StreamID]*controlState
+}
+
+type controlState struct {
+	handle  func(ControlMessage)
+	changed bool // 曾經暫停或限速，需要定期重送
+	paused  bool
+	limit   int64
+}
+
+// ControlMessage 是伺服器推送的控制訊息。
+type ControlMessage struct {
+	StreamID quic.StreamID
+	Verb     string
+	Args     []string
+}
+
+var controls sync.Map // *quic.Conn -> *Control
+
+// ControlFor 回傳 conn 的控制通道；連線沒有協商 datagram 或使用 HTTP/3 時回傳 nil。
+// 每條連線只建立一次，之後連線上收到的 datagram 都由控制通道處理。
+func ControlFor(conn *quic.Conn) *Control {
+	if conn == nil || !conn.ConnectionState().SupportsDatagrams || h3Conn(conn) != nil {
+		return nil
+	}
+	if c, ok := controls.Load(conn); ok {
+		return c.(*Control)
+	}
+	c := &Control{conn: conn, streams: make(map[quic.StreamID]*controlState)}
+	if actual, loaded := controls.LoadOrStore(conn, c); loaded {
+		return actual.(*Control)
+	}
+	go c.receive()
+	go c.resend()
+	context.AfterFunc(conn.Context(), func() { controls.Delete(conn) })
+	return c
+}
+
+func (c *Control) receive() {
+	for {
+		data, err := c.conn.ReceiveDatagram(c.conn.Context())
+		if err != nil {
+			return
+		}
+		f := strings.Fields(string(data))
+		if len(f) < 2 {
+			continue
+		}
+		id, err := strconv.ParseInt(f[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		c.mu.Lock()
+		st := c.streams[quic.StreamID(id)]
+		c.mu.Unlock()
+		if st != nil && st.handle != nil {
+			st.handle(ControlMessage{StreamID: quic.StreamID(id), Verb: f[0], Args: f[2:]})
+		}
+	}
+}
+
+func (c *Control) resend() {
+	ticker := time.NewTicker(controlResend)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.conn.Context().Done():
+			return
+		case <-ticker.C:
+		}
+		c.mu.Lock()
+		for id, st := range c.streams {
+			if st.changed {
+				c.send(id, st)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// send 送出 id 目前的狀態；呼叫時必須持有 c.mu。
+func (c *Control) send(id quic.StreamID, st *controlState) {
+	verb := "resume"
+	if st.paused {
+		verb = "pause"
+	}
+	c.conn.SendDatagram(fmt.Appendf(nil, "%s %d", verb, id))
+	c.conn.SendDatagram(fmt.Appendf(nil, "limit %d %d", id, st.limit))
+}
+
+// Watch 把伺服器對 stream id 推送的訊息交給 handle（在接收 goroutine 中呼叫），
+// 回傳的函式取消註冊並停止重送這個 stream 的狀態。
+func (c *Control) Watch(id quic.StreamID, handle func(ControlMessage)) func() {
+	c.mu.Lock()
+	c.state(id).handle = handle
+	c.mu.Unlock()
+	return func() {
+		c.mu.Lock()
+		delete(c.streams, id)
+		c.mu.Unlock()
+	}
+}
+
+func (c *Control) state(id quic.StreamID) *controlState {
+	st := c.streams[id]
+	if st == nil {
+		st = &controlState{}
+		c.streams[id] = st
+	}
+	return st
+}
+
+// SetPaused 要求伺服器暫停或繼續傳送 stream id。
+func (c *Control) SetPaused(id quic.StreamID, paused bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.state(id)
+	st.paused, st.changed = paused, true
+	c.send(id, st)
+}
+
+// SetLimit 要求伺服器以不超過 limit bytes/s 的速度傳送 stream id，0 表示不限速。
+func (c *Control) SetLimit(id quic.StreamID, limit int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.state(id)
+	st.limit, st.changed = limit, true
+	c.send(id, st)
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// command 送出會修改伺服器狀態的指令，伺服器必須回應以 OK 開頭的一行，否則視為失敗。
+func (c *Client) command(ctx context.Context, line string) error {
+	reply, err := c.Request(ctx, line)
+	if err != nil {
+		return err
+	}
+	if reply != "OK" && !strings.HasPrefix(reply, "OK ") {
+		return fmt.Errorf("非預期的回應 %q", Snippet(reply))
+	}
+	return nil
+}
+
+// Remove 送出 `rm <path>`；伺服器把檔案移到垃圾桶。
+func (c *Client) Remove(ctx context.Context, path string) error {
+	if err := c.CheckPath(path); err != nil {
+		return err
+	}
+	return c.command(ctx, "rm "+c.EncodePath(path))
+}
+
+// Mkdir 送出 `mkdir [-p] <dir>`；parents 為 true 時一併建立上層目錄，目錄已存在也不算錯誤。
+func (c *Client) Mkdir(ctx context.Context, dir string, parents bool) error {
+	if err := c.CheckPath(dir); err != nil {
+		return err
+	}
+	if parents {
+		return c.command(ctx, "mkdir -p "+c.EncodePath(dir))
+	}
+	return c.command(ctx, "mkdir "+c.EncodePath(dir))
+}
+
+// Rename 送出 `mv <from> <to>`。
+func (c *Client) Rename(ctx context.Context, from, to string) error {
+	if err := c.CheckPath(from); err != nil {
+		return err
+	}
+	if err := c.CheckPath(to); err != nil {
+		return err
+	}
+	return c.command(ctx, "mv "+c.EncodePath(from)+" "+c.EncodePath(to))
+}
+
+// Stat 送出 `stat <path>`，回應與 `ls -l` 的一行相同，是一個 JSON 物件。
+func (c *Client) Stat(ctx context.Context, path string) (Entry, error) {
+	if err := c.CheckPath(path); err != nil {
+		return Entry{}, err
+	}
+	reply, err := c.Request(ctx, "stat "+c.EncodePath(path))
+	if err != nil {
+		return Entry{}, err
+	}
+	var e Entry
+	if err := json.Unmarshal([]byte(reply), &e); err != nil {
+		return Entry{}, fmt.Errorf("無效的 stat 回應 %q", Snippet(reply))
+	}
+	return e, nil
+}
+
+// Hash 送出 `hash <path>`，回傳伺服器算出的檔案 SHA-256，不必下載內容。
+func (c *Client) Hash(ctx context.Context, path string) (*Checksum, error) {
+	if err := c.CheckPath(path); err != nil {
+		return nil, err
+	}
+	reply, err := c.Request(ctx, "hash "+c.EncodePath(path))
+	if err != nil {
+		return nil, err
+	}
+	return parseChecksum("sha256", strings.TrimSpace(reply))
+}
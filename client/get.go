@@ -0,0 +1,224 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/quic-go/quic-go"
+)
+
+// GetOptions 是下載的參數，零值表示從頭下載整個檔案、不壓縮。
+type GetOptions struct {
+	Offset      int64  // 從此位置開始
+	Length      int64  // 只下載這麼多位元組，0 表示到檔尾
+	Compression string // 要求伺服器以 gzip 或 zstd 壓縮傳輸
+	Level       int    // 壓縮等級，0 表示 codec 的預設值
+	Checksum    string // 要求伺服器以此演算法提供雜湊，空字串表示伺服器預設（sha256）
+	NoVerify    bool   // Get 不驗證伺服器提供的雜湊
+}
+
+// requestLine 組出 `get <name> [offset] [compress=<codec>:<level>] [len=<n>] [sum=<algorithm>]`。
+func (o GetOptions) requestLine(name string) string {
+	req := "get " + name
+	if o.Offset > 0 || o.Compression != "" || o.Length > 0 || o.Checksum != "" {
+		req += fmt.Sprintf(" %d", o.Offset)
+	}
+	if o.Compression != "" {
+		req += fmt.Sprintf(" compress=%s:%d", o.Compression, o.Level)
+	}
+	if o.Length > 0 {
+		req += fmt.Sprintf(" len=%d", o.Length)
+	}
+	if o.Checksum != "" {
+		req += " sum=" + o.Checksum
+	}
+	return req
+}
+
+// Download 是進行中的下載，讀到的是解壓縮後的檔案內容。
+// 不認得 len 的伺服器會送到檔尾，呼叫端需要自行限制讀取長度。
+type Download struct {
+	Size  int64  // 遠端檔案的完整大小（未壓縮）
+	Codec string // 伺服器實際使用的壓縮方式，空字串表示未壓縮
+	// Checksum 是伺服器提供的完整檔案雜湊，nil 表示伺服器沒有提供
+	Checksum *Checksum
+	FileMeta // 遠端檔案的修改時間與權限，伺服器沒有提供時為零值
+
+	stream stream
+	wire   *countingReader
+	r      io.Reader
+	dec    io.ReadCloser
+
+	ctrl    *Control // 連線協商了 datagram 時的控制通道
+	unwatch func()
+	aborted atomic.Pointer[ServerError]
+	sent    atomic.Int64 // 伺服器以 status 回報已送出的位元組數
+}
+
+// Open 送出 get 請求並讀取回應標頭，之後從回傳的 Download 讀取內容。
+func (c *Client) Open(ctx context.Context, name string, opts GetOptions) (*Download, error) {
+	if _, ok := checksumAlgorithms[opts.Checksum]; opts.Checksum != "" && !ok {
+		return nil, fmt.Errorf("不支援的雜湊演算法 %q", opts.Checksum)
+	}
+	d, err := c.open(ctx, name, opts)
+	if c.rejected0RTT(ctx, err) {
+		return c.open(ctx, name, opts)
+	}
+	return d, err
+}
+
+func (c *Client) open(ctx context.Context, name string, opts GetOptions) (*Download, error) {
+	if cc := h3Conn(c.conn); cc != nil {
+		return c.openHTTP3(ctx, cc, name, opts)
+	}
+	stream, err := c.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(stream, opts.requestLine(c.EncodePath(name)))
+
+	wire := &countingReader{r: stream}
+	r := bufio.NewReader(wire)
+	h, err := ReadTransferHeader(r)
+	if err != nil {
+		stream.CancelRead(0)
+		return nil, err
+	}
+	d := &Download{Size: h.Size, Codec: h.Codec, Checksum: h.Checksum, FileMeta: h.FileMeta, stream: stream, wire: wire, r: r}
+	if h.Codec != "" {
+		if d.dec, err = decompress(h.Codec, r); err != nil {
+			stream.CancelRead(0)
+			return nil, err
+		}
+		d.r = d.dec
+	}
+	if d.ctrl = ControlFor(c.conn); d.ctrl != nil {
+		d.unwatch = d.ctrl.Watch(stream.StreamID(), d.control)
+	}
+	return d, nil
+}
+
+// control 處理伺服器經由控制通道推送的訊息。
+func (d *Download) control(m ControlMessage) {
+	switch m.Verb {
+	case "status":
+		if len(m.Args) > 0 {
+			if n, err := strconv.ParseInt(m.Args[0], 10, 64); err == nil {
+				d.sent.Store(n)
+			}
+		}
+	case "abort":
+		e := &ServerError{Code: StatusInternal, Message: "伺服器中止傳送"}
+		if len(m.Args) > 0 {
+			if code, err := strconv.Atoi(m.Args[0]); err == nil {
+				e.Code = code
+			}
+		}
+		if len(m.Args) > 1 {
+			e.Message = strings.Join(m.Args[1:], " ")
+		}
+		d.aborted.Store(e)
+		d.stream.CancelRead(0)
+	}
+}
+
+func (d *Download) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if err != nil && err != io.EOF {
+		if e := d.aborted.Load(); e != nil {
+			return n, e
+		}
+	}
+	return n, err
+}
+
+// Pause 經由控制通道要求伺服器暫停或繼續傳送；連線沒有控制通道時回傳 false，
+// 呼叫端只能停止讀取，靠流量控制讓伺服器停下來。
+func (d *Download) Pause(paused bool) bool {
+	if d.ctrl == nil {
+		return false
+	}
+	d.ctrl.SetPaused(d.StreamID(), paused)
+	return true
+}
+
+// Throttle 經由控制通道要求伺服器以不超過 limit bytes/s 的速度傳送，0 表示不限速；
+// 連線沒有控制通道時回傳 false。
+func (d *Download) Throttle(limit int64) bool {
+	if d.ctrl == nil {
+		return false
+	}
+	d.ctrl.SetLimit(d.StreamID(), limit)
+	return true
+}
+
+// ServerSent 回傳伺服器經由控制通道回報已送出的位元組數，沒有回報時為 0。
+func (d *Download) ServerSent() int64 {
+	return d.sent.Load()
+}
+
+// WireBytes 回傳目前為止從 stream 收到的位元組數；有壓縮時即壓縮後的大小。
+func (d *Download) WireBytes() int64 {
+	return d.wire.n.Load()
+}
+
+// StreamID 回傳這次下載使用的 stream。
+func (d *Download) StreamID() quic.StreamID {
+	return d.stream.StreamID()
+}
+
+// Cancel 中止下載，伺服器會停止傳送；可與 Read 同時呼叫。
+func (d *Download) Cancel() {
+	d.stream.CancelRead(0)
+}
+
+// Close 釋放下載的資源，尚未讀完的內容會被捨棄。
+func (d *Download) Close() error {
+	if d.unwatch != nil {
+		d.unwatch()
+	}
+	if d.dec != nil {
+		d.dec.Close()
+	}
+	d.stream.CancelRead(0)
+	return d.stream.Close()
+}
+
+// Get 下載 name 並寫到 w，回傳寫入的位元組數。下載整個檔案且伺服器提供雜湊時，
+// 除非設定 NoVerify，否則邊寫邊驗證，不符時回傳 *ChecksumError。
+func (c *Client) Get(ctx context.Context, name string, w io.Writer, opts GetOptions) (int64, error) {
+	d, err := c.Open(ctx, name, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer d.Close()
+	stop := context.AfterFunc(ctx, d.Cancel)
+	defer stop()
+
+	want := d.Size - opts.Offset
+	if opts.Length > 0 {
+		want = min(want, opts.Length)
+	}
+	var h hash.Hash
+	if d.Checksum != nil && !opts.NoVerify && opts.Offset == 0 && opts.Length == 0 {
+		h = d.Checksum.New()
+		w = io.MultiWriter(w, h)
+	}
+	n, err := io.Copy(w, io.LimitReader(d, want))
+	if err != nil {
+		return n, err
+	}
+	if n != want {
+		return n, fmt.Errorf("下載提早結束 (%d/%d bytes)", n, want)
+	}
+	if h != nil {
+		return n, d.Checksum.Verify(h)
+	}
+	return n, nil
+}
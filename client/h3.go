@@ -0,0 +1,239 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// ALPNHTTP3 是 HTTP/3 的應用層協定名稱。有些網路只放行 h3，這時以一般的 HTTP/3 伺服器
+// 取代 data-transfer：下載是 GET（續傳與分段用 Range），上傳是 PUT。
+const ALPNHTTP3 = http3.NextProtoH3
+
+// ErrHTTP3Unsupported 表示這個操作只有 data-transfer 協定支援。
+var ErrHTTP3Unsupported = errors.New("HTTP/3 模式不支援此操作，只能使用 get 與 put")
+
+// stream 是 Download 與 Upload 底層的 bidirectional stream：*quic.Stream，或 HTTP/3 的 *http3.RequestStream。
+type stream interface {
+	io.ReadWriteCloser
+	StreamID() quic.StreamID
+	CancelRead(quic.StreamErrorCode)
+	CancelWrite(quic.StreamErrorCode)
+}
+
+var (
+	h3Mu    sync.Mutex
+	h3Conns = make(map[*quic.Conn]*http3.ClientConn)
+)
+
+// h3Conn 回傳 conn 上的 HTTP/3 用戶端，協商的 ALPN 不是 h3 時回傳 nil。
+// 同一條連線只能有一組 HTTP/3 控制 stream，因此每條連線共用一個 ClientConn。
+func h3Conn(conn *quic.Conn) *http3.ClientConn {
+	if conn.ConnectionState().TLS.NegotiatedProtocol != ALPNHTTP3 {
+		return nil
+	}
+	h3Mu.Lock()
+	defer h3Mu.Unlock()
+	cc, ok := h3Conns[conn]
+	if !ok {
+		cc = (&http3.Transport{}).NewClientConn(conn)
+		h3Conns[conn] = cc
+		context.AfterFunc(conn.Context(), func() {
+			h3Mu.Lock()
+			delete(h3Conns, conn)
+			h3Mu.Unlock()
+		})
+	}
+	return cc
+}
+
+// url 回傳遠端路徑 name 的 https URL，authority 取自 TLS 的伺服器名稱與連線的埠號。
+func (c *Client) url(name string) string {
+	host := c.conn.ConnectionState().TLS.ServerName
+	_, port, _ := net.SplitHostPort(c.conn.RemoteAddr().String())
+	if host == "" {
+		host, _, _ = net.SplitHostPort(c.conn.RemoteAddr().String())
+	}
+	u := url.URL{Scheme: "https", Host: net.JoinHostPort(host, port), Path: "/" + strings.TrimPrefix(name, "/")}
+	return u.String()
+}
+
+// httpError 把錯誤的 HTTP 狀態轉成 *ServerError，訊息取自回應內容的第一行。
+func httpError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, MaxHeaderLine))
+	msg, _, _ := strings.Cut(strings.TrimSpace(string(body)), "\n")
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+	return &ServerError{Code: resp.StatusCode, Message: msg}
+}
+
+// openHTTP3 以 GET 下載 name；Offset 與 Length 轉成 Range。HTTP/3 模式不要求壓縮，
+// 伺服器以 Repr-Digest 提供完整檔案的雜湊時用來驗證。
+func (c *Client) openHTTP3(ctx context.Context, cc *http3.ClientConn, name string, opts GetOptions) (*Download, error) {
+	str, err := cc.OpenRequestStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(name), nil)
+	if err != nil {
+		str.CancelWrite(0)
+		return nil, err
+	}
+	c.setAuthorization(req)
+	if opts.Offset > 0 || opts.Length > 0 {
+		end := ""
+		if opts.Length > 0 {
+			end = strconv.FormatInt(opts.Offset+opts.Length-1, 10)
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%s", opts.Offset, end))
+	}
+	// 明確指定 identity，http3 才不會自行要求 gzip，Content-Length 也才是檔案大小
+	req.Header.Set("Accept-Encoding", "identity")
+	alg := opts.Checksum
+	if alg == "" {
+		alg = "sha256"
+	}
+	req.Header.Set("Want-Repr-Digest", digestName(alg)+"=10")
+	if err := str.SendRequestHeader(req); err != nil {
+		str.CancelWrite(0)
+		return nil, err
+	}
+	str.Close()
+	resp, err := str.ReadResponse()
+	if err != nil {
+		return nil, err
+	}
+	d := &Download{stream: str, wire: &countingReader{r: str}}
+	d.r = d.wire
+	switch resp.StatusCode {
+	case http.StatusOK:
+		d.Size = resp.ContentLength
+		if opts.Offset > 0 {
+			// 伺服器不支援 Range，略過已經有的部分
+			if _, err := io.CopyN(io.Discard, d.r, opts.Offset); err != nil {
+				str.CancelRead(0)
+				return nil, err
+			}
+		}
+	case http.StatusPartialContent:
+		if d.Size, err = contentRangeSize(resp.Header.Get("Content-Range")); err != nil {
+			str.CancelRead(0)
+			return nil, err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// 續傳位置正好在檔尾
+		size, err := contentRangeSize(resp.Header.Get("Content-Range"))
+		str.CancelRead(0)
+		if err != nil || size != opts.Offset {
+			return nil, httpError(resp)
+		}
+		d.Size, d.r = size, strings.NewReader("")
+	default:
+		str.CancelRead(0)
+		return nil, httpError(resp)
+	}
+	if d.Size < 0 {
+		str.CancelRead(0)
+		return nil, errors.New("HTTP/3 回應沒有 Content-Length")
+	}
+	d.Checksum = parseReprDigest(resp.Header.Get("Repr-Digest"))
+	d.FileMeta = metaFromHeader(resp.Header)
+	return d, nil
+}
+
+// contentRangeSize 從 `bytes <first>-<last>/<size>` 或 `bytes */<size>` 取出完整大小。
+func contentRangeSize(v string) (int64, error) {
+	_, size, ok := strings.Cut(v, "/")
+	if !ok || !strings.HasPrefix(v, "bytes ") {
+		return 0, fmt.Errorf("無效的 Content-Range %q", Snippet(v))
+	}
+	n, err := ParseSize(size)
+	if err != nil {
+		return 0, fmt.Errorf("無效的 Content-Range %q", Snippet(v))
+	}
+	return n, nil
+}
+
+// digestName 把演算法名稱轉成 HTTP Digest Fields（RFC 9530）使用的名稱，例如 sha256 -> sha-256。
+func digestName(alg string) string {
+	return strings.Replace(alg, "sha", "sha-", 1)
+}
+
+// parseReprDigest 解析 `sha-256=:<base64>:, sha-512=:<base64>:`，回傳第一個支援的雜湊。
+func parseReprDigest(v string) *Checksum {
+	for _, item := range strings.Split(v, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(strings.Trim(value, ":"))
+		if err != nil {
+			continue
+		}
+		if c, err := parseChecksum(strings.Replace(name, "sha-", "sha", 1), hex.EncodeToString(raw)); err == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// openUploadHTTP3 以 PUT 上傳 name。壓縮時加上 Content-Encoding，此時不預先宣告長度。
+func (c *Client) openUploadHTTP3(ctx context.Context, cc *http3.ClientConn, name string, opts PutOptions) (*Upload, error) {
+	str, err := cc.OpenRequestStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 內容由 Upload.Write 直接寫入 request stream；http.NoBody 加上 ContentLength 讓 http3 送出 content-length
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.url(name), http.NoBody)
+	if err != nil {
+		str.CancelWrite(0)
+		return nil, err
+	}
+	c.setAuthorization(req)
+	req.ContentLength = opts.Size
+	opts.Meta.setHeader(req.Header)
+	if opts.Compression != "" {
+		req.ContentLength = -1
+		req.Header.Set("Content-Encoding", opts.Compression)
+	}
+	if err := str.SendRequestHeader(req); err != nil {
+		str.CancelWrite(0)
+		return nil, err
+	}
+	u := &Upload{stream: str, h3: str, wire: &countingWriter{w: str}, size: opts.Size}
+	u.w = u.wire
+	if opts.Compression != "" {
+		if u.enc, err = compressor(opts.Compression, opts.Level, u.wire); err != nil {
+			str.CancelWrite(0)
+			return nil, err
+		}
+		u.w, u.codec = u.enc, opts.Compression
+	}
+	return u, nil
+}
+
+// awaitHTTP3 等待 PUT 的回應，2xx 以外的狀態轉成 *ServerError。
+func (u *Upload) awaitHTTP3() error {
+	resp, err := u.h3.ReadResponse()
+	if err != nil {
+		return fmt.Errorf("無法讀取上傳結果: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return httpError(resp)
+	}
+	return nil
+}
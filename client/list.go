@@ -0,0 +1,108 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry 是遠端目錄中的一個項目。
+type Entry struct {
+	Name  string    `json:"name"`
+	Size  int64     `json:"size"`
+	Mtime time.Time `json:"mtime"`
+	Type  string    `json:"type"` // file | dir
+	Hash  string    `json:"hash,omitempty"`
+	Mode  string    `json:"mode,omitempty"` // 八進位的權限位元，例如 "0644"；舊伺服器不會送
+}
+
+// Perm 回傳項目的權限位元，伺服器沒有提供時 ok 為 false。
+func (e Entry) Perm() (perm os.FileMode, ok bool) {
+	mode, err := strconv.ParseUint(e.Mode, 8, 32)
+	if err != nil {
+		return 0, false
+	}
+	return os.FileMode(mode).Perm(), true
+}
+
+// List 列出遠端目錄 dir（空字串為根目錄）中的項目，包含大小與修改時間。
+func (c *Client) List(ctx context.Context, dir string) ([]Entry, error) {
+	var entries []Entry
+	err := c.ListFunc(ctx, dir, true, func(e Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	return entries, err
+}
+
+// ListFunc 送出 `ls [-l] [dir]` 並對每個項目呼叫 fn，fn 回傳錯誤時停止。
+// `ls -l` 的回應每行是一個 JSON 物件；不認得 -l 的舊伺服器只回傳名稱，此時只填入 Name 與 Type。
+//
+// 很大的目錄由伺服器分頁：一頁的最後一行是 `MORE <cursor>`，cursor 是不含空白的不透明字串，
+// 以 `ls [-l] cursor=<cursor> [dir]` 在新的 stream 上取得下一頁，直到沒有 MORE 為止。
+// 因此不論目錄多大，每次只需要處理一頁；不分頁的伺服器一次送完，行為與以前相同。
+func (c *Client) ListFunc(ctx context.Context, dir string, long bool, fn func(Entry) error) error {
+	var cursor string
+	for {
+		next, err := c.listPage(ctx, dir, long, cursor, fn)
+		if c.rejected0RTT(ctx, err) {
+			next, err = c.listPage(ctx, dir, long, cursor, fn)
+		}
+		if err != nil || next == "" {
+			return err
+		}
+		cursor = next
+	}
+}
+
+// listPage 列出從 cursor 開始的一頁，回傳下一頁的 cursor，沒有下一頁時為空字串。
+func (c *Client) listPage(ctx context.Context, dir string, long bool, cursor string, fn func(Entry) error) (string, error) {
+	if h3Conn(c.conn) != nil {
+		return "", ErrHTTP3Unsupported
+	}
+	stream, err := c.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+	defer stream.CancelRead(0)
+	req := "ls"
+	if long {
+		req += " -l"
+	}
+	if cursor != "" {
+		req += " cursor=" + cursor
+	}
+	if dir != "" {
+		req += " " + c.EncodePath(dir)
+	}
+	fmt.Fprintln(stream, req)
+
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if err := CheckServerError(line); err != nil {
+			return "", err
+		}
+		if next, ok := strings.CutPrefix(line, "MORE "); ok {
+			return next, nil
+		}
+		e := Entry{Name: line, Type: "file"}
+		if strings.HasPrefix(line, "{") {
+			if err := json.Unmarshal([]byte(line), &e); err != nil {
+				return "", fmt.Errorf("無效的列表項目 %q: %v", line, err)
+			}
+		} else if strings.HasSuffix(line, "/") {
+			e.Name, e.Type = strings.TrimSuffix(line, "/"), "dir"
+		}
+		if err := fn(e); err != nil {
+			return "", err
+		}
+	}
+	return "", scanner.Err()
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileMeta 是隨檔案傳送的中繼資料：修改時間與權限位元。
+// data-transfer 協定以 `mtime=<RFC3339>` 與 `mode=<八進位>` 欄位附在 get 的回應標頭及 put 的請求行後面；
+// HTTP/3 則使用 Last-Modified、X-File-Mtime 與 X-File-Mode。舊伺服器不會送，此時為零值。
+type FileMeta struct {
+	Mtime time.Time
+	Mode  os.FileMode // 只有權限位元，0 表示未提供
+}
+
+// parseField 解析一個 key=value 欄位，不是中繼資料時回傳 false。
+func (m *FileMeta) parseField(key, value string) bool {
+	switch key {
+	case "mtime":
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			m.Mtime = t
+		}
+	case "mode":
+		if mode, err := strconv.ParseUint(value, 8, 32); err == nil {
+			m.Mode = os.FileMode(mode).Perm()
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// ParseFileMeta 從回應標頭的欄位中取出中繼資料，其他欄位略過。
+func ParseFileMeta(fields []string) FileMeta {
+	var m FileMeta
+	for _, f := range fields {
+		if key, value, ok := strings.Cut(f, "="); ok {
+			m.parseField(key, value)
+		}
+	}
+	return m
+}
+
+// fields 回傳附在請求行後面的欄位（開頭有空白），沒有中繼資料時為空字串。
+func (m FileMeta) fields() string {
+	var s string
+	if !m.Mtime.IsZero() {
+		s += " mtime=" + m.Mtime.UTC().Format(time.RFC3339Nano)
+	}
+	if m.Mode != 0 {
+		s += fmt.Sprintf(" mode=%04o", m.Mode.Perm())
+	}
+	return s
+}
+
+// setHeader 把中繼資料加到 HTTP/3 的 PUT 請求。
+func (m FileMeta) setHeader(h http.Header) {
+	if !m.Mtime.IsZero() {
+		h.Set("X-File-Mtime", m.Mtime.UTC().Format(time.RFC3339Nano))
+	}
+	if m.Mode != 0 {
+		h.Set("X-File-Mode", fmt.Sprintf("%04o", m.Mode.Perm()))
+	}
+}
+
+// metaFromHeader 從 HTTP/3 的 GET 回應取出中繼資料；X-File-Mtime 的精度比 Last-Modified 高，優先使用。
+func metaFromHeader(h http.Header) FileMeta {
+	var m FileMeta
+	if t, err := http.ParseTime(h.Get("Last-Modified")); err == nil {
+		m.Mtime = t
+	}
+	if v := h.Get("X-File-Mtime"); v != "" {
+		m.parseField("mtime", v)
+	}
+	if v := h.Get("X-File-Mode"); v != "" {
+		m.parseField("mode", v)
+	}
+	return m
+}
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// MaxHeaderLine 是回應標頭行的長度上限，避免伺服器不送換行時無限制地緩衝。
+const MaxHeaderLine = 4096
+
+// ErrHeaderTooLong 表示回應標頭超過 MaxHeaderLine。
+var ErrHeaderTooLong = errors.New("回應標頭過長")
+
+// 伺服器以 `ERR <code> <message>` 回報錯誤，code 沿用 HTTP 狀態碼的意義。
+const (
+	StatusBadRequest   = 400
+	StatusUnauthorized = 401 // 沒有提供 token 或 token 無效
+	StatusForbidden    = 403
+	StatusNotFound     = 404
+	StatusConflict     = 409
+	StatusInternal     = 500
+	StatusInsufficient = 507 // 配額或磁碟空間不足
+)
+
+// ServerError 是伺服器以 ERR 開頭的回應。Code 為 0 表示伺服器沒有提供狀態碼，
+// 且無法從訊息判斷。可以用 errors.Is 與 fs.ErrNotExist、fs.ErrPermission、fs.ErrExist、ErrUnauthorized 比對。
+type ServerError struct {
+	Code    int
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	if e.Code == 0 {
+		return "伺服器錯誤: " + e.Message
+	}
+	return fmt.Sprintf("伺服器錯誤 %d: %s", e.Code, e.Message)
+}
+
+func (e *ServerError) Is(target error) bool {
+	switch target {
+	case fs.ErrNotExist:
+		return e.Code == StatusNotFound
+	case fs.ErrPermission:
+		return e.Code == StatusForbidden
+	case fs.ErrExist:
+		return e.Code == StatusConflict
+	case ErrUnauthorized:
+		return e.Code == StatusUnauthorized
+	}
+	return false
+}
+
+// guessStatus 從沒有狀態碼的舊伺服器的錯誤訊息推測狀態碼。
+func guessStatus(msg string) int {
+	msg = strings.ToLower(msg)
+	switch {
+	case strings.Contains(msg, "no such file"), strings.Contains(msg, "not found"), strings.Contains(msg, "not exist"):
+		return StatusNotFound
+	case strings.Contains(msg, "unauthorized"), strings.Contains(msg, "authentication"):
+		return StatusUnauthorized
+	case strings.Contains(msg, "permission denied"), strings.Contains(msg, "forbidden"):
+		return StatusForbidden
+	case strings.Contains(msg, "file exists"), strings.Contains(msg, "already exists"):
+		return StatusConflict
+	case strings.Contains(msg, "quota"), strings.Contains(msg, "no space"):
+		return StatusInsufficient
+	}
+	return 0
+}
+
+// maxSizeDigits 是大小欄位的最大位數，int64 最多 19 位。
+const maxSizeDigits = 19
+
+// ReadHeaderLine 讀取一行回應標頭（不含行尾），長度超過 MaxHeaderLine 即回傳錯誤。
+func ReadHeaderLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > MaxHeaderLine {
+			return "", ErrHeaderTooLong
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(line), "\r\n"), nil
+	}
+}
+
+// CheckServerError 在回應行以 ERR 開頭時回傳 *ServerError，`ERR <code> <message>` 的狀態碼填入 Code。
+func CheckServerError(line string) error {
+	rest, ok := strings.CutPrefix(line, "ERR")
+	if !ok {
+		return nil
+	}
+	msg := strings.TrimSpace(rest)
+	code, text, _ := strings.Cut(msg, " ")
+	if len(code) == 3 {
+		if n, err := strconv.Atoi(code); err == nil && n >= 400 && n < 600 {
+			return &ServerError{Code: n, Message: strings.TrimSpace(text)}
+		}
+	}
+	return &ServerError{Code: guessStatus(msg), Message: msg}
+}
+
+// ParseSize 嚴格解析大小欄位：只接受十進位數字，不允許符號、空白或其他字元。
+func ParseSize(line string) (int64, error) {
+	if err := CheckServerError(line); err != nil {
+		return 0, err
+	}
+	if line == "" || len(line) > maxSizeDigits {
+		return 0, fmt.Errorf("無效的大小標頭 %q", Snippet(line))
+	}
+	for i := 0; i < len(line); i++ {
+		if line[i] < '0' || line[i] > '9' {
+			return 0, fmt.Errorf("無效的大小標頭 %q", Snippet(line))
+		}
+	}
+	size, err := strconv.ParseInt(line, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("無效的大小標頭 %q", Snippet(line))
+	}
+	return size, nil
+}
+
+// ReadSizeHeader 讀取並解析 dgram 等指令回應的第一行大小標頭。
+func ReadSizeHeader(r *bufio.Reader) (int64, error) {
+	line, err := ReadHeaderLine(r)
+	if err != nil {
+		return 0, fmt.Errorf("無法讀取檔案大小: %w", err)
+	}
+	return ParseSize(line)
+}
+
+// TransferHeader 是 get 的回應標頭 "<size> [codec] [<algorithm>=<hex>] [mtime=<time>] [mode=<perm>]"。
+type TransferHeader struct {
+	Size     int64     // 未壓縮的大小
+	Codec    string    // 不為空時表示之後的資料以該方式壓縮
+	Checksum *Checksum // 伺服器提供的完整檔案雜湊，舊伺服器不會送
+	FileMeta
+}
+
+// ReadTransferHeader 讀取並解析 get 的回應標頭。
+func ReadTransferHeader(r *bufio.Reader) (TransferHeader, error) {
+	line, err := ReadHeaderLine(r)
+	if err != nil {
+		return TransferHeader{}, fmt.Errorf("無法讀取檔案大小: %w", err)
+	}
+	if err := CheckServerError(line); err != nil {
+		return TransferHeader{}, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return TransferHeader{}, fmt.Errorf("無效的大小標頭 %q", Snippet(line))
+	}
+	var h TransferHeader
+	if h.Size, err = ParseSize(fields[0]); err != nil {
+		return TransferHeader{}, err
+	}
+	for _, f := range fields[1:] {
+		alg, sum, ok := strings.Cut(f, "=")
+		if !ok {
+			h.Codec = f
+			continue
+		}
+		if h.parseField(alg, sum) {
+			continue
+		}
+		// 不認得的演算法略過，不影響下載
+		if c, err := parseChecksum(alg, sum); err == nil {
+			h.Checksum = c
+		}
+	}
+	return h, nil
+}
+
+// Snippet 截短要放進錯誤訊息的回應行，避免把大量垃圾資料印到終端機。
+func Snippet(line string) string {
+	if len(line) > 64 {
+		return line[:64] + "..."
+	}
+	return line
+}
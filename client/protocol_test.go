@@ -0,0 +1,129 @@
+package client_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go-client/client"
+)
+
+func TestParseSizeLarge(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"4398046511104", 4 << 40, true},
+		{"5497558138880", 5 << 40, true},
+		{"9223372036854775807", 1<<63 - 1, true},
+		{"0009223372036854775807", 0, false}, // 超過 19 位
+		{"9223372036854775808", 0, false},
+		{"99999999999999999999", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := client.ParseSize(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestReadTransferHeaderLarge(t *testing.T) {
+	const sum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	r := bufio.NewReader(strings.NewReader("5497558138880 zstd sha256=" + sum + " mode=0600\nbody"))
+	h, err := client.ReadTransferHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Size != 5<<40 || h.Codec != "zstd" || h.Checksum == nil || h.Checksum.Sum != sum || h.Mode != 0o600 {
+		t.Errorf("header = %+v", h)
+	}
+}
+
+func FuzzParseSize(f *testing.F) {
+	for _, s := range []string{"0", "1234", "9223372036854775807", "9223372036854775808", "-1", "+1", " 1", "1e3", "0x10", "", "ERR 404 missing"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		size, err := client.ParseSize(line)
+		if err != nil {
+			return
+		}
+		if size < 0 {
+			t.Fatalf("ParseSize(%q) = %d", line, size)
+		}
+		// 只接受十進位數字
+		if strings.TrimLeft(line, "0123456789") != "" {
+			t.Fatalf("ParseSize accepted %q", line)
+		}
+		if n, _ := strconv.ParseInt(line, 10, 64); n != size {
+			t.Fatalf("ParseSize(%q) = %d, want %d", line, size, n)
+		}
+	})
+}
+
+func FuzzReadTransferHeader(f *testing.F) {
+	for _, s := range []string{
+		"1234\nbody",
+		"1234 zstd\n",
+		"10 sha256=2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824 mtime=2024-01-02T03:04:05Z mode=0644\n",
+		"10 sha512=00 md5=abc\n",
+		"ERR 404 not found\n",
+		"-5\n",
+		"\n",
+		strings.Repeat("9", 5000) + "\n",
+	} {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bufio.NewReader(bytes.NewReader(data))
+		h, err := client.ReadTransferHeader(r)
+		if err != nil {
+			return
+		}
+		if h.Size < 0 {
+			t.Fatalf("negative size %d", h.Size)
+		}
+		if h.Checksum != nil {
+			if raw, err := hex.DecodeString(h.Checksum.Sum); err != nil || len(raw) != h.Checksum.New().Size() {
+				t.Fatalf("invalid checksum %+v", h.Checksum)
+			}
+		}
+		// 標頭之後的內容原封不動地留給呼叫端
+		rest, _ := io.ReadAll(r)
+		if i := bytes.IndexByte(data, '\n'); i < 0 || !bytes.Equal(rest, data[i+1:]) {
+			t.Fatalf("header consumed part of the body: %q", rest)
+		}
+	})
+}
+
+func FuzzCheckServerError(f *testing.F) {
+	for _, s := range []string{"ERR 404 no such file", "ERR 401", "ERR quota exceeded", "ERR 999 what", "ERR", "OK", "1234", "ERRATA"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		err := client.CheckServerError(line)
+		if !strings.HasPrefix(line, "ERR") {
+			if err != nil {
+				t.Fatalf("CheckServerError(%q) = %v", line, err)
+			}
+			return
+		}
+		var se *client.ServerError
+		if !errors.As(err, &se) {
+			t.Fatalf("CheckServerError(%q) = %v, want *ServerError", line, err)
+		}
+		if se.Code != 0 && (se.Code < 400 || se.Code >= 600) {
+			t.Fatalf("CheckServerError(%q): code %d", line, se.Code)
+		}
+		if se.Error() == "" {
+			t.Fatal("empty error message")
+		}
+	})
+}
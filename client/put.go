@@ -0,0 +1,174 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// PutOptions 是上傳的參數。
+type PutOptions struct {
+	// Size 是要上傳的位元組數。r 是 *os.File 或有 Len() 方法（如 *bytes.Reader）時可省略。
+	Size int64
+	// Compression 以 gzip 或 zstd 壓縮傳送的內容，由伺服器解壓縮後寫入；Level 為 0 表示預設等級。
+	Compression string
+	Level       int
+	// Meta 是要伺服器套用到檔案上的修改時間與權限，零值表示不送
+	Meta FileMeta
+}
+
+// Upload 是進行中的上傳：先寫入剛好 size 個位元組，再以 Close 等待伺服器確認。
+type Upload struct {
+	stream  stream
+	h3      *http3.RequestStream // HTTP/3 的 PUT，nil 表示 data-transfer 協定
+	wire    *countingWriter
+	w       io.Writer      // 未壓縮時即 wire
+	enc     io.WriteCloser // 壓縮時的編碼器
+	codec   string
+	size    int64 // -1 表示不限制（tar 串流）
+	written int64
+}
+
+// OpenUpload 送出 `put <name> <size> [compress=<codec>:<level>] [mtime=<time>] [mode=<perm>]`，
+// 之後寫入的內容即為檔案內容。
+// 壓縮時 size 仍是壓縮前的大小。
+func (c *Client) OpenUpload(ctx context.Context, name string, opts PutOptions) (*Upload, error) {
+	if cc := h3Conn(c.conn); cc != nil {
+		return c.openUploadHTTP3(ctx, cc, name, opts)
+	}
+	req := fmt.Sprintf("put %s %d", c.EncodePath(name), opts.Size)
+	if opts.Compression != "" {
+		req += fmt.Sprintf(" compress=%s:%d", opts.Compression, opts.Level)
+	}
+	req += opts.Meta.fields()
+	return c.openUpload(ctx, req, opts.Size, opts)
+}
+
+func (c *Client) openUpload(ctx context.Context, req string, size int64, opts PutOptions) (*Upload, error) {
+	stream, err := c.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintln(stream, req); err != nil {
+		stream.CancelWrite(0)
+		return nil, err
+	}
+	u := &Upload{stream: stream, wire: &countingWriter{w: stream}, size: size}
+	u.w = u.wire
+	if opts.Compression != "" {
+		if u.enc, err = compressor(opts.Compression, opts.Level, u.wire); err != nil {
+			stream.CancelWrite(0)
+			return nil, err
+		}
+		u.w, u.codec = u.enc, opts.Compression
+	}
+	return u, nil
+}
+
+func (u *Upload) Write(p []byte) (int, error) {
+	if u.size >= 0 && u.written+int64(len(p)) > u.size {
+		return 0, fmt.Errorf("寫入超過宣告的大小 %d", u.size)
+	}
+	n, err := u.w.Write(p)
+	u.written += int64(n)
+	return n, err
+}
+
+// WireBytes 回傳目前為止寫入 stream 的位元組數；有壓縮時即壓縮後的大小。
+func (u *Upload) WireBytes() int64 {
+	return u.wire.n.Load()
+}
+
+// StreamID 回傳這次上傳使用的 stream。
+func (u *Upload) StreamID() quic.StreamID {
+	return u.stream.StreamID()
+}
+
+// Cancel 中止上傳，伺服器會捨棄已收到的內容；可與 Write 同時呼叫。
+func (u *Upload) Cancel() {
+	u.stream.CancelWrite(0)
+	u.stream.CancelRead(0)
+}
+
+// Close 結束寫入並等待伺服器回應 OK。寫入的位元組數不足 size 時中止上傳。
+// 壓縮上傳時伺服器需回應 `OK <codec>`，否則表示它不認得 compress，把壓縮後的內容當成了檔案內容。
+func (u *Upload) Close() error {
+	if u.size >= 0 && u.written != u.size {
+		u.Cancel()
+		return fmt.Errorf("上傳內容不完整 (%d/%d bytes)", u.written, u.size)
+	}
+	if u.enc != nil {
+		if err := u.enc.Close(); err != nil {
+			u.Cancel()
+			return err
+		}
+	}
+	u.stream.Close()
+	if u.h3 != nil {
+		return u.awaitHTTP3()
+	}
+	reply, err := ReadHeaderLine(bufio.NewReader(u.stream))
+	if err != nil {
+		return fmt.Errorf("無法讀取上傳結果: %v", err)
+	}
+	if err := CheckServerError(reply); err != nil {
+		return err
+	}
+	if f := strings.Fields(reply); u.codec != "" && (len(f) < 2 || f[1] != u.codec) {
+		return fmt.Errorf("伺服器不支援壓縮上傳（回應 %q），請不使用 --compress 重新上傳", Snippet(reply))
+	}
+	return nil
+}
+
+// Put 把 r 的內容上傳為遠端的 name，回傳送出的位元組數。
+func (c *Client) Put(ctx context.Context, name string, r io.Reader, opts PutOptions) (int64, error) {
+	size, err := sizeOf(r, opts.Size)
+	if err != nil {
+		return 0, err
+	}
+	opts.Size = size
+	u, err := c.OpenUpload(ctx, name, opts)
+	if err != nil {
+		return 0, err
+	}
+	stop := context.AfterFunc(ctx, u.Cancel)
+	defer stop()
+	n, err := io.Copy(u, io.LimitReader(r, size))
+	if err != nil {
+		u.Cancel()
+		return n, err
+	}
+	return n, u.Close()
+}
+
+// sizeOf 決定上傳大小：有指定時使用 size，否則從檔案資訊或 Len() 取得。
+func sizeOf(r io.Reader, size int64) (int64, error) {
+	if size > 0 {
+		return size, nil
+	}
+	switch v := r.(type) {
+	case *os.File:
+		info, err := v.Stat()
+		if err != nil {
+			return 0, err
+		}
+		if !info.Mode().IsRegular() {
+			return 0, errors.New("無法取得上傳大小，請設定 PutOptions.Size")
+		}
+		off, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size() - off, nil
+	case interface{ Len() int }:
+		return int64(v.Len()), nil
+	}
+	return 0, errors.New("無法取得上傳大小，請設定 PutOptions.Size")
+}
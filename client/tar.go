@@ -0,0 +1,61 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// OpenTar 送出 `tar <dir> [compress=<codec>:<level>]`。伺服器先回應一行 `OK [codec]`，
+// 接著送出整個目錄的 tar 串流直到 stream 結束，省去逐一請求每個檔案的往返。
+// 回傳的 Download 讀到的是解壓縮後的 tar 內容，Size 為 -1。
+func (c *Client) OpenTar(ctx context.Context, dir string, opts GetOptions) (*Download, error) {
+	if h3Conn(c.conn) != nil {
+		return nil, ErrHTTP3Unsupported
+	}
+	stream, err := c.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req := "tar " + c.EncodePath(dir)
+	if opts.Compression != "" {
+		req += fmt.Sprintf(" compress=%s:%d", opts.Compression, opts.Level)
+	}
+	fmt.Fprintln(stream, req)
+
+	wire := &countingReader{r: stream}
+	r := bufio.NewReader(wire)
+	reply, err := ReadHeaderLine(r)
+	if err == nil {
+		err = CheckServerError(reply)
+	}
+	if err != nil {
+		stream.CancelRead(0)
+		return nil, err
+	}
+	fields := strings.Fields(reply)
+	if len(fields) == 0 || fields[0] != "OK" {
+		stream.CancelRead(0)
+		return nil, fmt.Errorf("無效的回應 %q", Snippet(reply))
+	}
+	d := &Download{Size: -1, stream: stream, wire: wire, r: r}
+	if len(fields) > 1 {
+		d.Codec = fields[1]
+		if d.dec, err = decompress(d.Codec, r); err != nil {
+			stream.CancelRead(0)
+			return nil, err
+		}
+		d.r = d.dec
+	}
+	return d, nil
+}
+
+// OpenTarUpload 送出 `untar <dir>`，之後寫入的 tar 串流由伺服器解開到 dir；
+// 沒有預先宣告的大小，Close 結束寫入後等待伺服器回應 OK。
+func (c *Client) OpenTarUpload(ctx context.Context, dir string) (*Upload, error) {
+	if h3Conn(c.conn) != nil {
+		return nil, ErrHTTP3Unsupported
+	}
+	return c.openUpload(ctx, "untar "+c.EncodePath(dir), -1, PutOptions{})
+}
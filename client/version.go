@@ -0,0 +1,66 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ALPNv2 是 data-transfer 協定的第 2 版：請求行中的路徑參數以百分比編碼，因此可以包含空白；
+// 伺服器的回應格式與第 1 版（ALPN 為 data-transfer）相同。
+const ALPNv2 = ALPN + "/2"
+
+// DefaultALPN 是預設宣告的應用層協定，依偏好排列。伺服器選擇它支援的最新版本，
+// 只認得 data-transfer 的舊伺服器仍然可以連線。
+var DefaultALPN = []string{ALPNv2, ALPN}
+
+// ProtocolVersion 由協商的 ALPN 判斷協定版本：名稱以 /<n> 結尾時為 n，
+// 其他名稱（包括 data-transfer 與自訂的名稱）為 1。
+func ProtocolVersion(proto string) int {
+	if i := strings.LastIndexByte(proto, '/'); i >= 0 {
+		if v, err := strconv.Atoi(proto[i+1:]); err == nil && v > 0 {
+			return v
+		}
+	}
+	return 1
+}
+
+// Version 回傳連線協商的協定版本。以 0-RTT 送出請求時交握尚未完成、還不知道協商結果，
+// 會等到交握完成。
+func (c *Client) Version() int {
+	select {
+	case <-c.conn.HandshakeComplete():
+	case <-c.conn.Context().Done():
+	}
+	return ProtocolVersion(c.conn.ConnectionState().TLS.NegotiatedProtocol)
+}
+
+// EncodePath 把路徑轉成請求行中的參數：第 2 版起以百分比編碼跳脫 %、空白與控制字元，
+// 第 1 版原樣送出。不需要跳脫的路徑兩版相同，不必等待交握，0-RTT 仍然有效。
+func (c *Client) EncodePath(p string) string {
+	if !strings.ContainsFunc(p, needsEscape) || c.Version() < 2 {
+		return p
+	}
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		if ch := p[i]; needsEscape(rune(ch)) {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		} else {
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+func needsEscape(r rune) bool {
+	return r == '%' || r <= ' ' || r == 0x7f
+}
+
+// CheckPath 拒絕空的路徑；第 1 版也拒絕含有空白或換行的路徑：指令以空白分隔參數，
+// 這類路徑會被伺服器解讀錯誤。
+func (c *Client) CheckPath(p string) error {
+	if p == "" || (strings.ContainsAny(p, " \t\r\n") && c.Version() < 2) {
+		return fmt.Errorf("無效的遠端路徑 %q", p)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// clobberPolicy 決定下載的目的檔已經存在時如何處理。
+type clobberPolicy int
+
+const (
+	clobberRefuse clobberPolicy = iota // 預設：回報錯誤，不碰既有的檔案
+	clobberSkip                        // --no-clobber：略過這個檔案
+	clobberBackup                      // --backup：把舊檔改名為 <name>~ 再寫入
+	clobberForce                       // --force：直接覆寫
+)
+
+// errSkipped 表示目的檔已存在，依 --no-clobber 略過下載。
+var errSkipped = errors.New("目的檔已存在，略過")
+
+// existsError 表示目的檔已存在而拒絕覆寫，可用 errors.Is(err, fs.ErrExist) 判斷。
+type existsError struct {
+	msg string
+}
+
+func (e *existsError) Error() string { return e.msg }
+
+func (e *existsError) Is(target error) bool { return target == fs.ErrExist }
+
+// parseClobber 由 --no-clobber、--backup、--force 決定策略，最多只能指定一個。
+func parseClobber(noClobber, backup, force bool) (clobberPolicy, error) {
+	n := 0
+	p := clobberRefuse
+	for _, f := range []struct {
+		set bool
+		p   clobberPolicy
+	}{{noClobber, clobberSkip}, {backup, clobberBackup}, {force, clobberForce}} {
+		if f.set {
+			n++
+			p = f.p
+		}
+	}
+	if n > 1 {
+		return 0, errors.New("--no-clobber、--backup 與 --force 只能擇一")
+	}
+	return p, nil
+}
+
+// check 在開始下載前檢查目的檔：已存在時依策略回傳錯誤或 errSkipped。
+func (p clobberPolicy) check(local string) error {
+	if _, err := os.Lstat(local); err != nil {
+		return nil
+	}
+	switch p {
+	case clobberSkip:
+		return errSkipped
+	case clobberRefuse:
+		return &existsError{fmt.Sprintf("%s 已存在；使用 --force 覆寫、--backup 保留舊檔或 --no-clobber 略過", local)}
+	}
+	return nil
+}
+
+// replace 把下載完成的暫存檔改名為 local。--backup 時先保留舊檔；
+// 下載期間 local 才出現且不允許覆寫時回傳錯誤，內容留在暫存檔中。
+func (p clobberPolicy) replace(tmp, local string) error {
+	if _, err := os.Lstat(local); err == nil {
+		switch p {
+		case clobberBackup:
+			if err := os.Rename(local, local+"~"); err != nil {
+				return err
+			}
+			con.Printf("已將原本的 %s 改名為 %s~\n", local, local)
+		case clobberRefuse, clobberSkip:
+			return &existsError{fmt.Sprintf("%s 在下載期間被建立，下載的內容保留在 %s", local, tmp)}
+		}
+	}
+	return os.Rename(tmp, local)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 命令列的指令名稱，供補全使用。
+var (
+	localCommands  = []string{"ctl", "jobs", "resume", "history", "usage", "verify", "completion", "version", "self-update", "audit"}
+	remoteCommands = []string{"ls", "get", "put", "sync", "watch", "check", "stream", "manifest", "ping", "bench", "daemon", "mount", "webdav", "sftp", "batch", "shell", "dedup-put", "quota", "rm", "mkdir", "mv", "stat", "hash", "verify", "restore", "trash", "lock", "unlock", "repair", "pipeline"}
+)
+
+const bashCompletion = `# %[1]s bash completion
+_%[2]s() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+    if [[ $cur == -* ]]; then
+        COMPREPLY=($(compgen -W "$(%[1]s __complete flags)" -- "$cur"))
+        return
+    fi
+    local i n=0 skip=0 valueflags=" $(%[1]s __complete valueflags | tr '\n' ' ') "
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        if ((skip)); then
+            skip=0
+        elif [[ ${COMP_WORDS[i]} == -* ]]; then
+            [[ ${COMP_WORDS[i]} != *=* && $valueflags == *" ${COMP_WORDS[i]} "* ]] && skip=1
+        else
+            ((n++))
+        fi
+    done
+    case $n in
+        0) COMPREPLY=($(compgen -W "$(%[1]s __complete servers) %[3]s" -- "$cur")) ;;
+        1) COMPREPLY=($(compgen -W "%[4]s" -- "$cur")) ;;
+        *) COMPREPLY=($(compgen -W "$(%[1]s __complete paths)" -- "$cur")) ;;
+    esac
+}
+complete -o default -F _%[2]s %[1]s
+`
+
+const zshCompletion = `#compdef %[1]s
+_%[2]s() {
+    if [[ $PREFIX == -* ]]; then
+        compadd -- ${(f)"$(%[1]s __complete flags)"}
+        return
+    fi
+    local n=0 w
+    for w in ${words[2,CURRENT-1]}; do
+        [[ $w != -* ]] && ((n++))
+    done
+    case $n in
+        0) compadd -- ${(f)"$(%[1]s __complete servers)"} %[3]s ;;
+        1) compadd -- %[4]s ;;
+        *) compadd -- ${(f)"$(%[1]s __complete paths)"}; _files ;;
+    esac
+}
+compdef _%[2]s %[1]s
+`
+
+const fishCompletion = `# %[1]s fish completion
+function __%[2]s_args
+    commandline -opc | string match -v -- '-*' | count
+end
+complete -c %[1]s -f -n 'test (__%[2]s_args) -eq 1' -a '(%[1]s __complete servers) %[3]s'
+complete -c %[1]s -f -n 'test (__%[2]s_args) -eq 2' -a '%[4]s'
+complete -c %[1]s -n 'test (__%[2]s_args) -ge 3' -a '(%[1]s __complete paths)'
+complete -c %[1]s -a '(%[1]s __complete flags)' -n 'string match -q -- "-*" (commandline -ct)'
+`
+
+// runCompletion 輸出指定 shell 的補全腳本。腳本透過隱藏指令 `__complete` 動態補全
+// 伺服器與最近使用過的遠端路徑。
+func runCompletion(shell string) error {
+	prog := filepath.Base(os.Args[0])
+	ident := strings.NewReplacer("-", "_", ".", "_").Replace(prog)
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "fish":
+		script = fishCompletion
+	default:
+		return fmt.Errorf("不支援的 shell %q，可用值: bash|zsh|fish", shell)
+	}
+	fmt.Printf(script, prog, ident, strings.Join(localCommands, " "), strings.Join(remoteCommands, " "))
+	return nil
+}
+
+// runComplete 實作 `__complete flags|valueflags|servers|paths`，每行輸出一個候選字；
+// valueflags 只列出需要參數的旗標，讓補全腳本能正確跳過旗標的值。
+func runComplete(kind string, flags *flag.FlagSet, profiles []string) error {
+	var words []string
+	switch kind {
+	case "flags", "valueflags":
+		flags.VisitAll(func(f *flag.Flag) {
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); kind == "valueflags" && ok && b.IsBoolFlag() {
+				return
+			}
+			words = append(words, "--"+f.Name)
+		})
+	case "servers", "paths":
+		seen := make(map[string]bool)
+		if kind == "servers" {
+			// 設定檔中的 profile 名稱也可以取代 <ip:port>
+			for _, p := range profiles {
+				seen[p] = true
+				words = append(words, p)
+			}
+		}
+		for _, e := range recentHistory() {
+			w := e.Remote
+			if kind == "servers" {
+				w = e.Peer
+			}
+			if w != "" && !seen[w] {
+				seen[w] = true
+				words = append(words, w)
+			}
+		}
+		sort.Strings(words)
+	}
+	for _, w := range words {
+		fmt.Println(w)
+	}
+	return nil
+}
+
+// recentHistory 讀取傳輸紀錄，讀取失敗時回傳空結果，補全不應產生錯誤訊息。
+func recentHistory() []historyEntry {
+	path, err := historyPath()
+	if err != nil {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e historyEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// compression 是一次傳輸要求伺服器使用的壓縮方式；codec 為空表示不壓縮。
+type compression struct {
+	codec string // gzip | zstd
+	level int    // 0 表示使用 codec 的預設等級
+}
+
+// compressionLevels 是各 codec 可接受的等級範圍。
+var compressionLevels = map[string][2]int{
+	"gzip": {1, 9},
+	"zstd": {1, 22},
+}
+
+// precompressedExts 是內容本身已壓縮的副檔名，再壓縮只會浪費 CPU。
+var precompressedExts = map[string]bool{
+	".gz": true, ".tgz": true, ".bz2": true, ".xz": true, ".zst": true, ".lz4": true,
+	".zip": true, ".7z": true, ".rar": true, ".jar": true, ".apk": true,
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".heic": true,
+	".mp3": true, ".aac": true, ".ogg": true, ".flac": true, ".opus": true,
+	".mp4": true, ".mkv": true, ".mov": true, ".webm": true, ".avi": true, ".ts": true,
+	".docx": true, ".xlsx": true, ".pptx": true, ".odt": true, ".pdf": true,
+}
+
+// compressFlag 是 --compress 的值：單獨使用時等同 zstd。
+type compressFlag struct {
+	codec string
+	bare  bool // 未以 = 指定 codec
+}
+
+func (f *compressFlag) String() string { return f.codec }
+
+func (f *compressFlag) Set(v string) error {
+	f.bare = v == "true"
+	switch v {
+	case "true":
+		v = "zstd"
+	case "false":
+		v = "none"
+	}
+	f.codec = v
+	return nil
+}
+
+func (f *compressFlag) IsBoolFlag() bool { return true }
+
+// accepts 回報 v 是否為 --compress 可接受的 codec 名稱。
+func (f *compressFlag) accepts(v string) bool {
+	_, ok := compressionLevels[v]
+	return ok || v == "none"
+}
+
+func parseCompression(codec string, level int) (compression, error) {
+	if codec == "" || codec == "none" {
+		if level != 0 {
+			return compression{}, fmt.Errorf("--compress-level 需要搭配 --compress")
+		}
+		return compression{}, nil
+	}
+	r, ok := compressionLevels[codec]
+	if !ok {
+		return compression{}, fmt.Errorf("不支援的壓縮方式 %q（可用 gzip、zstd、none）", codec)
+	}
+	if level != 0 && (level < r[0] || level > r[1]) {
+		return compression{}, fmt.Errorf("%s 的壓縮等級必須介於 %d 與 %d 之間", codec, r[0], r[1])
+	}
+	return compression{codec: codec, level: level}, nil
+}
+
+// For 回傳傳輸 name 時實際使用的壓縮方式：已壓縮格式的檔案不再壓縮。
+func (c compression) For(name string) compression {
+	if precompressedExts[strings.ToLower(path.Ext(name))] {
+		return compression{}
+	}
+	return c
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix 是環境變數的前綴：QUIC_CLIENT_SERVER 是預設伺服器，其餘如 QUIC_CLIENT_LIMIT、
+// QUIC_CLIENT_CONNECT_TIMEOUT 對應同名的旗標（- 換成 _）。
+const envPrefix = "QUIC_CLIENT_"
+
+// configPath 回傳設定檔路徑（$XDG_CONFIG_HOME/quic-client/config.yaml 或 ~/.config/quic-client/config.yaml）。
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "quic-client", "config.yaml"), nil
+}
+
+// scanArgv 在解析旗標前找出 --config 指定的設定檔（或 QUIC_CLIENT_CONFIG）與第一個非旗標參數，
+// 後者可能是設定檔中的 profile 名稱。
+func scanArgv(flags *flag.FlagSet, argv []string) (config, first string) {
+	config = os.Getenv(envPrefix + "CONFIG")
+	for i := 0; i < len(argv); i++ {
+		arg := argv[i]
+		if arg == "--" {
+			if i+1 < len(argv) {
+				first = argv[i+1]
+			}
+			break
+		}
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
+			first = arg
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if f := flags.Lookup(name); f != nil && !hasValue && !isBoolFlag(f) && i+1 < len(argv) {
+			i++
+			value = argv[i]
+		}
+		if name == "config" {
+			config = value
+		}
+	}
+	return config, first
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// clientConfig 是設定檔的內容：server 是預設伺服器，profiles 是具名的伺服器設定，
+// 其餘的鍵與旗標同名。
+type clientConfig struct {
+	path     string
+	server   string
+	values   map[string]any
+	profiles map[string]map[string]any
+}
+
+// defaults 是套用設定檔與環境變數後的結果。
+type defaults struct {
+	server   string   // 預設伺服器，沒有設定時為空字串
+	profile  string   // 命令列第一個參數是 profile 名稱時，它會被換成 server
+	profiles []string // 所有 profile 的名稱，供補全使用
+}
+
+// applyDefaults 依序套用設定檔、環境變數與命令列指定的 profile 作為旗標的預設值，
+// 之後解析的命令列旗標會再覆蓋它們。
+func applyDefaults(flags *flag.FlagSet, argv []string) (defaults, error) {
+	var d defaults
+	path, first := scanArgv(flags, argv)
+	required := path != ""
+	if !required {
+		var err error
+		if path, err = configPath(); err != nil {
+			return d, nil
+		}
+	}
+	conf, err := loadConfig(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		conf, err = &clientConfig{path: path}, nil
+	}
+	if err != nil {
+		return d, err
+	}
+	if d.server, err = conf.apply(flags, "", conf.values); err != nil {
+		return d, err
+	}
+
+	flags.VisitAll(func(f *flag.Flag) {
+		if err != nil || f.Name == "config" {
+			return
+		}
+		env := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if v, ok := os.LookupEnv(env); ok {
+			if serr := flags.Set(f.Name, v); serr != nil {
+				err = fmt.Errorf("%s: %v", env, serr)
+			}
+		}
+	})
+	if err != nil {
+		return d, err
+	}
+	if v := os.Getenv(envPrefix + "SERVER"); v != "" {
+		d.server = v
+	}
+
+	for name := range conf.profiles {
+		d.profiles = append(d.profiles, name)
+	}
+	slices.Sort(d.profiles)
+	if p, ok := conf.profiles[first]; ok {
+		server, err := conf.apply(flags, first, p)
+		if err != nil {
+			return d, err
+		}
+		if server == "" {
+			return d, fmt.Errorf("設定檔 %s: profile %q 沒有設定 server", path, first)
+		}
+		d.server, d.profile = server, first
+		return d, nil
+	}
+	// 直接指定伺服器位址時，套用 server 與它相同的 profile，例如為個別主機設定速度上限
+	for _, name := range d.profiles {
+		p := conf.profiles[name]
+		if server, ok := p["server"]; ok && fmt.Sprint(server) == first {
+			if _, err := conf.apply(flags, name, p); err != nil {
+				return d, err
+			}
+			break
+		}
+	}
+	return d, nil
+}
+
+// loadConfig 讀取 YAML 設定檔。
+func loadConfig(path string) (*clientConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("無法解析設定檔 %s: %v", path, err)
+	}
+	conf := &clientConfig{path: path, values: values}
+	if p, ok := values["profiles"]; ok {
+		if err := decodeProfiles(p, &conf.profiles); err != nil {
+			return nil, fmt.Errorf("設定檔 %s: profiles: %v", path, err)
+		}
+		delete(values, "profiles")
+	}
+	return conf, nil
+}
+
+// decodeProfiles 把 profiles 區段轉成 名稱 -> 設定 的對照表。
+func decodeProfiles(v any, profiles *map[string]map[string]any) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, profiles); err != nil {
+		return err
+	}
+	for name := range *profiles {
+		if strings.Contains(name, ":") {
+			return fmt.Errorf("profile 名稱 %q 不可包含 :", name)
+		}
+	}
+	return nil
+}
+
+// apply 以 values 設定旗標並回傳其中的 server；可重複的旗標（pin、include、exclude 等）可以寫成清單。
+// profile 不為空時表示 values 來自該 profile，只用於錯誤訊息。
+func (c *clientConfig) apply(flags *flag.FlagSet, profile string, values map[string]any) (string, error) {
+	where := c.path
+	if profile != "" {
+		where += fmt.Sprintf(": profile %q", profile)
+	}
+	var server string
+	for key, value := range values {
+		if key == "server" {
+			server = fmt.Sprint(value)
+			continue
+		}
+		if flags.Lookup(key) == nil || key == "config" {
+			return "", fmt.Errorf("設定檔 %s: 未知的選項 %q", where, key)
+		}
+		list, ok := value.([]any)
+		if !ok {
+			list = []any{value}
+		}
+		for _, v := range list {
+			if err := flags.Set(key, fmt.Sprint(v)); err != nil {
+				return "", fmt.Errorf("設定檔 %s: %s: %v", where, key, err)
+			}
+		}
+	}
+	return server, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"go-client/client"
+)
+
+// console 決定輸出的去向：一般模式下人類可讀的文字寫到 stdout；
+// --json 模式下 stdout 只有 JSON 結果（每行一個物件），--format 模式下則是以 Go 樣板格式化的結果；
+// 兩種模式的訊息與進度都改寫到 stderr，讓其他程式能可靠地解析輸出。
+type console struct {
+	mu       sync.Mutex
+	json     bool
+	format   *template.Template // --format 指定時以樣板輸出每筆結果
+	events   io.Writer          // 不為 nil 時寫出傳輸事件（--json 時為 stdout，或 --events-fd）
+	progress progressMode       // 進度的顯示方式（--progress、--quiet）
+	data     bool               // -o -：stdout 是下載的內容，訊息與結果都改寫到 stderr
+	hooks    transferHooks      // 檔案傳輸完成或失敗時執行的命令
+	inline   atomic.Bool        // stderr 上正顯示進度列，寫日誌前要先清除
+}
+
+var con = &console{}
+
+// Text 回傳人類可讀訊息應寫入的位置。
+func (c *console) Text() io.Writer {
+	if c.json || c.format != nil || c.data {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+func (c *console) Printf(format string, a ...any) {
+	fmt.Fprintf(c.Text(), format, a...)
+}
+
+func (c *console) Println(a ...any) {
+	fmt.Fprintln(c.Text(), a...)
+}
+
+// Result 在 --format 模式下以樣板輸出 v，在 --json 模式下把 v 以一行 JSON 寫到 stdout，
+// 一般模式下不做事。
+func (c *console) Result(v any) {
+	if c.format == nil && !c.json {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := os.Stdout
+	if c.data {
+		out = os.Stderr
+	}
+	if c.format != nil {
+		if err := c.format.Execute(out, v); err != nil {
+			fmt.Fprintln(os.Stderr, "樣板錯誤:", err)
+		}
+		fmt.Fprintln(out)
+		return
+	}
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+// SetFormat 解析 --format 樣板。樣板可使用 json 與 size 函式，例如 '{{.Name}}\t{{size .Size}}'。
+func (c *console) SetFormat(text string) error {
+	tmpl, err := template.New("format").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+		"size": humanSize,
+	}).Parse(text)
+	if err != nil {
+		return fmt.Errorf("無效的 --format: %v", err)
+	}
+	c.format = tmpl
+	return nil
+}
+
+// transferEvent 是傳輸過程中的一個事件，每行一個 JSON 物件，以 event 欄位區分種類。
+type transferEvent struct {
+	Event  string        `json:"event"` // start | progress | done | error | migrated
+	File   string        `json:"file"`
+	Bytes  int64         `json:"bytes"`
+	Total  int64         `json:"total"`
+	Rate   float64       `json:"rate,omitempty"` // bytes/sec
+	Local  string        `json:"local,omitempty"`
+	SHA256 string        `json:"sha256,omitempty"`
+	Time   time.Duration `json:"duration,omitempty"`
+	Error  string        `json:"error,omitempty"`
+	Path   string        `json:"path,omitempty"` // migrated：連線遷移後的本機位址，File 為伺服器
+}
+
+// Event 寫出一個傳輸事件（有指定事件輸出時），並執行對應的 --on-complete 或 --on-error 命令。
+func (c *console) Event(e transferEvent) {
+	if c.events != nil {
+		c.mu.Lock()
+		enc := json.NewEncoder(c.events)
+		enc.SetEscapeHTML(false)
+		enc.Encode(e)
+		c.mu.Unlock()
+	}
+	promMetrics.observe(e)
+	c.hooks.run(e)
+}
+
+// Error 在 --json 模式下輸出 {"error": "...", "status": 404, "exit_code": 3}；--format 模式下錯誤只寫到 stderr。
+func (c *console) Error(err error, exitCode int) {
+	if c.format != nil {
+		return
+	}
+	result := struct {
+		Error    string `json:"error"`
+		Status   int    `json:"status,omitempty"` // 伺服器回報的狀態碼
+		ExitCode int    `json:"exit_code"`
+	}{Error: err.Error(), ExitCode: exitCode}
+	var serverErr *client.ServerError
+	if errors.As(err, &serverErr) {
+		result.Status = serverErr.Code
+	}
+	c.Result(result)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+
+	"go-client/client"
+)
+
+// transferControl 讓使用者在傳輸進行中暫停、繼續或調整速度上限。
+// 暫停時停止讀取 stream，連線靠 keep-alive 維持；下載的連線有 datagram 控制通道時，
+// 也經由 remote 要求伺服器暫停或降速，不必靠流量控制把伺服器擋下來。
+type transferControl struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	paused  bool
+	limiter *rateLimitedReader
+	group   *limiterGroup // 速度上限的調整作用在整個群組上，避免成員重新分配時被覆蓋
+	remote  *client.Download
+
+	name  string
+	total int64
+	start time.Time
+	bytes int64
+}
+
+func newTransferControl(name string, total int64, limiter *rateLimitedReader, group *limiterGroup) *transferControl {
+	c := &transferControl{name: name, total: total, limiter: limiter, group: group, start: time.Now()}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *transferControl) Pause() {
+	c.mu.Lock()
+	c.paused = true
+	c.mu.Unlock()
+	if c.remote != nil {
+		c.remote.Pause(true)
+	}
+}
+
+func (c *transferControl) Resume() {
+	c.mu.Lock()
+	c.paused = false
+	c.mu.Unlock()
+	c.cond.Broadcast()
+	if c.remote != nil {
+		c.remote.Pause(false)
+	}
+}
+
+// limitStepUp 與 limitStepDown 是按 +/-、SIGUSR2/SIGUSR1 或 `ctl limit up|down` 時調整速度上限的倍數。
+const (
+	limitStepUp   = 1.25
+	limitStepDown = 0.8
+)
+
+// Nudge 將速度上限乘上 factor；原本不限速時以目前的平均速度為基準。
+func (c *transferControl) Nudge(factor float64) int64 {
+	limit := c.group.Nudge(factor)
+	if c.remote != nil {
+		c.remote.Throttle(limit)
+	}
+	return limit
+}
+
+// SetLimit 設定速度上限，0 表示不限速。
+func (c *transferControl) SetLimit(limit int64) {
+	c.group.SetTotal(limit)
+	if c.remote != nil {
+		c.remote.Throttle(limit)
+	}
+}
+
+// transferStatus 是控制 socket `json` 指令回傳的傳輸狀態。
+type transferStatus struct {
+	Name  string  `json:"name"`
+	State string  `json:"state"`
+	Bytes int64   `json:"bytes"`
+	Total int64   `json:"total"`
+	Rate  float64 `json:"rate"` // bytes/sec
+	Limit int64   `json:"limit"`
+	// Priority 只用於排隊中的傳輸
+	Priority string `json:"priority,omitempty"`
+	// ServerBytes 是伺服器經由 datagram 控制通道回報已送出的位元組數
+	ServerBytes int64 `json:"server_bytes,omitempty"`
+}
+
+func (c *transferControl) Snapshot() transferStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := "running"
+	if c.paused {
+		state = "paused"
+	}
+	st := transferStatus{
+		Name:  c.name,
+		State: state,
+		Bytes: c.bytes,
+		Total: c.total,
+		Rate:  float64(c.bytes) / time.Since(c.start).Seconds(),
+		Limit: c.group.Total(),
+	}
+	if c.remote != nil {
+		st.ServerBytes = c.remote.ServerSent()
+	}
+	return st
+}
+
+// Status 回傳一行狀態摘要，供控制 socket 使用。
+func (c *transferControl) Status() string {
+	st := c.Snapshot()
+	return fmt.Sprintf("%s %s %d/%d bytes %.2f KB/s limit=%d", st.Name, st.State, st.Bytes, st.Total, st.Rate/1024, st.Limit)
+}
+
+func (c *transferControl) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	for c.paused {
+		c.cond.Wait()
+	}
+	c.mu.Unlock()
+
+	n, err := c.limiter.Read(p)
+	c.mu.Lock()
+	c.bytes += int64(n)
+	c.mu.Unlock()
+	return n, err
+}
+
+// confirm 在 stdin 為終端機時詢問使用者，非互動環境一律回答否。
+func confirm(question string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+	con.Printf("%s [y/N] ", question)
+	var answer string
+	fmt.Scanln(&answer)
+	return answer == "y" || answer == "Y" || answer == "yes"
+}
+
+// keyRouter 是行程內唯一讀取 stdin 按鍵的地方。每個傳輸各開一個讀取 goroutine 的話，
+// 傳輸結束後舊的 goroutine 仍卡在 os.Stdin.Read，按鍵會被它搶走而送到已結束的傳輸；
+// 因此只啟動一個讀取者，按鍵一律交給最近開始且尚未結束的傳輸。
+type keyRouter struct {
+	mu      sync.Mutex
+	active  []*transferControl
+	started bool
+	state   *term.State // 第一個傳輸開始前的終端機設定，最後一個傳輸結束時還原
+}
+
+var keys keyRouter
+
+// watchKeys 在 stdin 為終端機時進入 raw 模式，並把按鍵導向 c：
+// p 暫停、r 繼續、+/- 調整速度上限。回傳的函式讓 c 不再接收按鍵，沒有其他傳輸時還原終端機設定。
+func watchKeys(c *transferControl) (stop func()) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return func() {}
+	}
+	if err := keys.add(c, fd); err != nil {
+		return func() {}
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() { keys.remove(c, fd) })
+	}
+}
+
+func (k *keyRouter) add(c *transferControl, fd int) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.active) == 0 {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		k.state = state
+	}
+	k.active = append(k.active, c)
+	if !k.started {
+		k.started = true
+		go k.read()
+	}
+	return nil
+}
+
+func (k *keyRouter) remove(c *transferControl, fd int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if i := slices.Index(k.active, c); i >= 0 {
+		k.active = slices.Delete(k.active, i, i+1)
+	}
+	if len(k.active) == 0 && k.state != nil {
+		term.Restore(fd, k.state)
+		k.state = nil
+	}
+}
+
+// current 回傳接收按鍵的傳輸；沒有進行中的傳輸時為 nil。
+func (k *keyRouter) current() *transferControl {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.active) == 0 {
+		return nil
+	}
+	return k.active[len(k.active)-1]
+}
+
+func (k *keyRouter) read() {
+	buf := make([]byte, 1)
+	for {
+		if _, err := os.Stdin.Read(buf); err != nil {
+			return
+		}
+		if c := k.current(); c != nil {
+			handleKey(c, buf[0])
+		}
+	}
+}
+
+func handleKey(c *transferControl, key byte) {
+	switch key {
+	case 'p':
+		c.Pause()
+		con.Printf("\r\n已暫停，按 r 繼續\r\n")
+	case 'r':
+		c.Resume()
+		con.Printf("\r\n繼續傳輸\r\n")
+	case '+':
+		if limit := c.Nudge(limitStepUp); limit > 0 {
+			con.Printf("\r\n速度上限: %s\r\n", humanRate(limit))
+		}
+	case '-':
+		con.Printf("\r\n速度上限: %s\r\n", humanRate(c.Nudge(limitStepDown)))
+	case 3: // Ctrl-C 在 raw 模式下不會產生 SIGINT
+		interrupt()
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestKeyRouterTarget(t *testing.T) {
+	var k keyRouter
+	a, b := &transferControl{name: "a"}, &transferControl{name: "b"}
+	if k.current() != nil {
+		t.Fatal("keys routed with no transfer")
+	}
+	// 不是終端機，不會設定 raw 模式；只檢查按鍵的去向
+	k.active = append(k.active, a, b)
+	if k.current() != b {
+		t.Error("keys not routed to the latest transfer")
+	}
+	k.remove(b, -1)
+	if k.current() != a {
+		t.Error("keys not routed back to a after b stopped")
+	}
+	k.remove(b, -1)
+	k.remove(a, -1)
+	if k.current() != nil {
+		t.Error("keys still routed after every transfer stopped")
+	}
+}
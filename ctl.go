@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// controlDir 是本機控制 socket 的所在目錄，每個執行中的傳輸各有一個 <pid>.sock。
+func controlDir() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("quic-client-%d", os.Getuid()))
+}
+
+// ensureControlDir 建立控制目錄並確認可以信任它：os.TempDir 是所有使用者共用的，
+// 其他使用者可以搶先建立同名的目錄或符號連結，攔截或偽造控制連線。
+func ensureControlDir() (string, error) {
+	dir := controlDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, checkPrivateDir(dir)
+}
+
+// controlServer 是本行程的控制 socket（<pid>.sock）。進行中的傳輸與排隊的佇列向它登記，
+// 第一個登記時建立 socket，最後一個結束時關閉；同時下載多個檔案時，`jobs` 因此能看到每個檔案，包括還在排隊的。
+type controlServer struct {
+	mu        sync.Mutex
+	ln        net.Listener
+	path      string
+	transfers []*controlledTransfer
+	queues    []*transferQueue
+}
+
+type controlledTransfer struct {
+	ctl    *transferControl
+	cancel func()
+}
+
+var control controlServer
+
+// serveControl 讓 c 可經由控制 socket 以 status|pause|resume|cancel|limit 指令管理，
+// 供其他 shell 或腳本使用。回傳的函式取消登記，沒有其他登記時關閉並刪除 socket。
+func serveControl(c *transferControl, cancel func()) (func(), error) {
+	t := &controlledTransfer{ctl: c, cancel: cancel}
+	if err := control.register(func() { control.transfers = append(control.transfers, t) }); err != nil {
+		return nil, err
+	}
+	return func() {
+		control.unregister(func() {
+			control.transfers = slices.DeleteFunc(control.transfers, func(x *controlledTransfer) bool { return x == t })
+		})
+	}, nil
+}
+
+// serveQueue 讓 q 中排隊的傳輸出現在控制 socket 的 list 回應中。
+func serveQueue(q *transferQueue) (func(), error) {
+	if err := control.register(func() { control.queues = append(control.queues, q) }); err != nil {
+		return nil, err
+	}
+	return func() {
+		control.unregister(func() {
+			control.queues = slices.DeleteFunc(control.queues, func(x *transferQueue) bool { return x == q })
+		})
+	}, nil
+}
+
+func (s *controlServer) register(add func()) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln == nil {
+		dir, err := ensureControlDir()
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%d.sock", os.Getpid()))
+		os.Remove(path)
+		ln, err := net.Listen("unix", path)
+		if err != nil {
+			return err
+		}
+		s.ln, s.path = ln, path
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				go s.handle(conn)
+			}
+		}()
+	}
+	add()
+	return nil
+}
+
+func (s *controlServer) unregister(remove func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	remove()
+	if s.ln != nil && len(s.transfers) == 0 && len(s.queues) == 0 {
+		s.ln.Close()
+		os.Remove(s.path)
+		s.ln = nil
+	}
+}
+
+// list 回傳進行中的傳輸與各佇列中排隊的傳輸。
+func (s *controlServer) list() []transferStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]transferStatus, 0, len(s.transfers))
+	for _, t := range s.transfers {
+		list = append(list, t.ctl.Snapshot())
+	}
+	for _, q := range s.queues {
+		for _, p := range q.Pending() {
+			list = append(list, transferStatus{Name: p.name, State: "queued", Priority: p.priority.String()})
+		}
+	}
+	return list
+}
+
+func (s *controlServer) running() []*controlledTransfer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.transfers)
+}
+
+// handle 處理一個控制連線。list 列出所有傳輸（`jobs` 使用）；其他指令作用於本行程所有進行中的傳輸。
+func (s *controlServer) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	if fields[0] == "list" {
+		data, _ := json.Marshal(s.list())
+		fmt.Fprintf(conn, "%s\n", data)
+		return
+	}
+	transfers := s.running()
+	if len(transfers) == 0 {
+		fmt.Fprintln(conn, "ERR 沒有進行中的傳輸")
+		return
+	}
+	// 速度上限作用在共用的群組上，只需調整一次
+	c := transfers[len(transfers)-1].ctl
+	switch fields[0] {
+	case "status":
+		lines := make([]string, len(transfers))
+		for i, t := range transfers {
+			lines[i] = t.ctl.Status()
+		}
+		fmt.Fprintln(conn, strings.Join(lines, "; "))
+	case "json":
+		data, _ := json.Marshal(c.Snapshot())
+		fmt.Fprintf(conn, "%s\n", data)
+	case "pause":
+		for _, t := range transfers {
+			t.ctl.Pause()
+		}
+		fmt.Fprintln(conn, "OK paused")
+	case "resume":
+		for _, t := range transfers {
+			t.ctl.Resume()
+		}
+		fmt.Fprintln(conn, "OK resumed")
+	case "cancel":
+		for _, t := range transfers {
+			t.cancel()
+			t.ctl.Resume()
+		}
+		fmt.Fprintln(conn, "OK cancelled")
+	case "limit":
+		if len(fields) != 2 {
+			fmt.Fprintln(conn, "ERR 用法: limit <bytes/sec|500k|2M|up|down>")
+			return
+		}
+		var limit int64
+		switch fields[1] {
+		case "up":
+			limit = c.Nudge(limitStepUp)
+		case "down":
+			limit = c.Nudge(limitStepDown)
+		default:
+			if limit, err = parseRate(fields[1]); err != nil {
+				fmt.Fprintln(conn, "ERR 無效的速度上限")
+				return
+			}
+			c.SetLimit(limit)
+		}
+		fmt.Fprintf(conn, "OK limit %d\n", limit)
+	default:
+		fmt.Fprintf(conn, "ERR 未知指令 %q\n", fields[0])
+	}
+}
+
+// runCtl 實作 `data_cli ctl <cmd> [pid]`；未指定 pid 時 status 會列出所有行程的傳輸，
+// 其他指令則要求恰好只有一個行程在傳輸。
+func runCtl(args []string) error {
+	if len(args) == 0 {
+		return errors.New("用法: data_cli ctl <status|pause|resume|cancel|limit N> [pid]")
+	}
+	cmd := args[0]
+	rest := args[1:]
+	if cmd == "limit" {
+		if len(rest) == 0 {
+			return errors.New("用法: data_cli ctl limit <bytes/sec|500k|2M|up|down> [pid]")
+		}
+		cmd += " " + rest[0]
+		rest = rest[1:]
+	}
+
+	sockets, err := controlSockets()
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		sockets = []string{filepath.Join(controlDir(), rest[0]+".sock")}
+	}
+	if len(sockets) == 0 {
+		return errors.New("沒有執行中的傳輸")
+	}
+	if len(sockets) > 1 && cmd != "status" {
+		return fmt.Errorf("有 %d 個執行中的傳輸，請指定 pid", len(sockets))
+	}
+
+	failed := false
+	for _, path := range sockets {
+		pid := strings.TrimSuffix(filepath.Base(path), ".sock")
+		reply, err := sendControl(path, cmd)
+		if err != nil {
+			failed = true
+			con.Printf("%s\t%v\n", pid, err)
+			con.Result(ctlResult{PID: pid, Error: err.Error()})
+			continue
+		}
+		if strings.HasPrefix(reply, "ERR") {
+			failed = true
+		}
+		con.Printf("%s\t%s\n", pid, reply)
+		con.Result(ctlResult{PID: pid, Reply: reply})
+	}
+	if failed {
+		return errors.New("部分控制指令失敗")
+	}
+	return nil
+}
+
+type ctlResult struct {
+	PID   string `json:"pid"`
+	Reply string `json:"reply,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// controlSockets 回傳控制目錄中各行程的控制 socket，不包括 daemon 的 HTTP API socket。
+// 控制目錄不屬於目前的使用者時回傳錯誤，不連線到別人放置的 socket。
+func controlSockets() ([]string, error) {
+	dir := controlDir()
+	if err := checkPrivateDir(dir); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	sockets, _ := filepath.Glob(filepath.Join(dir, "*.sock"))
+	return slices.DeleteFunc(sockets, func(p string) bool { return filepath.Base(p) == daemonSocket }), nil
+}
+
+func sendControl(path, cmd string) (string, error) {
+	conn, err := net.DialTimeout("unix", path, 2*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintln(conn, cmd)
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(reply), nil
+}
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go-client/client"
+)
+
+// daemonTransfer 是 daemon 佇列中的一筆傳輸。ctl 在開始傳輸後才建立，之前只有 state。
+type daemonTransfer struct {
+	ID       string    `json:"id"`
+	Server   string    `json:"server"`
+	Op       string    `json:"op"` // get 或 put
+	Priority string    `json:"priority"`
+	Remote   string    `json:"remote"`
+	Local    string    `json:"local"`
+	State    string    `json:"state"` // queued、running、paused、done、failed、cancelled
+	Bytes    int64     `json:"bytes"`
+	Total    int64     `json:"total"`
+	Rate     float64   `json:"rate"` // bytes/sec
+	SHA256   string    `json:"sha256,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Created  time.Time `json:"created"`
+	Finished time.Time `json:"finished,omitzero"`
+
+	ctl    *transferControl
+	paused bool
+	cancel context.CancelFunc
+}
+
+// daemonSocket 是 daemon 預設在控制目錄中監聽的 HTTP API socket，`jobs` 也經由它列出 daemon 的傳輸。
+const daemonSocket = "daemon.sock"
+
+// daemon 保持到各伺服器的連線，並依序執行經由 HTTP API 加入的傳輸。
+type daemon struct {
+	ctx      context.Context // daemon 結束時取消所有傳輸
+	servers  []string
+	limiter  *limiterGroup // 下載
+	upload   *limiterGroup // 上傳
+	clobber  clobberPolicy
+	prios    priorityRules
+	preserve bool
+	token    string // TCP 的 API 要求的 bearer token，unix socket 時為空
+
+	mu        sync.Mutex
+	sessions  map[string]*liveSession
+	transfers map[string]*daemonTransfer
+	order     []string
+	queue     *transferQueue
+}
+
+// runDaemon 實作 `daemon [-listen addr] [-j N] [ip:port ...]`：保持到 server 與其他列出的伺服器的 QUIC 連線（中斷時重新連線），
+// 在本機提供 HTTP API 加入傳輸、查詢進度、暫停、繼續與取消。預設監聽控制目錄中的 unix socket，
+// 只有同一個使用者可以連線；-listen host:port 改用 TCP，此時每個請求都要帶 bearer token：
+// 由 -token-file 提供，或啟動時產生並寫到控制目錄中的 daemon.token。沒有 -token-file 時只能監聽 loopback 位址。
+func runDaemon(ctx context.Context, server string, args []string, limiter, upload *limiterGroup, clobber clobberPolicy, prios priorityRules, preserve bool) error {
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	listen := flags.String("listen", "", "API 監聽的位址 host:port，預設為 "+filepath.Join(controlDir(), daemonSocket))
+	jobs := flags.Int("j", 2, "同時進行的傳輸數")
+	tokenFile := flags.String("token-file", "", "-listen 時 API 要求的 bearer token 所在的檔案（預設產生隨機 token 寫到 "+filepath.Join(controlDir(), "daemon.token")+"）")
+	flags.Parse(args)
+	// 旗標之後的參數是其他要保持連線的伺服器
+	servers := append([]string{server}, flags.Args()...)
+	if *jobs < 1 {
+		return errors.New("用法: data_cli <ip:port> daemon [-listen host:port [-token-file file]] [-j N] [ip:port ...]")
+	}
+	var token string
+	if *listen != "" {
+		var cleanup func()
+		var err error
+		if token, cleanup, err = daemonToken(*listen, *tokenFile); err != nil {
+			return err
+		}
+		defer cleanup()
+	}
+
+	d := &daemon{ctx: ctx, servers: servers, limiter: limiter, upload: upload, clobber: clobber, prios: prios, preserve: preserve, token: token,
+		sessions: make(map[string]*liveSession), transfers: make(map[string]*daemonTransfer), queue: newTransferQueue(*jobs)}
+	defer d.queue.Close()
+	for _, server := range servers {
+		if _, err := d.session(ctx, server); err != nil {
+			// 第一次加入該伺服器的傳輸時會再試一次
+			slog.Warn("daemon 無法連線", "server", server, "err", err)
+		}
+	}
+
+	var ln net.Listener
+	var err error
+	if *listen == "" {
+		dir, err := ensureControlDir()
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, daemonSocket)
+		os.Remove(path)
+		if ln, err = net.Listen("unix", path); err != nil {
+			return err
+		}
+		defer os.Remove(path)
+	} else if ln, err = net.Listen("tcp", *listen); err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: d.handler()}
+	context.AfterFunc(ctx, func() { srv.Close() })
+
+	con.Printf("daemon 已啟動，API 位於 %s，伺服器: %s\n", ln.Addr(), strings.Join(servers, ", "))
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// daemonToken 決定 TCP API 的 bearer token：讀取 tokenFile，或產生隨機 token 寫到控制目錄中的 daemon.token
+// （只有同一個使用者可以讀取，daemon 結束時刪除）。沒有 tokenFile 時拒絕監聽非 loopback 的位址。
+func daemonToken(listen, tokenFile string) (string, func(), error) {
+	if tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return "", nil, fmt.Errorf("無法讀取 -token-file: %v", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", nil, fmt.Errorf("-token-file %s 是空的", tokenFile)
+		}
+		return token, func() {}, nil
+	}
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", nil, fmt.Errorf("無效的 -listen %q: %v", listen, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return "", nil, fmt.Errorf("-listen %s 不是 loopback 位址，必須以 -token-file 設定 API token", listen)
+	}
+	dir, err := ensureControlDir()
+	if err != nil {
+		return "", nil, err
+	}
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	token := hex.EncodeToString(buf)
+	path := filepath.Join(dir, "daemon.token")
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", nil, err
+	}
+	con.Printf("API token 已寫到 %s\n", path)
+	return token, func() { os.Remove(path) }, nil
+}
+
+// session 回傳到 server 的連線，尚未連線或已中斷時重新連線。
+func (d *daemon) session(ctx context.Context, server string) (*liveSession, error) {
+	d.mu.Lock()
+	live, ok := d.sessions[server]
+	d.mu.Unlock()
+	if ok {
+		_, err := live.get(ctx)
+		return live, err
+	}
+	conn, err := dial(ctx, server, nil)
+	if err != nil {
+		return nil, fmt.Errorf("無法連線到 %s: %w", server, err)
+	}
+	live = newLiveSession(conn, server, nil)
+	d.mu.Lock()
+	d.sessions[server] = live
+	d.mu.Unlock()
+	return live, nil
+}
+
+func (d *daemon) handler() http.Handler {
+	promMetrics.start()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /servers", d.handleServers)
+	mux.HandleFunc("GET /transfers", d.handleList)
+	mux.HandleFunc("POST /transfers", d.handleEnqueue)
+	mux.HandleFunc("GET /transfers/{id}", d.handleGet)
+	mux.HandleFunc("POST /transfers/{id}/{action}", d.handleAction)
+	mux.Handle("GET /metrics", promMetrics)
+	return d.guard(mux)
+}
+
+// guard 檢查 bearer token（TCP 時），並要求 POST 的 Content-Type 是 application/json：
+// 瀏覽器跨站送出的表單（simple request）不能設定這個 Content-Type，也就無法經由 daemon 讀寫本機檔案。
+func (d *daemon) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.token != "" {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(d.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, http.StatusUnauthorized, "需要有效的 bearer token")
+				return
+			}
+		}
+		if r.Method == http.MethodPost {
+			if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, "Content-Type 必須是 application/json")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+type daemonServer struct {
+	Server string `json:"server"`
+	Addr   string `json:"addr,omitempty"`
+	Alive  bool   `json:"alive"`
+}
+
+func (d *daemon) handleServers(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := make([]daemonServer, 0, len(d.servers))
+	for _, server := range d.servers {
+		s := daemonServer{Server: server}
+		if live, ok := d.sessions[server]; ok {
+			s.Alive = live.alive()
+			live.mu.Lock()
+			s.Addr = live.conn.RemoteAddr().String()
+			live.mu.Unlock()
+		}
+		list = append(list, s)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// snapshot 在持有 d.mu 時回傳 t 目前的狀態。
+func (t *daemonTransfer) snapshot() daemonTransfer {
+	s := *t
+	if t.ctl != nil && (t.State == "running" || t.State == "paused") {
+		st := t.ctl.Snapshot()
+		s.Bytes, s.Total, s.Rate = st.Bytes, st.Total, st.Rate
+	}
+	s.ctl, s.cancel = nil, nil
+	return s
+}
+
+func (d *daemon) handleList(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	list := make([]daemonTransfer, 0, len(d.order))
+	for _, id := range d.order {
+		list = append(list, d.transfers[id].snapshot())
+	}
+	d.mu.Unlock()
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (d *daemon) handleGet(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	t, ok := d.transfers[r.PathValue("id")]
+	var s daemonTransfer
+	if ok {
+		s = t.snapshot()
+	}
+	d.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "沒有這個傳輸")
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// handleEnqueue 加入一筆傳輸：{"op":"get|put","remote":"...","local":"...","server":"...","priority":"high"}。
+// 只設定一個伺服器時 server 可以省略；local 的相對路徑以 daemon 的工作目錄為準。
+// 沒有指定 priority 時依 --priority 的規則決定，高優先權的傳輸插隊到佇列前面。
+func (d *daemon) handleEnqueue(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Server   string `json:"server"`
+		Op       string `json:"op"`
+		Remote   string `json:"remote"`
+		Local    string `json:"local"`
+		Priority string `json:"priority"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "無效的 JSON: "+err.Error())
+		return
+	}
+	if req.Server == "" && len(d.servers) == 1 {
+		req.Server = d.servers[0]
+	}
+	switch {
+	case !slices.Contains(d.servers, req.Server):
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("伺服器 %q 不在 daemon 的設定中", req.Server))
+		return
+	case req.Op != "get" && req.Op != "put":
+		writeError(w, http.StatusBadRequest, "op 必須是 get 或 put")
+		return
+	case req.Op == "put" && req.Local == "":
+		writeError(w, http.StatusBadRequest, "put 需要 local")
+		return
+	case req.Op == "get" && req.Remote == "":
+		writeError(w, http.StatusBadRequest, "get 需要 remote")
+		return
+	}
+	if req.Op == "get" && req.Local == "" {
+		req.Local = filepath.Base(req.Remote)
+	}
+	if req.Op == "put" && req.Remote == "" {
+		req.Remote = filepath.Base(req.Local)
+	}
+	local, err := filepath.Abs(req.Local)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	p := d.prios.For(req.Remote, d.prios.def)
+	if req.Priority != "" {
+		if p, err = parsePriority(req.Priority); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	id := make([]byte, 4)
+	rand.Read(id)
+	t := &daemonTransfer{ID: hex.EncodeToString(id), Server: req.Server, Op: req.Op, Priority: p.String(), Remote: req.Remote, Local: local,
+		State: "queued", Created: time.Now()}
+
+	d.mu.Lock()
+	d.transfers[t.ID] = t
+	d.order = append(d.order, t.ID)
+	s := t.snapshot()
+	d.mu.Unlock()
+	d.queue.Push(p, t.Remote, func() { d.run(d.ctx, t) })
+	writeJSON(w, http.StatusCreated, s)
+}
+
+// handleAction 處理 pause、resume 與 cancel。暫停只是停止讀取 stream，連線靠 keep-alive 維持。
+func (d *daemon) handleAction(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t, ok := d.transfers[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "沒有這個傳輸")
+		return
+	}
+	finished := t.State == "done" || t.State == "failed" || t.State == "cancelled"
+	switch action := r.PathValue("action"); {
+	case action != "pause" && action != "resume" && action != "cancel":
+		writeError(w, http.StatusNotFound, fmt.Sprintf("未知的動作 %q（可用 pause、resume、cancel）", action))
+		return
+	case finished:
+		writeError(w, http.StatusConflict, "傳輸已經結束")
+		return
+	case action == "pause":
+		t.paused = true
+		if t.ctl != nil {
+			t.ctl.Pause()
+			t.State = "paused"
+		}
+	case action == "resume":
+		t.paused = false
+		if t.ctl != nil {
+			t.ctl.Resume()
+			t.State = "running"
+		}
+	case action == "cancel":
+		if t.cancel != nil {
+			t.cancel()
+		}
+		if t.ctl != nil {
+			t.ctl.Resume()
+		}
+		t.State, t.Finished = "cancelled", time.Now()
+	}
+	writeJSON(w, http.StatusOK, t.snapshot())
+}
+
+// run 執行佇列中的一筆傳輸並記錄結果。
+func (d *daemon) run(ctx context.Context, t *daemonTransfer) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	d.mu.Lock()
+	if t.State == "cancelled" {
+		d.mu.Unlock()
+		return
+	}
+	t.State, t.cancel = "running", cancel
+	d.mu.Unlock()
+
+	start := time.Now()
+	sum, err := d.transfer(ctx, t)
+
+	d.mu.Lock()
+	switch {
+	case t.State == "cancelled":
+	case err != nil:
+		t.State, t.Error, t.Finished = "failed", err.Error(), time.Now()
+	default:
+		t.State, t.SHA256, t.Finished = "done", sum, time.Now()
+	}
+	if t.ctl != nil {
+		st := t.ctl.Snapshot()
+		t.Bytes, t.Total, t.Rate = st.Bytes, st.Total, st.Rate
+	}
+	// 與其他模式相同地送出事件，計入 /metrics 並執行 --on-complete、--on-error
+	e := transferEvent{Event: "done", File: t.Remote, Bytes: t.Bytes, Total: t.Total, Local: t.Local, SHA256: t.SHA256, Time: time.Since(start)}
+	if t.State != "done" {
+		e = transferEvent{Event: "error", File: t.Remote, Local: t.Local, Error: t.Error}
+	}
+	state := t.State
+	d.mu.Unlock()
+	if state != "cancelled" {
+		con.Event(e)
+	}
+	slog.Info("daemon 傳輸結束", "id", t.ID, "op", t.Op, "server", t.Server, "remote", t.Remote, "state", state)
+}
+
+// start 在傳輸開始讀寫前設定 ctl，加入前已要求暫停的話從暫停狀態開始。
+func (d *daemon) start(t *daemonTransfer, ctl *transferControl) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t.ctl = ctl
+	if t.paused {
+		ctl.Pause()
+		t.State = "paused"
+	}
+}
+
+// transfer 執行一筆傳輸，回傳內容的 SHA-256。
+func (d *daemon) transfer(ctx context.Context, t *daemonTransfer) (string, error) {
+	live, err := d.session(ctx, t.Server)
+	if err != nil {
+		return "", err
+	}
+	session, err := live.get(ctx)
+	if err != nil {
+		return "", err
+	}
+	c := client.New(session)
+	group := d.limiter
+	if t.Op == "put" {
+		group = d.upload
+	}
+	bucket := newTokenBucket()
+	group.Join(bucket, 1)
+	defer group.Leave(bucket)
+	hasher := sha256.New()
+
+	if t.Op == "put" {
+		f, err := os.Open(t.Local)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return "", err
+		}
+		u, err := c.OpenUpload(ctx, t.Remote, client.PutOptions{Size: info.Size(), Meta: putMeta(info, d.preserve)})
+		if err != nil {
+			return "", err
+		}
+		stop := context.AfterFunc(ctx, u.Cancel)
+		defer stop()
+		ctl := newTransferControl(t.Remote, info.Size(), bucket.Reader(io.TeeReader(io.LimitReader(f, info.Size()), hasher)), group)
+		d.start(t, ctl)
+		if _, err := io.Copy(u, ctl); err != nil {
+			u.Cancel()
+			return "", err
+		}
+		if err := u.Close(); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(hasher.Sum(nil)), nil
+	}
+
+	if err := d.clobber.check(t.Local); err != nil {
+		return "", err
+	}
+	dl, err := c.Open(ctx, t.Remote, client.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer dl.Close()
+	stop := context.AfterFunc(ctx, dl.Cancel)
+	defer stop()
+	if err := os.MkdirAll(filepath.Dir(t.Local), 0o755); err != nil {
+		return "", err
+	}
+	// 失敗或取消時不保留暫存檔；daemon 的傳輸不續傳
+	tmp := partialPath(t.Local)
+	defer os.Remove(tmp)
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	hashes := io.Writer(hasher)
+	// verifier 比對伺服器提供的雜湊，演算法同為 sha256 時與 hasher 共用
+	var verifier hash.Hash
+	if dl.Checksum != nil {
+		verifier = hasher
+		if dl.Checksum.Algorithm != "sha256" {
+			verifier = dl.Checksum.New()
+			hashes = io.MultiWriter(hasher, verifier)
+		}
+	}
+	ctl := newTransferControl(t.Remote, dl.Size, bucket.Reader(io.LimitReader(dl, dl.Size)), group)
+	ctl.remote = dl
+	d.start(t, ctl)
+	n, err := io.Copy(io.MultiWriter(out, hashes), ctl)
+	if err != nil {
+		return "", err
+	}
+	if n != dl.Size {
+		return "", fmt.Errorf("下載提早結束 (%d/%d bytes)", n, dl.Size)
+	}
+	if verifier != nil {
+		if err := dl.Checksum.Verify(verifier); err != nil {
+			return "", quarantine(out, t.Local, err)
+		}
+	}
+	if err := out.Sync(); err != nil {
+		return "", err
+	}
+	if err := commitOutput(out, t.Local, d.clobber); err != nil {
+		return "", err
+	}
+	if d.preserve {
+		permissions{}.preserveMeta(t.Local, dl.FileMeta)
+	}
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	if err := recordHistory(historyEntry{Time: time.Now(), Peer: session.RemoteAddr().String(), Remote: t.Remote, Local: t.Local,
+		Size: dl.Size, SHA256: sum, Duration: time.Since(ctl.start)}); err != nil {
+		slog.Warn("無法寫入傳輸紀錄", "err", err)
+	}
+	return sum, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// datagram 標頭：group(4) | index(1) | k(1) | m(1)，之後為片段內容。
+// 不使用 FEC 時 k=1、m=0，group 即為封包序號。除檔案結尾外每個片段長度相同，
+// 最後一個 group 以零補齊，接收端依檔案大小截斷。
+const dgramHeaderLen = 7
+
+// dgramMaxWindow 是同時緩衝的 group 數上限，超出視窗的 datagram 直接丟棄，確保記憶體用量有上限。
+const dgramMaxWindow = 1024
+
+type dgramGroup struct {
+	shards   [][]byte
+	received int
+	first    time.Time
+}
+
+// parseFEC 解析 `--fec k,m` 參數，空字串表示不使用 FEC。
+func parseFEC(s string) (k, m int, err error) {
+	if s == "" {
+		return 1, 0, nil
+	}
+	if _, err := fmt.Sscanf(s, "%d,%d", &k, &m); err != nil {
+		return 0, 0, fmt.Errorf("無效的 --fec %q，格式為 k,m", s)
+	}
+	if k <= 0 || m < 0 || k+m > 255 {
+		return 0, 0, fmt.Errorf("無效的 --fec %q", s)
+	}
+	return k, m, nil
+}
+
+// runStream 以不可靠的 QUIC datagram 接收遠端媒體檔並依序輸出到 w。
+// 每個 group 在收齊（或可由 FEC 還原）時輸出；超過 jitter 仍未完成的 group 直接跳過。
+func runStream(ctx context.Context, session *quic.Conn, filename string, rate, k, m int, jitter time.Duration, w io.Writer) error {
+	if !session.ConnectionState().SupportsDatagrams {
+		return errors.New("伺服器不支援 QUIC datagram")
+	}
+	stream, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stream, "dgram %s %d %d %d\n", client.New(session).EncodePath(filename), rate, k, m)
+
+	totalSize, err := client.ReadSizeHeader(bufio.NewReader(stream))
+	if err != nil {
+		return err
+	}
+
+	var code *rsCode
+	if m > 0 {
+		if code, err = newRSCode(k, m); err != nil {
+			return err
+		}
+	}
+
+	// 伺服器送完所有 datagram 後關閉 stream；之後再等一個 jitter 收尾。
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, stream)
+		close(done)
+	}()
+
+	groups := make(map[uint32]*dgramGroup)
+	var next uint32
+	var lost int64
+	shardSize := 0
+	streamDone := false
+
+	deliver := func(id uint32, g *dgramGroup) error {
+		if code != nil && g.received >= k {
+			if err := code.Reconstruct(g.shards); err != nil {
+				return err
+			}
+		}
+		for j, shard := range g.shards[:k] {
+			pos := (int64(id)*int64(k) + int64(j)) * int64(shardSize)
+			if pos >= totalSize {
+				break
+			}
+			if shard == nil {
+				lost++
+				continue
+			}
+			if rest := totalSize - pos; int64(len(shard)) > rest {
+				shard = shard[:rest]
+			}
+			if _, err := w.Write(shard); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	flush := func(force bool) error {
+		for len(groups) > 0 {
+			if g := groups[next]; g != nil {
+				if g.received < k && !force && time.Since(g.first) < jitter {
+					return nil
+				}
+				if err := deliver(next, g); err != nil {
+					return err
+				}
+			} else {
+				if !force && !expired(groups, next, jitter) {
+					return nil
+				}
+				lost += int64(k)
+			}
+			delete(groups, next)
+			next++
+		}
+		return nil
+	}
+	finished := func() bool {
+		return shardSize > 0 && int64(next)*int64(k)*int64(shardSize) >= totalSize
+	}
+
+	for !finished() {
+		recvCtx, cancel := context.WithTimeout(ctx, jitter)
+		data, err := session.ReceiveDatagram(recvCtx)
+		cancel()
+		select {
+		case <-done:
+			streamDone = true
+		default:
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				if streamDone {
+					break
+				}
+				if err := flush(false); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		if len(data) < dgramHeaderLen {
+			continue
+		}
+		group := binary.BigEndian.Uint32(data[0:4])
+		index := int(data[4])
+		if int(data[5]) != k || int(data[6]) != m || index >= k+m || group < next || group-next >= dgramMaxWindow {
+			continue
+		}
+		payload := data[dgramHeaderLen:]
+		if shardSize == 0 {
+			shardSize = len(payload)
+		}
+		g := groups[group]
+		if g == nil {
+			g = &dgramGroup{shards: make([][]byte, k+m), first: time.Now()}
+			groups[group] = g
+		}
+		if g.shards[index] == nil {
+			g.shards[index] = payload
+			g.received++
+		}
+		if err := flush(false); err != nil {
+			return err
+		}
+	}
+	if err := flush(true); err != nil {
+		return err
+	}
+	if lost > 0 {
+		fmt.Fprintf(os.Stderr, "串流結束: 遺失 %d 個片段\n", lost)
+	}
+	return nil
+}
+
+// expired 回報是否已有比 next 更新且等待超過 jitter 的 group，表示 next 已不會到達。
+func expired(groups map[uint32]*dgramGroup, next uint32, jitter time.Duration) bool {
+	for id, g := range groups {
+		if id > next && time.Since(g.first) >= jitter {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// 去重上傳的協定（皆在各自的 stream 上，請求送完即關閉寫入端）：
+//
+//	have <n>\n<hash>\n...            伺服器回傳其中缺少的 hash，每行一個
+//	chunks\n(<hash> <size>\n<data>)*  上傳 chunk，回一行 OK
+//	commit <remote> <size> <n>\n<hash>\n...  依序組合 chunk 成檔案，回一行 OK
+//
+// 任何回應以 ERR 開頭表示失敗。
+
+// chunkIndex 記錄某個伺服器上已確認存在的 chunk，之後上傳時不必再詢問這些 chunk。
+type chunkIndex struct {
+	path  string
+	known map[string]bool
+}
+
+func chunkIndexPath(server string) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	name := strings.NewReplacer(":", "_", "/", "_", "[", "", "]", "").Replace(server)
+	return filepath.Join(dir, "chunks", name+".idx"), nil
+}
+
+func loadChunkIndex(server string) (*chunkIndex, error) {
+	path, err := chunkIndexPath(server)
+	if err != nil {
+		return nil, err
+	}
+	ix := &chunkIndex{path: path, known: make(map[string]bool)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ix, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if h := strings.TrimSpace(scanner.Text()); h != "" {
+			ix.known[h] = true
+		}
+	}
+	return ix, scanner.Err()
+}
+
+// add 把新確認的 chunk 附加到索引檔。
+func (ix *chunkIndex) add(hashes []string) error {
+	if err := os.MkdirAll(filepath.Dir(ix.path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(ix.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, h := range hashes {
+		if !ix.known[h] {
+			ix.known[h] = true
+			fmt.Fprintln(w, h)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (ix *chunkIndex) reset() error {
+	ix.known = make(map[string]bool)
+	if err := os.Remove(ix.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+type dedupResult struct {
+	Local       string `json:"local"`
+	Remote      string `json:"remote"`
+	Size        int64  `json:"size"`
+	Chunks      int    `json:"chunks"`
+	SentChunks  int    `json:"sent_chunks"`
+	SentBytes   int64  `json:"sent_bytes"`
+	IndexedHits int    `json:"indexed_hits"` // 依本機索引略過詢問的 chunk 數
+}
+
+// runDedupPut 以內容定義切塊上傳 local：只傳送伺服器還沒有的 chunk，再請伺服器組合成 remote。
+// 本機索引記得已上傳過的 chunk，重複上傳 VM 映像或備份時幾乎不需傳送資料。
+func runDedupPut(ctx context.Context, session *quic.Conn, server, local, remote string) error {
+	ix, err := loadChunkIndex(server)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var chunks []chunk
+	if err := chunkReader(f, func(c chunk, _ []byte) error {
+		chunks = append(chunks, c)
+		return nil
+	}); err != nil {
+		return err
+	}
+	hashes := make([]string, len(chunks))
+	for i, c := range chunks {
+		hashes[i] = c.Hash
+	}
+
+	res := dedupResult{Local: local, Remote: remote, Size: info.Size(), Chunks: len(chunks)}
+	for attempt := 0; ; attempt++ {
+		if err := sendMissingChunks(ctx, session, f, chunks, ix, &res); err != nil {
+			return err
+		}
+		err := commitChunks(ctx, session, remote, info.Size(), hashes)
+		if err == nil {
+			break
+		}
+		if attempt > 0 || res.IndexedHits == 0 {
+			return err
+		}
+		// 伺服器可能已清除索引中記錄的 chunk，清空索引後重新詢問一次
+		con.Printf("組合失敗 (%v)，清除本機 chunk 索引後重試\n", err)
+		if err := ix.reset(); err != nil {
+			return err
+		}
+	}
+	if err := ix.add(hashes); err != nil {
+		con.Printf("無法更新 chunk 索引: %v\n", err)
+	}
+	con.Printf("已上傳 %s -> %s: %d 個 chunk 中傳送 %d 個 (%s / %s)\n",
+		local, remote, res.Chunks, res.SentChunks, humanSize(res.SentBytes), humanSize(res.Size))
+	con.Result(res)
+	return nil
+}
+
+// sendMissingChunks 詢問伺服器缺少哪些 chunk，並從 f 讀出這些 chunk 上傳。
+func sendMissingChunks(ctx context.Context, session *quic.Conn, f *os.File, chunks []chunk, ix *chunkIndex, res *dedupResult) error {
+	res.SentChunks, res.SentBytes, res.IndexedHits = 0, 0, 0
+	seen := make(map[string]bool)
+	var unknown []string
+	for _, c := range chunks {
+		if seen[c.Hash] {
+			continue
+		}
+		seen[c.Hash] = true
+		if ix.known[c.Hash] {
+			res.IndexedHits++
+		} else {
+			unknown = append(unknown, c.Hash)
+		}
+	}
+	missing, err := queryMissing(ctx, session, unknown)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	stream, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(stream)
+	fmt.Fprintln(w, "chunks")
+	buf := make([]byte, cdcMaxSize)
+	for _, c := range chunks {
+		if !missing[c.Hash] {
+			continue
+		}
+		delete(missing, c.Hash)
+		data := buf[:c.Size]
+		if _, err := f.ReadAt(data, c.Offset); err != nil {
+			stream.CancelWrite(0)
+			return err
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != c.Hash {
+			stream.CancelWrite(0)
+			return fmt.Errorf("上傳期間 %s 內容改變", f.Name())
+		}
+		fmt.Fprintf(w, "%s %d\n", c.Hash, c.Size)
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("上傳失敗: %w", err)
+		}
+		res.SentChunks++
+		res.SentBytes += c.Size
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("上傳失敗: %w", err)
+	}
+	stream.Close()
+	reply, err := client.ReadHeaderLine(bufio.NewReader(stream))
+	if err != nil {
+		return fmt.Errorf("無法讀取上傳結果: %w", err)
+	}
+	return client.CheckServerError(reply)
+}
+
+// queryMissing 回傳 hashes 中伺服器缺少的 chunk。
+func queryMissing(ctx context.Context, session *quic.Conn, hashes []string) (map[string]bool, error) {
+	missing := make(map[string]bool)
+	if len(hashes) == 0 {
+		return missing, nil
+	}
+	stream, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(stream)
+	fmt.Fprintf(w, "have %d\n", len(hashes))
+	for _, h := range hashes {
+		fmt.Fprintln(w, h)
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	stream.Close()
+
+	r := bufio.NewReader(stream)
+	for {
+		line, err := client.ReadHeaderLine(r)
+		if err != nil {
+			if err == io.EOF {
+				return missing, nil
+			}
+			return nil, err
+		}
+		if err := client.CheckServerError(line); err != nil {
+			return nil, err
+		}
+		missing[line] = true
+	}
+}
+
+// commitChunks 請伺服器把 hashes 依序組合成 remote。
+func commitChunks(ctx context.Context, session *quic.Conn, remote string, size int64, hashes []string) error {
+	c := client.New(session)
+	if err := c.CheckPath(remote); err != nil {
+		return err
+	}
+	stream, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(stream)
+	fmt.Fprintf(w, "commit %s %d %d\n", c.EncodePath(remote), size, len(hashes))
+	for _, h := range hashes {
+		fmt.Fprintln(w, h)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	stream.Close()
+	reply, err := client.ReadHeaderLine(bufio.NewReader(stream))
+	if err != nil {
+		return fmt.Errorf("無法讀取組合結果: %w", err)
+	}
+	return client.CheckServerError(reply)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// delta 區塊大小的範圍；實際大小約為本機檔案大小的平方根（同 rsync），兼顧簽章量與比對的精細度。
+const (
+	deltaMinBlock = 2 << 10
+	deltaMaxBlock = 128 << 10
+)
+
+// deltaBlockSize 依本機檔案大小選擇區塊大小，取 1 KiB 的倍數。
+func deltaBlockSize(size int64) int64 {
+	bs := int64(math.Sqrt(float64(size))) &^ (1<<10 - 1)
+	return min(max(bs, deltaMinBlock), deltaMaxBlock)
+}
+
+// weakSum 是 rsync 的滾動校驗和：a 為位元組總和、b 為加權總和，各取 16 位元。
+// 伺服器可在 O(1) 內把視窗移動一個位元組，逐一位置比對本機的區塊。
+func weakSum(block []byte) uint32 {
+	var a, b uint32
+	l := uint32(len(block))
+	for i, c := range block {
+		a += uint32(c)
+		b += (l - uint32(i)) * uint32(c)
+	}
+	return a&0xffff | b<<16
+}
+
+// getDelta 實作 get --delta：以本機既有的舊版本 local 為基礎，只下載變更的部分。
+// 送出 `delta <file> <blocksize> <count>` 及每個區塊一行 "<weak> <strong>"，伺服器以滾動校驗和
+// 找出遠端檔案中與本機區塊相同的位置，回傳 "<size> <sha256> [mtime=<time>] [mode=<perm>]" 標頭，接著是一連串指令：
+// `C <index> <count>` 複製本機第 index 起的 count 個區塊、`D <length>` 後接 length 位元組的新資料、
+// `E` 結束。重建的檔案寫入暫存檔，SHA-256 與標頭相符才取代 local。
+func getDelta(ctx context.Context, session *quic.Conn, filename, local string, opts getOptions) error {
+	c := client.New(session)
+	if err := c.CheckPath(filename); err != nil {
+		return err
+	}
+	src, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	bs := deltaBlockSize(info.Size())
+	count := (info.Size() + bs - 1) / bs
+
+	stream, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	stop := context.AfterFunc(ctx, func() { stream.CancelRead(0) })
+	defer stop()
+	w := bufio.NewWriter(stream)
+	fmt.Fprintf(w, "delta %s %d %d\n", c.EncodePath(filename), bs, count)
+	buf := make([]byte, bs)
+	for i := int64(0); i < count; i++ {
+		n, err := io.ReadFull(src, buf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		strong := sha256.Sum256(buf[:n])
+		fmt.Fprintf(w, "%08x %s\n", weakSum(buf[:n]), hex.EncodeToString(strong[:16]))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(stream)
+	header, err := client.ReadHeaderLine(r)
+	if err != nil {
+		return fmt.Errorf("無法讀取 delta 標頭: %w", err)
+	}
+	if err := client.CheckServerError(header); err != nil {
+		return err
+	}
+	sizeField, want, _ := strings.Cut(header, " ")
+	want, rest, _ := strings.Cut(want, " ")
+	size, err := client.ParseSize(sizeField)
+	if err != nil || len(want) != sha256.Size*2 {
+		return fmt.Errorf("無效的 delta 標頭 %q", header)
+	}
+	if opts.maxSize > 0 && size > opts.maxSize {
+		return fmt.Errorf("%s 大小 %d bytes 超過上限 %d", filename, size, opts.maxSize)
+	}
+
+	out, err := os.Create(partialPath(local))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	keep := false
+	defer func() {
+		if !keep {
+			os.Remove(out.Name())
+		}
+	}()
+	if err := opts.perms.applyFile(out); err != nil {
+		return err
+	}
+
+	progress := NewProgressReader(nil, size)
+	progress.name = filename
+	progress.StartMonitor()
+	defer progress.Stop()
+	bucket := newTokenBucket()
+	opts.limiter.Join(bucket, opts.weight)
+	defer opts.limiter.Leave(bucket)
+	literal := bucket.Reader(opts.stats.Track(int64(stream.StreamID()), filename, r))
+
+	con.Event(transferEvent{Event: "start", File: filename, Total: size, Local: local})
+	start := time.Now()
+	hasher := sha256.New()
+	dst := io.MultiWriter(out, hasher)
+	var reused, fetched int64 // 沿用本機檔案與從伺服器下載的位元組數
+loop:
+	for {
+		line, err := client.ReadHeaderLine(r)
+		if err != nil {
+			return fmt.Errorf("delta 指令不完整: %w", err)
+		}
+		op, arg, _ := strings.Cut(line, " ")
+		var n int64
+		switch op {
+		case "C":
+			idxField, cntField, _ := strings.Cut(arg, " ")
+			idx, err1 := strconv.ParseInt(idxField, 10, 64)
+			cnt, err2 := strconv.ParseInt(cntField, 10, 64)
+			if err1 != nil || err2 != nil || idx < 0 || cnt <= 0 || idx+cnt > count {
+				return fmt.Errorf("無效的 delta 指令 %q", line)
+			}
+			off := idx * bs
+			n = min(cnt*bs, info.Size()-off)
+			if _, err := io.Copy(dst, io.NewSectionReader(src, off, n)); err != nil {
+				return err
+			}
+			reused += n
+		case "D":
+			if n, err = client.ParseSize(arg); err != nil {
+				return fmt.Errorf("無效的 delta 指令 %q", line)
+			}
+			if _, err := io.CopyN(dst, literal, n); err != nil {
+				return fmt.Errorf("無法下載變更的資料: %w", err)
+			}
+			fetched += n
+		case "E":
+			break loop
+		default:
+			return fmt.Errorf("無效的 delta 指令 %q", line)
+		}
+		progress.readBytes.Add(n)
+		if reused+fetched > size {
+			return fmt.Errorf("重建的檔案超過遠端大小 %d bytes", size)
+		}
+	}
+	progress.Stop()
+	if reused+fetched != size {
+		return fmt.Errorf("重建的檔案為 %d bytes，與遠端大小 %d bytes 不符", reused+fetched, size)
+	}
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	if sum != want {
+		keep = true // 已改名為 .corrupt
+		return quarantine(out, local, &client.ChecksumError{Algorithm: "sha256", Want: want, Got: sum})
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if err := commitOutput(out, local, opts.clobber); err != nil {
+		return err
+	}
+	keep = true
+	if opts.preserve {
+		opts.perms.preserveMeta(local, client.ParseFileMeta(strings.Fields(rest)))
+	}
+	con.Printf("%s: 沿用本機 %s，下載 %s / %s\n", local, humanSize(reused), humanSize(fetched), humanSize(size))
+	finishGet(session, filename, local, size, sum, start, opts)
+	return nil
+}
+
+// deltaUnsupported 判斷伺服器是否不認得 delta 指令，此時改為完整下載。
+func deltaUnsupported(err error) bool {
+	var se *client.ServerError
+	return errors.As(err, &se) && se.Code == client.StatusBadRequest
+}
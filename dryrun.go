@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// dryRunAction 是 --dry-run 列出的一個動作，--json 時每個動作輸出為一行 JSON。
+type dryRunAction struct {
+	Action string `json:"action"` // download | overwrite | backup | skip | conflict | delete
+	Remote string `json:"remote,omitempty"`
+	Local  string `json:"local,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+var dryRunLabels = map[string]string{
+	"download":  "下載",
+	"overwrite": "覆寫",
+	"backup":    "覆寫（舊檔改名為 ~）",
+	"skip":      "略過（已存在）",
+	"conflict":  "衝突（已存在，不會覆寫）",
+	"delete":    "刪除",
+}
+
+func (a dryRunAction) print() {
+	if con.json || con.format != nil {
+		con.Result(a)
+		return
+	}
+	switch {
+	case a.Remote != "" && a.Local != "":
+		con.Printf("%s: %s -> %s (%s)\n", dryRunLabels[a.Action], a.Remote, a.Local, humanSize(a.Size))
+	case a.Remote != "":
+		con.Printf("%s: 遠端 %s\n", dryRunLabels[a.Action], a.Remote)
+	default:
+		con.Printf("%s: %s\n", dryRunLabels[a.Action], a.Local)
+	}
+}
+
+// planDownloads 是 getFiles 的試執行：依 clobber 策略列出每個檔案會下載、覆寫、略過或衝突，不傳輸任何資料。
+// 有衝突時回傳錯誤，與實際執行時會失敗一致。
+func planDownloads(files []remoteFile, opts getOptions) error {
+	var (
+		count, conflicts int
+		total            int64
+	)
+	for _, f := range files {
+		local := f.local
+		if len(opts.encryptTo) > 0 {
+			local = encryptedName(local)
+		}
+		a := dryRunAction{Action: "download", Remote: f.remote, Local: local, Size: f.size}
+		if _, err := os.Lstat(local); err == nil {
+			switch opts.clobber {
+			case clobberSkip:
+				a.Action = "skip"
+			case clobberRefuse:
+				a.Action = "conflict"
+			case clobberBackup:
+				a.Action = "backup"
+			default:
+				a.Action = "overwrite"
+			}
+		}
+		a.print()
+		switch a.Action {
+		case "skip":
+		case "conflict":
+			conflicts++
+		default:
+			count++
+			total += f.size
+		}
+	}
+	con.Printf("試執行: 會下載 %d 個檔案，共 %s；沒有傳輸任何資料\n", count, humanSize(total))
+	if conflicts > 0 {
+		return &existsError{fmt.Sprintf("%d 個目的檔已存在；使用 --force 覆寫、--backup 保留舊檔或 --no-clobber 略過", conflicts)}
+	}
+	return nil
+}
+
+// dryRunSupported 回報 --dry-run 是否支援 args 指定的指令（不含伺服器位址）。
+// 其他指令沒有試執行的版本，會真的執行，因此一律拒絕。
+func dryRunSupported(args []string) bool {
+	switch args[0] {
+	case "sync", "rm":
+		return true
+	case "get":
+		// 與 run 分派 get 的方式相同：-r、多個參數或萬用字元都會經過 getFiles
+		rest := args[1:]
+		return len(rest) > 0 && rest[0] != "--tar" && (rest[0] == "-r" || len(rest) > 1 || hasGlob(rest[0]))
+	}
+	return false
+}
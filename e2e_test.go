@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+
+	"go-client/testserver"
+)
+
+// 端對端測試以子行程執行測試執行檔本身：設定了 DATA_CLI_TEST_MAIN 時它就是 data_cli，
+// 結束碼、暫存檔與 stdout/stderr 都與實際執行相同。
+func TestMain(m *testing.M) {
+	if os.Getenv("DATA_CLI_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+type cliResult struct {
+	stdout, stderr string
+	code           int
+}
+
+// cliTimeout 是單次執行的時間上限：卡住的指令會被終止，以失敗的結束碼回報而不是拖到整個測試逾時。
+const cliTimeout = 30 * time.Second
+
+// cli 在 dir 中執行 `data_cli --insecure --progress none <args...>`，狀態與設定目錄都在 dir 之下。
+func cli(t *testing.T, dir string, args ...string) cliResult {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(t.Context(), cliTimeout)
+	defer cancel()
+	cmd := cliCommand(ctx, dir, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatal(err)
+	}
+	return cliResult{stdout.String(), stderr.String(), cmd.ProcessState.ExitCode()}
+}
+
+func cliCommand(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, os.Args[0], append([]string{"--insecure", "--progress", "none"}, args...)...)
+	cmd.Dir = dir
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "QUIC_CLIENT_") && !strings.HasPrefix(kv, "XDG_") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.Env = append(cmd.Env, "DATA_CLI_TEST_MAIN=1", "HOME="+dir,
+		"XDG_STATE_HOME="+filepath.Join(dir, ".state"),
+		"XDG_CACHE_HOME="+filepath.Join(dir, ".cache"),
+		"XDG_CONFIG_HOME="+filepath.Join(dir, ".config"))
+	return cmd
+}
+
+// startCLI 在背景執行 data_cli，測試結束時終止。
+func startCLI(t *testing.T, dir string, args ...string) *exec.Cmd {
+	t.Helper()
+	cmd := cliCommand(t.Context(), dir, args...)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cmd.Process.Kill(); cmd.Wait() })
+	return cmd
+}
+
+func startServer(t *testing.T, opts testserver.Options) *testserver.Server {
+	t.Helper()
+	srv, err := testserver.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func randomFile(t *testing.T, path string, size int) []byte {
+	t.Helper()
+	data := make([]byte, size)
+	rand.Read(data)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCLIPutGet(t *testing.T) {
+	srv := startServer(t, testserver.Options{})
+	dir := t.TempDir()
+	data := randomFile(t, filepath.Join(dir, "up.bin"), 300_000)
+
+	if r := cli(t, dir, srv.Addr(), "put", "up.bin", "remote.bin"); r.code != 0 {
+		t.Fatalf("put: exit %d\n%s", r.code, r.stderr)
+	}
+	if got, _ := os.ReadFile(filepath.Join(srv.Root, "remote.bin")); !bytes.Equal(got, data) {
+		t.Fatalf("uploaded %d bytes, want %d", len(got), len(data))
+	}
+	for _, alg := range []string{"sha256", "sha512"} {
+		out := filepath.Join(dir, alg+".bin")
+		if r := cli(t, dir, "--checksum", alg, "-o", out, srv.Addr(), "get", "remote.bin"); r.code != 0 {
+			t.Fatalf("get --checksum %s: exit %d\n%s", alg, r.code, r.stderr)
+		}
+		if got, _ := os.ReadFile(out); !bytes.Equal(got, data) {
+			t.Errorf("get --checksum %s: downloaded %d bytes, want %d", alg, len(got), len(data))
+		}
+	}
+	if !slices.Contains(srv.Requests(), "get remote.bin 0 sum=sha512") {
+		t.Errorf("requests = %q", srv.Requests())
+	}
+}
+
+func TestCLIGetPriority(t *testing.T) {
+	srv := startServer(t, testserver.Options{})
+	dir := t.TempDir()
+	a := randomFile(t, filepath.Join(srv.Root, "a.bin"), 1000)
+	b := randomFile(t, filepath.Join(srv.Root, "b.bin"), 1000)
+
+	// 高優先權的下載結束後必須從排程登出，否則之後一般優先權的 b.bin 會一直等下去
+	r := cli(t, dir, "--priority", "a.bin=high", srv.Addr(), "get", "a.bin", "b.bin")
+	if r.code != 0 {
+		t.Fatalf("exit %d\n%s", r.code, r.stderr)
+	}
+	for name, want := range map[string][]byte{"a.bin": a, "b.bin": b} {
+		if got, _ := os.ReadFile(filepath.Join(dir, name)); !bytes.Equal(got, want) {
+			t.Errorf("%s: downloaded %d bytes, want %d", name, len(got), len(want))
+		}
+	}
+}
+
+func TestCLIJobsQueued(t *testing.T) {
+	srv := startServer(t, testserver.Options{})
+	dir := t.TempDir()
+	// 控制 socket 放在測試自己的暫存目錄，不會看到其他行程的傳輸
+	t.Setenv("TMPDIR", dir)
+	for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+		randomFile(t, filepath.Join(srv.Root, name), 300_000)
+	}
+	startCLI(t, dir, "--limit", "50k", "-o", "out/", srv.Addr(), "get", "a.bin", "b.bin", "c.bin")
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		r := cli(t, dir, "--json", "jobs")
+		var states []string
+		for _, line := range strings.Split(strings.TrimSpace(r.stdout), "\n") {
+			var res struct{ Name, State string }
+			if json.Unmarshal([]byte(line), &res) == nil && res.Name != "" {
+				states = append(states, res.Name+"="+res.State)
+			}
+		}
+		slices.Sort(states)
+		if slices.Equal(states, []string{"a.bin=running", "b.bin=queued", "c.bin=queued"}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs = %q, want a.bin running and b.bin, c.bin queued\n%s", states, r.stderr)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestCLIGetChecksumMismatch(t *testing.T) {
+	srv := startServer(t, testserver.Options{Corrupt: true})
+	dir := t.TempDir()
+	randomFile(t, filepath.Join(srv.Root, "a.bin"), 100_000)
+
+	r := cli(t, dir, "-o", "a.bin", srv.Addr(), "get", "a.bin")
+	if r.code != 6 {
+		t.Fatalf("exit %d, want 6 (checksum)\n%s", r.code, r.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.bin")); err == nil {
+		t.Error("corrupt download replaced the destination")
+	}
+	// --no-verify 時照樣寫入
+	if r := cli(t, dir, "--no-verify", "-o", "a.bin", srv.Addr(), "get", "a.bin"); r.code != 0 {
+		t.Errorf("--no-verify: exit %d\n%s", r.code, r.stderr)
+	}
+}
+
+func TestCLIResume(t *testing.T) {
+	const size, step = 300_000, 100_000
+	srv := startServer(t, testserver.Options{AbortAfter: step})
+	dir := t.TempDir()
+	data := randomFile(t, filepath.Join(srv.Root, "big.bin"), size)
+
+	r := cli(t, dir, "-o", "big.bin", srv.Addr(), "get", "big.bin")
+	if r.code != 7 {
+		t.Fatalf("aborted get: exit %d, want 7 (network)\n%s", r.code, r.stderr)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "big.bin.partial")); err != nil || info.Size() != step {
+		t.Fatalf("partial file after the abort: %v, %v", info, err)
+	}
+
+	// 每次續傳多收到 step 個位元組，第三次完成
+	for i := 1; i < size/step; i++ {
+		r = cli(t, dir, "--resume", "-o", "big.bin", srv.Addr(), "get", "big.bin")
+	}
+	if r.code != 0 {
+		t.Fatalf("resume: exit %d\n%s", r.code, r.stderr)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "big.bin")); !bytes.Equal(got, data) {
+		t.Errorf("resumed download differs (%d bytes)", len(got))
+	}
+	reqs := srv.Requests()
+	for _, want := range []string{"get big.bin 100000", "get big.bin 200000"} {
+		if !slices.Contains(reqs, want) {
+			t.Errorf("missing request %q in %q", want, reqs)
+		}
+	}
+}
+
+func TestCLIRetries(t *testing.T) {
+	srv := startServer(t, testserver.Options{AbortAfter: 100_000})
+	dir := t.TempDir()
+	data := randomFile(t, filepath.Join(srv.Root, "big.bin"), 350_000)
+
+	r := cli(t, dir, "--retries", "5", "--retry-backoff", "10ms", "-o", "big.bin", srv.Addr(), "get", "big.bin")
+	if r.code != 0 {
+		t.Fatalf("exit %d\n%s", r.code, r.stderr)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "big.bin")); !bytes.Equal(got, data) {
+		t.Errorf("downloaded %d bytes, want %d", len(got), len(data))
+	}
+}
+
+func TestCLIServerErrors(t *testing.T) {
+	srv := startServer(t, testserver.Options{Token: "secret"})
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(srv.Root, "d"), 0o755)
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"no token", []string{srv.Addr(), "ls"}, 9},
+		{"wrong token", []string{"--token", "wrong", srv.Addr(), "ls"}, 9},
+		{"not found", []string{"--token", "secret", "-o", "x", srv.Addr(), "get", "missing.bin"}, 3},
+		{"exists", []string{"--token", "secret", srv.Addr(), "mkdir", "d"}, 5},
+		{"ok", []string{"--token", "secret", srv.Addr(), "mkdir", "e"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if r := cli(t, dir, tt.args...); r.code != tt.code {
+				t.Errorf("exit %d, want %d\n%s", r.code, tt.code, r.stderr)
+			}
+		})
+	}
+}
+
+func TestCLIKnownHosts(t *testing.T) {
+	srv := startServer(t, testserver.Options{})
+	dir := t.TempDir()
+	knownHosts := filepath.Join(dir, ".config", "quic-client", "known_hosts")
+
+	if r := cli(t, dir, "--tofu", srv.Addr(), "ls"); r.code != 0 {
+		t.Fatalf("first connect: exit %d\n%s", r.code, r.stderr)
+	}
+	recorded, _ := os.ReadFile(knownHosts)
+	if !strings.HasPrefix(string(recorded), srv.Addr()+" sha256//") {
+		t.Fatalf("known_hosts = %q", recorded)
+	}
+
+	// 伺服器換了金鑰：--accept-new 也不能覆寫紀錄
+	changed := srv.Addr() + " sha256//AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=\n"
+	os.WriteFile(knownHosts, []byte(changed), 0o600)
+	for _, flag := range []string{"--tofu", "--accept-new"} {
+		r := cli(t, dir, flag, srv.Addr(), "ls")
+		if r.code == 0 || !strings.Contains(r.stderr, "--forget") {
+			t.Errorf("%s with a changed key: exit %d\n%s", flag, r.code, r.stderr)
+		}
+		if got, _ := os.ReadFile(knownHosts); string(got) != changed {
+			t.Errorf("%s rewrote known_hosts: %q", flag, got)
+		}
+	}
+
+	if r := cli(t, dir, "--forget", srv.Addr()); r.code != 0 {
+		t.Fatalf("--forget: exit %d\n%s", r.code, r.stderr)
+	}
+	if r := cli(t, dir, "--accept-new", srv.Addr(), "ls"); r.code != 0 {
+		t.Errorf("connect after --forget: exit %d\n%s", r.code, r.stderr)
+	}
+	if got, _ := os.ReadFile(knownHosts); string(got) != string(recorded) {
+		t.Errorf("known_hosts after --forget = %q, want %q", got, recorded)
+	}
+}
+
+func TestCLIUnknownCommand(t *testing.T) {
+	srv := startServer(t, testserver.Options{})
+	dir := t.TempDir()
+	for _, args := range [][]string{{"bogus", "x"}, {"get"}} {
+		r := cli(t, dir, append([]string{srv.Addr()}, args...)...)
+		if r.code != 1 || !strings.Contains(r.stderr, "用法") && !strings.Contains(r.stderr, "未知的指令") {
+			t.Errorf("%q: exit %d, want a usage error\n%s", args, r.code, r.stderr)
+		}
+	}
+	// 用法與錯誤一起寫到 stderr，stdout 保持空白
+	if r := cli(t, dir); r.code != 1 || r.stdout != "" || !strings.Contains(r.stderr, "用法") {
+		t.Errorf("no arguments: exit %d, stdout %q\n%s", r.code, r.stdout, r.stderr)
+	}
+}
+
+func TestCLIListPagination(t *testing.T) {
+	srv := startServer(t, testserver.Options{PageSize: 2})
+	dir := t.TempDir()
+	names := []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"}
+	for _, n := range names {
+		os.WriteFile(filepath.Join(srv.Root, n), []byte(n), 0o644)
+	}
+	r := cli(t, dir, srv.Addr(), "ls")
+	if r.code != 0 {
+		t.Fatalf("exit %d\n%s", r.code, r.stderr)
+	}
+	for _, n := range names {
+		if !strings.Contains(r.stdout, n) {
+			t.Errorf("ls output lacks %s:\n%s", n, r.stdout)
+		}
+	}
+	var cursors []string
+	for _, req := range srv.Requests() {
+		if strings.Contains(req, "cursor=") {
+			cursors = append(cursors, req)
+		}
+	}
+	if len(cursors) != 2 {
+		t.Errorf("requests for later pages = %q, want 2", cursors)
+	}
+}
+
+func TestCLIGetMultiTerabyte(t *testing.T) {
+	// 伺服器宣告 4 TiB，實際只送 200 KB：下載失敗、保留已收到的部分，記憶體與磁碟用量與宣告的大小無關
+	srv := startServer(t, testserver.Options{Missing: 4 << 40})
+	dir := t.TempDir()
+	data := randomFile(t, filepath.Join(srv.Root, "huge.bin"), 200_000)
+
+	r := cli(t, dir, "-o", "huge.bin", srv.Addr(), "get", "huge.bin")
+	if r.code != 1 || !strings.Contains(r.stderr, "下載提早結束") {
+		t.Fatalf("exit %d, want 1 with a short-download error\n%s", r.code, r.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "huge.bin")); err == nil {
+		t.Error("short download created the destination")
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "huge.bin.partial")); !bytes.Equal(got, data) {
+		t.Errorf("partial file has %d bytes, want the %d bytes received", len(got), len(data))
+	}
+
+	r = cli(t, dir, "--max-filesize", "1T", "-o", "other.bin", srv.Addr(), "get", "huge.bin")
+	if r.code != 1 || !strings.Contains(r.stderr, "超過上限") {
+		t.Errorf("--max-filesize 1T: exit %d\n%s", r.code, r.stderr)
+	}
+}
+
+func TestCLIGetStdoutShort(t *testing.T) {
+	srv := startServer(t, testserver.Options{Missing: 1000})
+	dir := t.TempDir()
+	data := randomFile(t, filepath.Join(srv.Root, "a.bin"), 5000)
+
+	// 沒有校驗時也必須以失敗結束，管線才知道 stdout 上的內容不完整
+	r := cli(t, dir, "--no-verify", "-o", "-", srv.Addr(), "get", "a.bin")
+	if r.code != 1 || !strings.Contains(r.stderr, "下載提早結束") {
+		t.Fatalf("exit %d, want 1 with a short-download error\n%s", r.code, r.stderr)
+	}
+	if r.stdout != string(data) {
+		t.Errorf("stdout has %d bytes, want the %d bytes received", len(r.stdout), len(data))
+	}
+}
+
+func TestCLIAge(t *testing.T) {
+	srv := startServer(t, testserver.Options{})
+	dir := t.TempDir()
+	id, idFile, recipientFile := ageFixture(t)
+	data := randomFile(t, filepath.Join(srv.Root, "secret.bin"), 150_000)
+
+	// get --encrypt-to 只在磁碟上留下加密後的檔案
+	if r := cli(t, dir, "--encrypt-to", recipientFile, "-o", "secret.bin", srv.Addr(), "get", "secret.bin"); r.code != 0 {
+		t.Fatalf("get --encrypt-to: exit %d\n%s", r.code, r.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "secret.bin")); err == nil {
+		t.Error("plaintext written next to the encrypted file")
+	}
+	got, err := decryptFile(t, filepath.Join(dir, "secret.bin.age"), []age.Identity{id})
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("decrypting the download: %d bytes, %v", len(got), err)
+	}
+
+	// put --decrypt 上傳解密後的內容；損毀的加密檔在上傳前即被拒絕
+	if r := cli(t, dir, "--decrypt", idFile, srv.Addr(), "put", "secret.bin.age", "plain.bin"); r.code != 0 {
+		t.Fatalf("put --decrypt: exit %d\n%s", r.code, r.stderr)
+	}
+	if got, _ := os.ReadFile(filepath.Join(srv.Root, "plain.bin")); !bytes.Equal(got, data) {
+		t.Errorf("uploaded %d bytes, want the %d plaintext bytes", len(got), len(data))
+	}
+	enc, _ := os.ReadFile(filepath.Join(dir, "secret.bin.age"))
+	os.WriteFile(filepath.Join(dir, "cut.age"), enc[:len(enc)-100], 0o644)
+	if r := cli(t, dir, "--decrypt", idFile, srv.Addr(), "put", "cut.age", "cut.bin"); r.code == 0 {
+		t.Error("put --decrypt of a truncated file succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(srv.Root, "cut.bin")); err == nil {
+		t.Error("truncated upload left a file on the server")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// 行程的結束碼，讓腳本不必解析錯誤訊息就能區分失敗原因。
+const (
+	exitFailure     = 1  // 其他錯誤
+	exitNotFound    = 3  // 遠端或本機檔案不存在
+	exitPermission  = 4  // 權限不足
+	exitExists      = 5  // 目標已存在（未使用 --force）
+	exitChecksum    = 6  // 下載內容與雜湊不符
+	exitNetwork     = 7  // 無法連線、逾時或連線中斷
+	exitServer      = 8  // 伺服器回報的其他錯誤
+	exitAuth        = 9  // 伺服器要求認證或拒絕了 token
+	exitQuota       = 10 // 已達 --quota 的流量配額
+	exitInterrupted = 130
+)
+
+// exitStatusError 指定行程的結束碼，例如 `lock -- command` 沿用命令的結束碼。
+// 指令回傳它而不是直接呼叫 os.Exit，main 仍會關閉連線並寫入稽核紀錄。
+type exitStatusError struct {
+	code int
+	err  error
+}
+
+func (e *exitStatusError) Error() string { return e.err.Error() }
+func (e *exitStatusError) Unwrap() error { return e.err }
+
+// exitCode 依錯誤類型決定結束碼。
+func exitCode(err error) int {
+	var (
+		checksumErr  *client.ChecksumError
+		serverErr    *client.ServerError
+		idleErr      *quic.IdleTimeoutError
+		handshakeErr *quic.HandshakeTimeoutError
+		transportErr *quic.TransportError
+		appErr       *quic.ApplicationError
+		netErr       net.Error
+		quotaErr     *quotaError
+		statusErr    *exitStatusError
+	)
+	switch {
+	case interrupted.Load():
+		return exitInterrupted
+	case errors.As(err, &statusErr):
+		return statusErr.code
+	case errors.As(err, &checksumErr):
+		return exitChecksum
+	case errors.As(err, &quotaErr):
+		return exitQuota
+	case errors.Is(err, client.ErrUnauthorized):
+		return exitAuth
+	case errors.Is(err, fs.ErrNotExist):
+		return exitNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return exitPermission
+	case errors.Is(err, fs.ErrExist):
+		return exitExists
+	case errors.As(err, &serverErr):
+		return exitServer
+	case errors.As(err, &idleErr), errors.As(err, &handshakeErr), errors.As(err, &transportErr),
+		errors.As(err, &appErr), errors.As(err, &netErr), errors.Is(err, context.DeadlineExceeded):
+		return exitNetwork
+	}
+	return exitFailure
+}
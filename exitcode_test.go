@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"testing"
+
+	"go-client/client"
+)
+
+func TestExitCode(t *testing.T) {
+	cmdErr := exec.Command("sh", "-c", "exit 42").Run()
+	if cmdErr == nil {
+		t.Fatal("sh -c 'exit 42' succeeded")
+	}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"other", errors.New("x"), exitFailure},
+		{"not found", fmt.Errorf("a: %w", &client.ServerError{Code: client.StatusNotFound}), exitNotFound},
+		{"local not found", fs.ErrNotExist, exitNotFound},
+		{"exists", &client.ServerError{Code: client.StatusConflict}, exitExists},
+		{"auth", &client.ServerError{Code: client.StatusUnauthorized}, exitAuth},
+		{"server", &client.ServerError{Code: client.StatusInternal}, exitServer},
+		{"checksum", fmt.Errorf("a: %w", &client.ChecksumError{}), exitChecksum},
+		{"timeout", context.DeadlineExceeded, exitNetwork},
+		{"command status", fmt.Errorf("lock: %w", &exitStatusError{code: 42, err: cmdErr}), 42},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
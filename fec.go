@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// GF(2^8) 運算表，生成多項式 x^8+x^4+x^3+x^2+1 (0x11d)。
+var gfExp, gfLog = func() (exp [512]byte, log [256]byte) {
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+	return
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// rsCode 是系統式 Reed-Solomon 抹除碼：k 個資料片段產生 m 個同位片段，
+// 同一組內任意 k 個片段即可還原資料，用於不可靠 datagram 模式的前向糾錯。
+type rsCode struct {
+	k, m   int
+	matrix [][]byte // (k+m) x k：前 k 列為單位矩陣，後 m 列為 Cauchy 矩陣
+}
+
+func newRSCode(k, m int) (*rsCode, error) {
+	if k <= 0 || m < 0 || k+m > 256 {
+		return nil, fmt.Errorf("無效的 FEC 參數 k=%d m=%d", k, m)
+	}
+	matrix := make([][]byte, k+m)
+	for i := range matrix {
+		matrix[i] = make([]byte, k)
+		if i < k {
+			matrix[i][i] = 1
+			continue
+		}
+		for j := 0; j < k; j++ {
+			// x_i = i, y_j = j，兩者互異，故 x_i ^ y_j 不為零
+			matrix[i][j] = gfInv(byte(i) ^ byte(j))
+		}
+	}
+	return &rsCode{k: k, m: m, matrix: matrix}, nil
+}
+
+// Encode 以前 k 個片段計算後 m 個同位片段，所有片段長度必須相同。
+func (c *rsCode) Encode(shards [][]byte) error {
+	if len(shards) != c.k+c.m {
+		return errors.New("FEC 片段數量錯誤")
+	}
+	size := len(shards[0])
+	for i := 0; i < c.k; i++ {
+		if len(shards[i]) != size {
+			return errors.New("FEC 片段長度不一致")
+		}
+	}
+	for i := c.k; i < c.k+c.m; i++ {
+		if len(shards[i]) != size {
+			shards[i] = make([]byte, size)
+		}
+		mulRows(shards[i], c.matrix[i], shards[:c.k])
+	}
+	return nil
+}
+
+// Reconstruct 還原遺失（nil）的資料片段；同位片段不會被重建。
+func (c *rsCode) Reconstruct(shards [][]byte) error {
+	if len(shards) != c.k+c.m {
+		return errors.New("FEC 片段數量錯誤")
+	}
+	missing := false
+	for i := 0; i < c.k; i++ {
+		if shards[i] == nil {
+			missing = true
+			break
+		}
+	}
+	if !missing {
+		return nil
+	}
+
+	rows := make([][]byte, 0, c.k)
+	inputs := make([][]byte, 0, c.k)
+	size := -1
+	for i := 0; i < c.k+c.m && len(rows) < c.k; i++ {
+		if shards[i] == nil {
+			continue
+		}
+		if size >= 0 && len(shards[i]) != size {
+			return errors.New("FEC 片段長度不一致")
+		}
+		size = len(shards[i])
+		rows = append(rows, c.matrix[i])
+		inputs = append(inputs, shards[i])
+	}
+	if len(rows) < c.k {
+		return fmt.Errorf("FEC 片段不足: 需要 %d 個，只有 %d 個", c.k, len(rows))
+	}
+
+	inv, err := invertMatrix(rows)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < c.k; i++ {
+		if shards[i] == nil {
+			shards[i] = make([]byte, size)
+			mulRows(shards[i], inv[i], inputs)
+		}
+	}
+	return nil
+}
+
+// mulRows 計算 dst = Σ coef[j] * src[j]。
+func mulRows(dst []byte, coef []byte, src [][]byte) {
+	clear(dst)
+	for j, s := range src {
+		if coef[j] == 0 {
+			continue
+		}
+		for n, b := range s {
+			dst[n] ^= gfMul(coef[j], b)
+		}
+	}
+}
+
+// invertMatrix 以高斯-約旦消去法求 GF(2^8) 上方陣的反矩陣。
+func invertMatrix(in [][]byte) ([][]byte, error) {
+	n := len(in)
+	a := make([][]byte, n)
+	for i := range a {
+		a[i] = make([]byte, 2*n)
+		copy(a[i], in[i])
+		a[i][n+i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for r := col; r < n; r++ {
+			if a[r][col] != 0 {
+				pivot = r
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, errors.New("FEC 矩陣不可逆")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		scale := gfInv(a[col][col])
+		for j := range a[col] {
+			a[col][j] = gfMul(a[col][j], scale)
+		}
+		for r := 0; r < n; r++ {
+			if r == col || a[r][col] == 0 {
+				continue
+			}
+			f := a[r][col]
+			for j := range a[r] {
+				a[r][j] ^= gfMul(f, a[col][j])
+			}
+		}
+	}
+	out := make([][]byte, n)
+	for i := range out {
+		out[i] = a[i][n:]
+	}
+	return out, nil
+}
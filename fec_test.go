@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+)
+
+// encodeShards 產生 k 個隨機資料片段並計算 m 個同位片段。
+func encodeShards(t *testing.T, c *rsCode, size int, rng *rand.Rand) [][]byte {
+	t.Helper()
+	shards := make([][]byte, c.k+c.m)
+	for i := range c.k {
+		shards[i] = make([]byte, size)
+		for j := range shards[i] {
+			shards[i][j] = byte(rng.IntN(256))
+		}
+	}
+	if err := c.Encode(shards); err != nil {
+		t.Fatal(err)
+	}
+	return shards
+}
+
+// erase 複製 shards 並把 lost 中的片段設為 nil。
+func erase(shards [][]byte, lost []int) [][]byte {
+	out := make([][]byte, len(shards))
+	for i, s := range shards {
+		out[i] = bytes.Clone(s)
+	}
+	for _, i := range lost {
+		out[i] = nil
+	}
+	return out
+}
+
+// subsets 回傳 0..n-1 中所有大小為 size 的子集合。
+func subsets(n, size int) [][]int {
+	if size == 0 {
+		return [][]int{nil}
+	}
+	var all [][]int
+	for first := 0; first <= n-size; first++ {
+		for _, rest := range subsets(n-first-1, size-1) {
+			s := []int{first}
+			for _, r := range rest {
+				s = append(s, first+1+r)
+			}
+			all = append(all, s)
+		}
+	}
+	return all
+}
+
+func TestRSReconstruct(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	tests := []struct {
+		k, m int
+	}{
+		{1, 1},
+		{2, 1},
+		{4, 2},
+		{5, 3},
+		{8, 4},
+		{10, 0},
+	}
+	for _, tt := range tests {
+		c, err := newRSCode(tt.k, tt.m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shards := encodeShards(t, c, 37, rng)
+		// 遺失最多 m 個任意位置的片段（資料與同位片段都可能遺失）都能還原
+		for n := 0; n <= tt.m; n++ {
+			for _, lost := range subsets(tt.k+tt.m, n) {
+				got := erase(shards, lost)
+				if err := c.Reconstruct(got); err != nil {
+					t.Fatalf("k=%d m=%d lost %v: %v", tt.k, tt.m, lost, err)
+				}
+				for i := range tt.k {
+					if !bytes.Equal(got[i], shards[i]) {
+						t.Fatalf("k=%d m=%d lost %v: data shard %d differs", tt.k, tt.m, lost, i)
+					}
+				}
+			}
+		}
+		// 遺失超過 m 個資料片段時無法還原
+		lost := make([]int, 0, tt.m+1)
+		for i := 0; i < tt.m+1 && i < tt.k; i++ {
+			lost = append(lost, i)
+		}
+		for i := tt.k; len(lost) < tt.m+1; i++ {
+			lost = append(lost, i)
+		}
+		if err := c.Reconstruct(erase(shards, lost)); err == nil {
+			t.Errorf("k=%d m=%d lost %v: reconstructed with too few shards", tt.k, tt.m, lost)
+		}
+	}
+}
+
+func TestRSReconstructLarge(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	c, err := newRSCode(200, 56)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards := encodeShards(t, c, 64, rng)
+	for range 20 {
+		lost := rng.Perm(256)[:56]
+		got := erase(shards, lost)
+		if err := c.Reconstruct(got); err != nil {
+			t.Fatalf("lost %v: %v", lost, err)
+		}
+		for i := range c.k {
+			if !bytes.Equal(got[i], shards[i]) {
+				t.Fatalf("lost %v: data shard %d differs", lost, i)
+			}
+		}
+	}
+	if err := c.Reconstruct(erase(shards, rng.Perm(256)[:57])); err == nil {
+		t.Error("reconstructed 57 lost shards with 56 parity shards")
+	}
+}
+
+func TestRSInvalid(t *testing.T) {
+	for _, km := range [][2]int{{0, 1}, {-1, 2}, {4, -1}, {200, 57}} {
+		if _, err := newRSCode(km[0], km[1]); err == nil {
+			t.Errorf("newRSCode(%d, %d) succeeded", km[0], km[1])
+		}
+	}
+	c, _ := newRSCode(2, 1)
+	if err := c.Encode([][]byte{{1, 2}, {3}, nil}); err == nil {
+		t.Error("Encode accepted shards of different lengths")
+	}
+	if err := c.Encode([][]byte{{1}, {2}}); err == nil {
+		t.Error("Encode accepted the wrong number of shards")
+	}
+	if err := c.Reconstruct([][]byte{nil, {1, 2}, {3}}); err == nil {
+		t.Error("Reconstruct accepted shards of different lengths")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// runMkdir 實作 `mkdir [-p] <dir>...`。
+func runMkdir(ctx context.Context, session *quic.Conn, args []string) error {
+	flags := flag.NewFlagSet("mkdir", flag.ExitOnError)
+	parents := flags.Bool("p", false, "一併建立上層目錄，目錄已存在不算錯誤")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return errors.New("用法: data_cli <ip:port> mkdir [-p] <dir>...")
+	}
+	for _, dir := range flags.Args() {
+		if err := client.New(session).Mkdir(ctx, dir, *parents); err != nil {
+			return fmt.Errorf("無法建立 %s: %w", dir, err)
+		}
+		con.Println("已建立目錄:", dir)
+	}
+	return nil
+}
+
+// runMv 實作 `mv <from> <to>`，在伺服器上搬移或重新命名。
+func runMv(ctx context.Context, session *quic.Conn, args []string) error {
+	if len(args) != 2 {
+		return errors.New("用法: data_cli <ip:port> mv <from> <to>")
+	}
+	if err := client.New(session).Rename(ctx, args[0], args[1]); err != nil {
+		return fmt.Errorf("無法搬移 %s: %w", args[0], err)
+	}
+	con.Printf("已搬移 %s -> %s\n", args[0], args[1])
+	return nil
+}
+
+// runStat 實作 `stat <path>...`，顯示類型、大小、修改時間與雜湊。
+func runStat(ctx context.Context, session *quic.Conn, args []string) error {
+	if len(args) == 0 {
+		return errors.New("用法: data_cli <ip:port> stat <path>...")
+	}
+	for _, p := range args {
+		e, err := client.New(session).Stat(ctx, p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		con.Printf("  路徑: %s\n  類型: %s\n  大小: %d (%s)\n", p, e.Type, e.Size, humanSize(e.Size))
+		if !e.Mtime.IsZero() {
+			con.Printf("  修改: %s\n", e.Mtime.Local().Format(time.RFC3339))
+		}
+		if e.Hash != "" {
+			con.Printf("  雜湊: %s\n", e.Hash)
+		}
+		con.Result(e)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// filterRule 是一條 --include/--exclude 規則。
+type filterRule struct {
+	include bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// filterRules 依命令列順序比對，第一條符合的規則決定去留（與 rsync 相同）；都不符合則保留。
+type filterRules []filterRule
+
+// add 加入一條規則。語法：
+//   - "*" 不跨越 "/"，"**" 可跨越 "/"（"**/" 可比對零層目錄），"?" 比對單一字元，"[...]" 為字元集合
+//   - 以 "/" 開頭的樣式從根目錄比對整個路徑
+//   - 含 "/" 的樣式比對路徑結尾的完整元件，否則只比對最後一個元件
+//   - 以 "/" 結尾的樣式只比對目錄
+func (rules *filterRules) add(include bool, pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("空白的過濾樣式")
+	}
+	r := filterRule{include: include}
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	body := "(^|/)" + globToRegexp(pattern) + "$"
+	if strings.HasPrefix(pattern, "/") {
+		body = "^" + globToRegexp(pattern[1:]) + "$"
+	}
+	re, err := regexp.Compile(body)
+	if err != nil {
+		return fmt.Errorf("無效的過濾樣式 %q: %v", pattern, err)
+	}
+	r.re = re
+	*rules = append(*rules, r)
+	return nil
+}
+
+// Included 回報相對路徑 rel（以 "/" 分隔）是否應被處理。
+func (rules filterRules) Included(rel string, isDir bool) bool {
+	rel = strings.Trim(path.Clean("/"+rel), "/")
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.re.MatchString(rel) {
+			return r.include
+		}
+	}
+	return true
+}
+
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			switch {
+			case strings.HasPrefix(glob[i:], "**/"):
+				// "**/" 可比對零到多層目錄
+				b.WriteString("(.*/)?")
+				i += 2
+			case strings.HasPrefix(glob[i:], "**"):
+				b.WriteString(".*")
+				i++
+			default:
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// walkRemote 遞迴列出遠端目錄 dir，對每個未被 rules 排除的檔案呼叫 fn；被排除的目錄整個略過。
+// rel 是相對於 dir、以 "/" 分隔的路徑，maxDepth 的意義與 walkLocal 相同。
+func walkRemote(ctx context.Context, session *quic.Conn, dir string, rules filterRules, maxDepth int, fn func(rel string, e client.Entry) error) error {
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		var subdirs []string
+		err := client.New(session).ListFunc(ctx, path.Join(dir, rel), true, func(e client.Entry) error {
+			p := path.Join(rel, e.Name)
+			if !withinDepth(p, maxDepth) || !rules.Included(p, e.Type == "dir") {
+				return nil
+			}
+			if e.Type == "dir" {
+				// 列表的 stream 還開著，讀完之後再深入子目錄
+				subdirs = append(subdirs, p)
+				return nil
+			}
+			return fn(p, e)
+		})
+		if err != nil {
+			return err
+		}
+		for _, sub := range subdirs {
+			if err := walk(sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk("")
+}
+
+// remoteFile 是批次下載中的一個檔案。mtime 不為零時，下載完成後設為本機檔案的修改時間。
+type remoteFile struct {
+	remote, local string
+	size          int64
+	mtime         time.Time
+}
+
+// runGetRecursive 實作 `get -r [-j N] <dir>`：依遠端列表在本機重建目錄結構並下載每個檔案。
+func runGetRecursive(ctx context.Context, session *quic.Conn, args []string, filters filterRules, maxDepth int, weights weightRules, opts getOptions) error {
+	flags := flag.NewFlagSet("get -r", flag.ExitOnError)
+	jobs := flags.Int("j", opts.concurrency, "同時下載的檔案數")
+	flags.Parse(args)
+	if flags.NArg() != 1 || *jobs < 1 {
+		return fmt.Errorf("用法: data_cli <ip:port> get -r [-j N] <dir>")
+	}
+	dir := strings.Trim(path.Clean("/"+flags.Arg(0)), "/")
+	// 與 cp -r 相同：-o 是已存在的目錄時放在它底下，不存在時以它作為下載後的目錄
+	localRoot, err := destination(dir, opts.output, opts.parents)
+	if err != nil {
+		// 遠端根目錄直接下載到目前目錄或 -o 指定的目錄
+		localRoot = cmp.Or(opts.output, ".")
+	} else if info, serr := os.Stat(localRoot); opts.output != "" && serr == nil && !info.IsDir() {
+		return fmt.Errorf("%s 不是目錄", localRoot)
+	}
+
+	var files []remoteFile
+	err = walkRemote(ctx, session, dir, filters, maxDepth, func(rel string, e client.Entry) error {
+		files = append(files, remoteFile{remote: path.Join(dir, rel), local: filepath.Join(localRoot, filepath.FromSlash(rel)), size: e.Size})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := getFiles(ctx, session, dir, files, *jobs, weights, opts); err != nil || opts.dryRun {
+		return err
+	}
+	con.Printf("目錄下載完成: %s -> %s\n", dir, localRoot)
+	return nil
+}
+
+// getFiles 依序或同時下載 files，label 用於顯示。
+// jobs 為 1 時逐一下載，每個檔案有自己的進度列；大於 1 時同時下載多個檔案，顯示一條總進度列。
+func getFiles(ctx context.Context, session *quic.Conn, label string, files []remoteFile, jobs int, weights weightRules, opts getOptions) error {
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	con.Printf("%s: %d 個檔案，共 %s\n", label, len(files), humanSize(total))
+	if opts.dryRun {
+		return planDownloads(files, opts)
+	}
+
+	if jobs > 1 {
+		// 同時下載時各檔案不各自顯示進度，也不監聽按鍵
+		opts.overall = NewProgressReader(nil, total)
+		opts.overall.name = label
+		opts.overall.StartMonitor()
+		defer opts.overall.Stop()
+		opts.noKeys = true
+		opts.job = nil
+	}
+	var (
+		mu      sync.Mutex
+		failed  int
+		started int
+		done    int64
+	)
+	// 依優先權排隊，高優先權的檔案先開始；同時進行的傳輸共用 opts.limiter，依權重分配頻寬
+	q := newTransferQueue(jobs)
+	// 排隊中的檔案也經由控制 socket 列在 `jobs` 中
+	if stopQueue, err := serveQueue(q); err != nil {
+		slog.Warn("無法建立控制 socket", "err", err)
+	} else {
+		defer stopQueue()
+	}
+	for _, f := range files {
+		p := opts.priorities.For(f.remote, opts.priority)
+		q.Push(p, f.remote, func() {
+			if ctx.Err() != nil {
+				return
+			}
+			mu.Lock()
+			started++
+			if jobs == 1 {
+				con.Printf("[%d/%d] %s（總計 %s/%s）\n", started, len(files), f.remote, humanSize(done), humanSize(total))
+			}
+			mu.Unlock()
+			o := opts
+			o.local = f.local
+			o.weight = weights.For(f.remote)
+			o.priority = p
+			err := runGet(ctx, session, f.remote, o)
+			if err == nil && opts.preserve && !f.mtime.IsZero() {
+				local := f.local
+				if len(opts.encryptTo) > 0 {
+					local = encryptedName(local)
+				}
+				err = os.Chtimes(local, f.mtime, f.mtime)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			done += f.size
+			if err != nil {
+				failed++
+				slog.Error("下載失敗", "file", f.remote, "err", err)
+				con.Event(transferEvent{Event: "error", File: f.remote, Error: err.Error()})
+			}
+		})
+	}
+	q.Close()
+	q.Wait()
+	opts.overall.Stop()
+	if failed > 0 {
+		return fmt.Errorf("%d 個檔案下載失敗（共 %d 個）", failed, len(files))
+	}
+	return ctx.Err()
+}
+
+// hasGlob 回報 pattern 是否含有萬用字元。
+func hasGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// runGetMulti 實作 `get [-j N] <file|pattern>...`：在同一條連線上下載多個檔案，
+// 含萬用字元的參數展開為所有符合的遠端檔案。
+func runGetMulti(ctx context.Context, session *quic.Conn, args []string, filters filterRules, weights weightRules, opts getOptions) error {
+	flags := flag.NewFlagSet("get", flag.ExitOnError)
+	jobs := flags.Int("j", opts.concurrency, "同時下載的檔案數")
+	flags.Parse(args)
+	if flags.NArg() == 0 || *jobs < 1 {
+		return fmt.Errorf("用法: data_cli <ip:port> get [-j N] <file|pattern>...")
+	}
+	if opts.output != "" && !outputIsDir(opts.output) {
+		return fmt.Errorf("下載多個檔案時 -o 必須是目錄")
+	}
+
+	var files []remoteFile
+	seen := make(map[string]string)
+	add := func(remote string, size int64) error {
+		local, err := destination(remote, opts.output, opts.parents)
+		if err != nil {
+			return err
+		}
+		if prev, ok := seen[local]; ok {
+			if prev == remote {
+				return nil
+			}
+			return fmt.Errorf("%s 與 %s 會寫到同一個本機檔案 %s，請加上 --parents", prev, remote, local)
+		}
+		seen[local] = remote
+		files = append(files, remoteFile{remote: remote, local: local, size: size})
+		return nil
+	}
+	for _, arg := range flags.Args() {
+		if !hasGlob(arg) {
+			remote := strings.Trim(path.Clean("/"+arg), "/")
+			e, err := statRemote(ctx, session, remote)
+			if err != nil {
+				return fmt.Errorf("%s: %w", arg, err)
+			}
+			if e.Type == "dir" {
+				return fmt.Errorf("%s 是目錄，請使用 get -r", arg)
+			}
+			if err := add(remote, e.Size); err != nil {
+				return err
+			}
+			continue
+		}
+		matched := false
+		err := globRemote(ctx, session, arg, filters, func(remote string, e client.Entry) error {
+			matched = true
+			return add(remote, e.Size)
+		})
+		if err != nil {
+			return err
+		}
+		if !matched {
+			return fmt.Errorf("沒有符合 %q 的遠端檔案", arg)
+		}
+	}
+	label := flags.Arg(0)
+	if flags.NArg() > 1 {
+		label = strings.Join(flags.Args(), " ")
+	}
+	return getFiles(ctx, session, label, files, *jobs, weights, opts)
+}
+
+// globRemote 從樣式中第一個含萬用字元的層級開始列出遠端目錄，
+// 以 path.Match 比對完整路徑後對每個符合的檔案呼叫 fn。
+func globRemote(ctx context.Context, session *quic.Conn, pattern string, filters filterRules, fn func(remote string, e client.Entry) error) error {
+	pattern = strings.Trim(path.Clean("/"+pattern), "/")
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("無效的樣式 %q: %v", pattern, err)
+	}
+	// 樣式中不含萬用字元的前段即為要列出的目錄
+	segments := strings.Split(pattern, "/")
+	fixed := 0
+	for fixed < len(segments) && !hasGlob(segments[fixed]) {
+		fixed++
+	}
+	dir := strings.Join(segments[:fixed], "/")
+	return walkRemote(ctx, session, dir, filters, len(segments)-fixed, func(rel string, e client.Entry) error {
+		remote := path.Join(dir, rel)
+		if ok, _ := path.Match(pattern, remote); !ok {
+			return nil
+		}
+		return fn(remote, e)
+	})
+}
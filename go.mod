@@ -2,14 +2,25 @@ module go-client
 
 go 1.24.5
 
-require github.com/quic-go/quic-go v0.54.0
+require (
+	filippo.io/age v1.3.1
+	github.com/hanwen/go-fuse/v2 v2.7.2
+	github.com/klauspost/compress v1.17.9
+	github.com/quic-go/quic-go v0.54.0
+	golang.org/x/mod v0.30.0
+	golang.org/x/net v0.47.0
+	golang.org/x/sys v0.38.0
+	golang.org/x/term v0.37.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
+	filippo.io/hpke v0.4.0 // indirect
+	github.com/francoispqt/gojay v1.2.13 // indirect
+	github.com/quic-go/qpack v0.5.1 // indirect
 	go.uber.org/mock v0.5.0 // indirect
-	golang.org/x/crypto v0.26.0 // indirect
-	golang.org/x/mod v0.18.0 // indirect
-	golang.org/x/net v0.28.0 // indirect
-	golang.org/x/sync v0.8.0 // indirect
-	golang.org/x/sys v0.23.0 // indirect
-	golang.org/x/tools v0.22.0 // indirect
+	golang.org/x/crypto v0.45.0 // indirect
+	golang.org/x/sync v0.18.0 // indirect
+	golang.org/x/text v0.31.0 // indirect
+	golang.org/x/tools v0.39.0 // indirect
 )
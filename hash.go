@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// runHash 實作 `hash <remotefile>...`：向伺服器查詢雜湊而不下載內容，
+// 以 sha256sum 的格式輸出，可直接作為本機 `verify` 的 manifest。
+func runHash(ctx context.Context, session *quic.Conn, args []string) error {
+	if len(args) == 0 {
+		return errors.New("用法: data_cli <ip:port> hash <remotefile>...")
+	}
+	for _, p := range args {
+		sum, err := client.New(session).Hash(ctx, p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		con.Printf("%s  %s\n", sum.Sum, p)
+		con.Result(struct {
+			Path      string `json:"path"`
+			Algorithm string `json:"algorithm"`
+			Hash      string `json:"hash"`
+		}{p, sum.Algorithm, sum.Sum})
+	}
+	return nil
+}
+
+// verifyResult 是 `verify <remotefile> <localfile>` 的結果。
+type verifyResult struct {
+	Remote     string `json:"remote"`
+	Local      string `json:"local"`
+	Status     string `json:"status"` // ok | modified
+	RemoteHash string `json:"remote_hash"`
+	LocalHash  string `json:"local_hash"`
+}
+
+// runRemoteVerify 實作 `verify <remotefile> <localfile>`：向伺服器查詢雜湊，與本機檔案算出的雜湊比對，
+// 不傳輸檔案內容。讀取本機檔案時顯示與傳輸相同的進度列；不符時以 ChecksumError 結束（結束碼 6）。
+func runRemoteVerify(ctx context.Context, session *quic.Conn, args []string) error {
+	if len(args) != 2 {
+		return errors.New("用法: data_cli <ip:port> verify <remotefile> <localfile>")
+	}
+	remote, local := args[0], args[1]
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s 是目錄", local)
+	}
+	want, err := client.New(session).Hash(ctx, remote)
+	if err != nil {
+		return fmt.Errorf("%s: %w", remote, err)
+	}
+
+	// 中斷時關閉檔案，讓讀取立即返回
+	stop := context.AfterFunc(ctx, func() { f.Close() })
+	defer stop()
+	h := want.New()
+	progress := NewProgressReader(f, info.Size())
+	progress.name = local
+	progress.StartMonitor()
+	_, err = io.Copy(h, progress)
+	progress.Stop()
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	res := verifyResult{Remote: remote, Local: local, Status: "ok", RemoteHash: want.Sum, LocalHash: hex.EncodeToString(h.Sum(nil))}
+	err = want.Verify(h)
+	if err != nil {
+		res.Status = "modified"
+		con.Printf("MODIFIED %s  %s\n", remote, local)
+	} else {
+		con.Printf("OK       %s  %s\n", remote, local)
+	}
+	con.Result(res)
+	if err != nil {
+		return fmt.Errorf("%s 與 %s 不同: %w", local, remote, err)
+	}
+	return nil
+}
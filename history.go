@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"text/tabwriter"
+	"time"
+)
+
+// historyEntry 是一筆完成的傳輸紀錄。
+type historyEntry struct {
+	Time     time.Time     `json:"time"`
+	Peer     string        `json:"peer"`
+	Remote   string        `json:"remote"`
+	Local    string        `json:"local"`
+	Size     int64         `json:"size"`
+	SHA256   string        `json:"sha256"`
+	Duration time.Duration `json:"duration"`
+}
+
+func historyPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// recordHistory 把一筆紀錄附加到 history.jsonl。
+func recordHistory(e historyEntry) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// runHistory 列出符合 pattern（比對遠端路徑，省略時列出全部）的傳輸紀錄。
+// 指定 pattern 卻沒有任何紀錄時回傳錯誤，方便腳本判斷「是否已經下載過」。
+func runHistory(pattern string) error {
+	var re *regexp.Regexp
+	if pattern != "" {
+		var err error
+		if re, err = regexp.Compile("(^|/)" + globToRegexp(pattern) + "$"); err != nil {
+			return err
+		}
+	}
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	w := tabwriter.NewWriter(con.Text(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tPEER\tREMOTE\tSIZE\tDURATION\tSHA256")
+	found := 0
+	if f != nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var e historyEntry
+			if json.Unmarshal(scanner.Bytes(), &e) != nil {
+				continue
+			}
+			if re != nil && !re.MatchString(e.Remote) {
+				continue
+			}
+			found++
+			con.Result(e)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", e.Time.Format(time.DateTime), e.Peer, e.Remote, e.Size, e.Duration.Round(time.Millisecond), e.SHA256)
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if pattern != "" && found == 0 {
+		return fmt.Errorf("沒有符合 %q 的傳輸紀錄", pattern)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// transferHooks 是 --on-complete 與 --on-error 指定的命令，每個檔案傳輸完成或失敗時以 shell 執行一次。
+// 傳輸資訊放在環境變數 TRANSFER_EVENT、TRANSFER_FILE（遠端路徑）、TRANSFER_LOCAL、TRANSFER_SIZE、
+// TRANSFER_SHA256、TRANSFER_DURATION（秒）與 TRANSFER_ERROR，stdin 則是與 --events-fd 相同的 JSON 事件。
+// 命令執行完才繼續，後續處理（解壓縮、搬移、通知）完成後 data_cli 才結束。
+type transferHooks struct {
+	complete, fail string
+}
+
+// run 依事件種類執行對應的命令；命令失敗只記錄警告，不影響傳輸結果。
+func (h transferHooks) run(e transferEvent) {
+	name, cmdline := "--on-complete", h.complete
+	if e.Event == "error" {
+		name, cmdline = "--on-error", h.fail
+	} else if e.Event != "done" {
+		return
+	}
+	if cmdline == "" {
+		return
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", cmdline)
+	} else {
+		cmd = exec.Command("sh", "-c", cmdline)
+	}
+	data, _ := json.Marshal(e)
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	// 命令的輸出不能混進 --json 的結果
+	cmd.Stdout, cmd.Stderr = con.Text(), os.Stderr
+	cmd.Env = append(os.Environ(),
+		"TRANSFER_EVENT="+e.Event,
+		"TRANSFER_FILE="+e.File,
+		"TRANSFER_LOCAL="+e.Local,
+		fmt.Sprintf("TRANSFER_SIZE=%d", e.Bytes),
+		"TRANSFER_SHA256="+e.SHA256,
+		fmt.Sprintf("TRANSFER_DURATION=%.3f", e.Time.Seconds()),
+		"TRANSFER_ERROR="+e.Error,
+	)
+	if err := cmd.Run(); err != nil {
+		slog.Warn("傳輸後的命令失敗", "hook", name, "file", e.File, "err", err)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ignoreFileName 是來源目錄中的忽略檔，語法與 .gitignore 相同。
+const ignoreFileName = ".quicignore"
+
+type ignoreFile struct {
+	dir   string // 相對於 root，以 "/" 分隔
+	lines []string
+}
+
+// loadIgnoreFiles 讀取 root 底下所有 .quicignore 並轉成 filterRules。
+// gitignore 是「最後符合的規則生效」且較深層的檔案優先，因此反向加入規則以配合 filterRules 的第一條符合生效。
+func loadIgnoreFiles(root string) (filterRules, error) {
+	var files []ignoreFile
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() != ignoreFileName {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		files = append(files, ignoreFile{dir: filepath.ToSlash(rel), lines: strings.Split(string(data), "\n")})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return depth(files[i].dir) > depth(files[j].dir)
+	})
+
+	var rules filterRules
+	for _, f := range files {
+		for i := len(f.lines) - 1; i >= 0; i-- {
+			line := strings.TrimRight(f.lines[i], " \r")
+			if line == "" || line[0] == '#' {
+				continue
+			}
+			include := false
+			if line[0] == '!' {
+				include = true
+				line = line[1:]
+			}
+			line = strings.TrimPrefix(line, `\`)
+			// 開頭或中間含 "/" 的樣式相對於忽略檔所在目錄
+			anchored := strings.Contains(strings.TrimSuffix(line, "/"), "/")
+			line = strings.TrimPrefix(line, "/")
+			switch {
+			case f.dir != "." && anchored:
+				line = "/" + f.dir + "/" + line
+			case f.dir != ".":
+				line = "/" + f.dir + "/**/" + line
+			case anchored:
+				line = "/" + line
+			}
+			if err := rules.add(include, line); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return rules, nil
+}
+
+func depth(dir string) int {
+	if dir == "." || dir == "" {
+		return 0
+	}
+	return strings.Count(dir, "/") + 1
+}
+
+// withinDepth 回報相對路徑 rel 是否在 maxDepth 層以內；maxDepth <= 0 表示不限制。
+func withinDepth(rel string, maxDepth int) bool {
+	return maxDepth <= 0 || depth(strings.Trim(rel, "/")) <= maxDepth
+}
+
+// walkLocal 走訪本機目錄 root，對每個未被 rules 排除的檔案呼叫 fn；被排除的目錄整個略過。
+// rel 是相對於 root、以 "/" 分隔的路徑。maxDepth > 0 時只處理前 maxDepth 層（root 的直接子項為第 1 層）。
+func walkLocal(root string, rules filterRules, maxDepth int, fn func(rel string, d fs.DirEntry) error) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !withinDepth(rel, maxDepth) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !rules.Included(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		return fn(rel, d)
+	})
+}
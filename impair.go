@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// impairment 描述要注入 UDP 路徑的人工延遲、抖動與遺失，用於測試限速、續傳與重試邏輯。
+// 同一個 seed 會產生相同的遺失與抖動序列。
+type impairment struct {
+	delay  time.Duration
+	jitter time.Duration
+	loss   float64
+	seed   uint64
+}
+
+// activeImpairment 由 --impair 設定，dialPacketConn 會以它包裝 UDP 連線。
+var activeImpairment *impairment
+
+// parseImpairment 解析 "delay=80ms,jitter=20ms,loss=0.02,seed=1"，各項皆可省略。
+func parseImpairment(s string) (*impairment, error) {
+	im := &impairment{seed: 1}
+	for _, part := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("無效的 --impair 設定: %q", part)
+		}
+		var err error
+		switch key {
+		case "delay":
+			im.delay, err = time.ParseDuration(value)
+		case "jitter":
+			im.jitter, err = time.ParseDuration(value)
+		case "loss":
+			im.loss, err = strconv.ParseFloat(value, 64)
+			if err == nil && (im.loss < 0 || im.loss > 1) {
+				err = fmt.Errorf("必須介於 0 與 1 之間")
+			}
+		case "seed":
+			im.seed, err = strconv.ParseUint(value, 10, 64)
+		default:
+			return nil, fmt.Errorf("未知的 --impair 項目: %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("無效的 --impair %s: %v", key, err)
+		}
+	}
+	if im.delay < 0 || im.jitter < 0 {
+		return nil, fmt.Errorf("--impair 的 delay 與 jitter 不可為負")
+	}
+	return im, nil
+}
+
+// impairedConn 包裝 net.PacketConn：送出的封包延遲 delay±jitter 後才寫出，
+// 收送兩個方向都以 loss 機率丟棄封包。
+type impairedConn struct {
+	net.PacketConn
+	im *impairment
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func (im *impairment) wrap(conn net.PacketConn) *impairedConn {
+	return &impairedConn{PacketConn: conn, im: im, rng: rand.New(rand.NewPCG(im.seed, im.seed))}
+}
+
+// roll 回傳這個封包是否要丟棄，以及要延遲多久。
+func (c *impairedConn) roll() (drop bool, delay time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	drop = c.im.loss > 0 && c.rng.Float64() < c.im.loss
+	delay = c.im.delay
+	if c.im.jitter > 0 {
+		delay += time.Duration(c.rng.Int64N(int64(2*c.im.jitter)+1)) - c.im.jitter
+	}
+	return drop, max(delay, 0)
+}
+
+func (c *impairedConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	drop, delay := c.roll()
+	if drop {
+		return len(p), nil
+	}
+	if delay == 0 {
+		return c.PacketConn.WriteTo(p, addr)
+	}
+	// quic-go 會重複使用緩衝區，延遲送出前必須複製
+	buf := append([]byte(nil), p...)
+	time.AfterFunc(delay, func() { c.PacketConn.WriteTo(buf, addr) })
+	return len(p), nil
+}
+
+func (c *impairedConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(p)
+		if err != nil {
+			return n, addr, err
+		}
+		if drop, _ := c.roll(); !drop {
+			return n, addr, nil
+		}
+	}
+}
+
+// SetReadBuffer 與 SetWriteBuffer 轉給底層的 socket，讓 quic-go 能調大緩衝區。
+func (c *impairedConn) SetReadBuffer(n int) error {
+	if b, ok := c.PacketConn.(interface{ SetReadBuffer(int) error }); ok {
+		return b.SetReadBuffer(n)
+	}
+	return errors.ErrUnsupported
+}
+
+func (c *impairedConn) SetWriteBuffer(n int) error {
+	if b, ok := c.PacketConn.(interface{ SetWriteBuffer(int) error }); ok {
+		return b.SetWriteBuffer(n)
+	}
+	return errors.ErrUnsupported
+}
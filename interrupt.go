@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/quic-go/quic-go"
+)
+
+// errInterrupted 是收到 SIGINT/SIGTERM 時 context 的取消原因。
+var errInterrupted = errors.New("已中斷")
+
+// errCodeInterrupted 是使用者中斷時關閉連線所用的應用層錯誤碼。
+const errCodeInterrupted quic.ApplicationErrorCode = 0x130
+
+var (
+	interrupted atomic.Bool
+	cancelRun   context.CancelCauseFunc
+)
+
+// signalContext 回傳收到 SIGINT 或 SIGTERM 時取消的 context；再收到一次訊號則直接結束行程。
+var signalContext = sync.OnceValue(func() context.Context {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancelRun = cancel
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		interrupt()
+		<-sig
+		os.Exit(130)
+	}()
+	return ctx
+})
+
+// interrupt 取消執行中的指令，與收到 SIGINT 相同；終端機 raw 模式下的 Ctrl-C 也經由它處理。
+func interrupt() {
+	interrupted.Store(true)
+	if cancelRun != nil {
+		cancelRun(errInterrupted)
+	}
+}
+
+// closeOnInterrupt 在指令被中斷時以 errCodeInterrupted 關閉連線，讓伺服器知道不是網路問題，
+// 同時讓阻塞在 stream 上的讀寫立即返回。
+func closeOnInterrupt(ctx context.Context, session *quic.Conn) {
+	context.AfterFunc(ctx, func() {
+		if interrupted.Load() {
+			session.CloseWithError(errCodeInterrupted, "interrupted")
+		}
+	})
+}
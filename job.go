@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// stateDir 回傳本機狀態目錄（$XDG_STATE_HOME/quic-client 或 ~/.local/state/quic-client）。
+func stateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "quic-client"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "quic-client"), nil
+}
+
+// job 是一筆持久化的傳輸紀錄，保存原始命令列以便 `resume <id>` 以相同選項重新執行。
+type job struct {
+	ID      string    `json:"id"`
+	Argv    []string  `json:"argv"`
+	PID     int       `json:"pid"`
+	State   string    `json:"state"` // running | done
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+
+	Checkpoint *checkpoint `json:"checkpoint,omitempty"`
+}
+
+func jobPath(id string) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "jobs", id+".json"), nil
+}
+
+// startJob 建立新的傳輸紀錄；id 不為空時沿用既有紀錄並更新為目前的行程。
+func startJob(id string, argv []string) (*job, error) {
+	j := &job{ID: id, Argv: argv, Created: time.Now()}
+	if id == "" {
+		b := make([]byte, 4)
+		rand.Read(b)
+		j.ID = hex.EncodeToString(b)
+	} else if old, err := loadJob(id); err == nil {
+		j.Created = old.Created
+		j.Checkpoint = old.Checkpoint
+	}
+	j.PID = os.Getpid()
+	j.State = "running"
+	return j, j.save()
+}
+
+func (j *job) finish() error {
+	j.State = "done"
+	j.Checkpoint = nil
+	return j.save()
+}
+
+func (j *job) save() error {
+	path, err := jobPath(j.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	j.Updated = time.Now()
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func loadJob(id string) (*job, error) {
+	path, err := jobPath(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("找不到傳輸 %s", id)
+	}
+	var j job
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("傳輸紀錄 %s 已損毀: %v", id, err)
+	}
+	return &j, nil
+}
+
+// loadJobs 讀取所有傳輸紀錄。
+func loadJobs() ([]*job, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "jobs", "*.json"))
+	var jobs []*job
+	for _, path := range paths {
+		if j, err := loadJob(strings.TrimSuffix(filepath.Base(path), ".json")); err == nil {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs, nil
+}
+
+// interrupted 表示紀錄仍是 running，但原本的行程已經不在。
+func (j *job) interrupted() bool {
+	return j.State == "running" && !processAlive(j.PID)
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// runResume 以原本的命令列重新執行一個中斷的傳輸。
+func runResume(id string) error {
+	j, err := loadJob(id)
+	if err != nil {
+		return err
+	}
+	switch {
+	case j.State == "done":
+		return fmt.Errorf("傳輸 %s 已完成", id)
+	case !j.interrupted():
+		return fmt.Errorf("傳輸 %s 仍在執行中 (pid %d)", id, j.PID)
+	}
+	return run(j.Argv, j.ID)
+}
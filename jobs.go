@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runJobs 列出所有進行中與排隊中的傳輸：各行程經由控制 socket 回報自己的傳輸（同時下載多個檔案時
+// 包括還在佇列中的），daemon 的傳輸經由其 API socket 查詢；已結束行程留下的 socket 會被清除。
+// 中斷的傳輸以其 ID 列出，可用 `resume <id>` 繼續。
+func runJobs() error {
+	w := tabwriter.NewWriter(con.Text(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PID/ID\tNAME\tSTATE\tPROGRESS\tRATE\tETA")
+	row := func(id string, st transferStatus) {
+		con.Result(jobResult{ID: id, transferStatus: st})
+		if st.State == "queued" {
+			fmt.Fprintf(w, "%s\t%s\tqueued (%s)\t-\t-\t-\n", id, st.Name, st.Priority)
+			return
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.2f KB/s\t%s\n", id, st.Name, st.State, formatProgress(st), st.Rate/1024, formatETA(st))
+	}
+
+	sockets, err := controlSockets()
+	if err != nil {
+		return err
+	}
+	for _, path := range sockets {
+		pid := strings.TrimSuffix(filepath.Base(path), ".sock")
+		reply, err := sendControl(path, "list")
+		if err != nil {
+			os.Remove(path)
+			continue
+		}
+		var list []transferStatus
+		if err := json.Unmarshal([]byte(reply), &list); err != nil {
+			continue
+		}
+		for _, st := range list {
+			row(pid, st)
+		}
+	}
+
+	// daemon 未執行或改用 -listen 時沒有這個 socket
+	transfers, err := daemonTransfers(filepath.Join(controlDir(), daemonSocket))
+	if err != nil {
+		slog.Debug("無法查詢 daemon 的傳輸", "err", err)
+	}
+	for _, t := range transfers {
+		switch t.State {
+		case "queued", "running", "paused":
+			row("daemon:"+t.ID, transferStatus{Name: t.Op + " " + t.Remote, State: t.State, Bytes: t.Bytes, Total: t.Total, Rate: t.Rate, Priority: t.Priority})
+		}
+	}
+
+	jobs, err := loadJobs()
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs {
+		if j.interrupted() {
+			con.Result(jobResult{ID: j.ID, Argv: j.Argv, transferStatus: transferStatus{State: "interrupted"}})
+			fmt.Fprintf(w, "%s\t%s\tinterrupted\t-\t-\t-\n", j.ID, strings.Join(j.Argv, " "))
+		}
+	}
+	return w.Flush()
+}
+
+// daemonTransfers 經由 unix socket 上的 daemon API 取得所有傳輸（GET /transfers）。
+func daemonTransfers(socket string) ([]daemonTransfer, error) {
+	if _, err := os.Stat(socket); err != nil {
+		return nil, nil
+	}
+	hc := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}},
+	}
+	resp, err := hc.Get("http://daemon/transfers")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon API: %s", resp.Status)
+	}
+	var list []daemonTransfer
+	err = json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(&list)
+	return list, err
+}
+
+type jobResult struct {
+	ID   string   `json:"id"`
+	Argv []string `json:"argv,omitempty"`
+	transferStatus
+}
+
+func formatProgress(st transferStatus) string {
+	if st.Total <= 0 {
+		return fmt.Sprintf("%d B", st.Bytes)
+	}
+	return fmt.Sprintf("%.1f%%", float64(st.Bytes)/float64(st.Total)*100)
+}
+
+func formatETA(st transferStatus) string {
+	if st.State != "running" || st.Rate <= 0 || st.Total <= 0 {
+		return "-"
+	}
+	eta := time.Duration(float64(st.Total-st.Bytes) / st.Rate * float64(time.Second))
+	return eta.Round(time.Second).String()
+}
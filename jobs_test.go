@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDaemonTransfers(t *testing.T) {
+	d := &daemon{transfers: map[string]*daemonTransfer{
+		"q1": {ID: "q1", Op: "get", Remote: "a.bin", Priority: "high", State: "queued", Created: time.Now()},
+		"d1": {ID: "d1", Op: "put", Remote: "b.bin", State: "done", Created: time.Now()},
+	}, order: []string{"q1", "d1"}}
+	socket := filepath.Join(t.TempDir(), daemonSocket)
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: d.handler()}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	list, err := daemonTransfers(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "q1" || list[0].State != "queued" || list[0].Priority != "high" || list[1].State != "done" {
+		t.Errorf("daemonTransfers = %+v", list)
+	}
+	// daemon 沒有執行
+	if list, err := daemonTransfers(filepath.Join(t.TempDir(), daemonSocket)); list != nil || err != nil {
+		t.Errorf("without a daemon: %v, %v", list, err)
+	}
+}
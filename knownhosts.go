@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// knownHostsPath 回傳 TOFU 記錄伺服器公鑰的檔案（~/.config/quic-client/known_hosts）。
+// 每行是 `<host:port> sha256//<base64>`，格式與 --pin 相同，# 開頭為註解。
+func knownHostsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "quic-client", "known_hosts"), nil
+}
+
+// hostKeyChangedError 表示伺服器的公鑰與 known_hosts 中記錄的不同。
+type hostKeyChangedError struct {
+	server, known, got string
+}
+
+func (e *hostKeyChangedError) Error() string {
+	return fmt.Sprintf("%s 的公鑰與 known_hosts 的紀錄不同，可能遭到中間人攻擊！\n"+
+		"  記錄的公鑰: %s\n  目前的公鑰: %s\n"+
+		"確認伺服器確實更換了金鑰後，以 `data_cli --forget %s` 刪除紀錄，下次連線時重新記錄", e.server, e.known, e.got, e.server)
+}
+
+// keyFingerprint 回傳憑證公鑰（SubjectPublicKeyInfo）的 SHA-256，格式同 --pin。
+// 只比對公鑰，伺服器以同一把金鑰更新憑證時不會被視為變更。
+func keyFingerprint(cs tls.ConnectionState) (string, error) {
+	if len(cs.PeerCertificates) == 0 {
+		return "", errors.New("伺服器沒有提供憑證")
+	}
+	sum := sha256.Sum256(cs.PeerCertificates[0].RawSubjectPublicKeyInfo)
+	return "sha256//" + base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// readKnownHosts 讀取所有紀錄；檔案不存在時回傳空的 map。
+func readKnownHosts(path string) (map[string]string, error) {
+	hosts := make(map[string]string)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return hosts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s 第 %d 行格式錯誤", path, n)
+		}
+		hosts[fields[0]] = fields[1]
+	}
+	return hosts, sc.Err()
+}
+
+// updateKnownHosts 在鎖定下把 server 的紀錄設為 fingerprint，fingerprint 為空時刪除紀錄。
+// 回傳原本的紀錄。其他行（包括註解）維持原樣。
+func updateKnownHosts(server, fingerprint string) (old string, err error) {
+	path, err := knownHostsPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	var out []string
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == server {
+			old = fields[1]
+			continue
+		}
+		if line != "" {
+			out = append(out, line)
+		}
+	}
+	if fingerprint != "" {
+		out = append(out, server+" "+fingerprint)
+	} else if old == "" {
+		return "", nil
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(out, "\n")+"\n"), 0o600); err != nil {
+		return "", err
+	}
+	return old, os.Rename(tmp, path)
+}
+
+// verifyKnownHost 是 --tofu 的 VerifyConnection：第一次連線時記錄伺服器的公鑰，之後公鑰不同就拒絕連線。
+// 與 ssh 的 accept-new 相同，變更的公鑰一律拒絕，只能以 --forget 明確刪除紀錄。
+func verifyKnownHost(server string) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		got, err := keyFingerprint(cs)
+		if err != nil {
+			return err
+		}
+		path, err := knownHostsPath()
+		if err != nil {
+			return err
+		}
+		hosts, err := readKnownHosts(path)
+		if err != nil {
+			return err
+		}
+		known, ok := hosts[server]
+		switch {
+		case known == got:
+			return nil
+		case !ok:
+			if _, err := updateKnownHosts(server, got); err != nil {
+				return fmt.Errorf("無法寫入 known_hosts: %v", err)
+			}
+			con.Printf("第一次連線到 %s，已記錄公鑰 %s\n", server, got)
+			return nil
+		}
+		return &hostKeyChangedError{server: server, known: known, got: got}
+	}
+}
+
+// runForget 實作 `--forget <server>`：刪除 known_hosts 中的紀錄，下次連線時重新記錄。
+func runForget(server string) error {
+	old, err := updateKnownHosts(server, "")
+	if err != nil {
+		return err
+	}
+	if old == "" {
+		return fmt.Errorf("known_hosts 中沒有 %s 的紀錄", server)
+	}
+	con.Printf("已刪除 %s 的紀錄（%s）\n", server, old)
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var limitSignals struct {
+	once   sync.Once
+	mu     sync.Mutex
+	groups []*limiterGroup
+}
+
+// watchLimitSignals 登記 g，收到 SIGUSR1 時調降、SIGUSR2 時調升所有群組的速度上限，
+// 不必重新開始就能對長時間的傳輸限速，例如 `kill -USR1 <pid>`。
+func watchLimitSignals(g *limiterGroup) {
+	limitSignals.mu.Lock()
+	limitSignals.groups = append(limitSignals.groups, g)
+	limitSignals.mu.Unlock()
+	limitSignals.once.Do(func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGUSR1, syscall.SIGUSR2)
+		go func() {
+			for s := range sig {
+				factor := limitStepDown
+				if s == syscall.SIGUSR2 {
+					factor = limitStepUp
+				}
+				limitSignals.mu.Lock()
+				for _, g := range limitSignals.groups {
+					if limit := g.Nudge(factor); limit > 0 {
+						con.Printf("\n速度上限: %s\n", humanRate(limit))
+					}
+				}
+				limitSignals.mu.Unlock()
+			}
+		}()
+	})
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// watchLimitSignals 在沒有 SIGUSR1/SIGUSR2 的平台上不做任何事，請改用 `ctl limit`。
+func watchLimitSignals(g *limiterGroup) {}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// runLs 實作 `ls [-l] [-r] [--sort name|size|mtime] [--json] [dir]`，套用 --include/--exclude 與 --max-depth。
+// 沒有 --sort 時依伺服器送出的順序逐筆輸出，不必等完整的列表。
+func runLs(ctx context.Context, session *quic.Conn, args []string, filters filterRules, maxDepth int) error {
+	flags := flag.NewFlagSet("ls", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "以 JSON 陣列輸出（name, size, mtime, type, hash, mode）")
+	longFormat := flags.Bool("l", false, "長格式：權限、大小、修改時間與名稱")
+	recursive := flags.Bool("r", false, "遞迴列出子目錄，名稱為相對於 dir 的路徑")
+	sortBy := flags.String("sort", "", "依 name、size（大的在前）或 mtime（新的在前）排序，需要先收完整個列表")
+	flags.Parse(args)
+	order, err := entryOrder(*sortBy)
+	if err != nil {
+		return err
+	}
+
+	jsonOut := *asJSON || con.json
+	out := newEntryWriter(os.Stdout, jsonOut)
+	out.long = *longFormat
+	long := jsonOut || *longFormat || *recursive || con.format != nil || *sortBy == "size" || *sortBy == "mtime"
+	write := func(e client.Entry) error {
+		if con.format != nil {
+			con.Result(e)
+			return nil
+		}
+		return out.Write(e)
+	}
+	var entries []client.Entry
+	emit := write
+	if order != nil {
+		emit = func(e client.Entry) error {
+			entries = append(entries, e)
+			return nil
+		}
+	}
+	err = listRemote(ctx, session, strings.Join(flags.Args(), " "), long, *recursive, filters, maxDepth, emit)
+	if err == nil && order != nil {
+		slices.SortStableFunc(entries, order)
+		for _, e := range entries {
+			if err = write(e); err != nil {
+				break
+			}
+		}
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// entryOrder 回傳 --sort 的比較函式，空字串表示不排序。
+func entryOrder(by string) (func(a, b client.Entry) int, error) {
+	switch by {
+	case "":
+		return nil, nil
+	case "name":
+		return func(a, b client.Entry) int { return strings.Compare(a.Name, b.Name) }, nil
+	case "size":
+		return func(a, b client.Entry) int {
+			return cmp.Or(cmp.Compare(b.Size, a.Size), strings.Compare(a.Name, b.Name))
+		}, nil
+	case "mtime":
+		return func(a, b client.Entry) int { return cmp.Or(b.Mtime.Compare(a.Mtime), strings.Compare(a.Name, b.Name)) }, nil
+	}
+	return nil, fmt.Errorf("無效的 --sort %q，可用 name、size 或 mtime", by)
+}
+
+// listRemote 列出遠端目錄 dir，recursive 時也列出子目錄的內容；項目的名稱是相對於 dir 的路徑，
+// 被 filters 排除的目錄整個略過。
+func listRemote(ctx context.Context, session *quic.Conn, dir string, long, recursive bool, filters filterRules, maxDepth int, fn func(client.Entry) error) error {
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		var subdirs []string
+		err := client.New(session).ListFunc(ctx, path.Join(dir, rel), long, func(e client.Entry) error {
+			e.Name = path.Join(rel, e.Name)
+			if !withinDepth(e.Name, maxDepth) || !filters.Included(e.Name, e.Type == "dir") {
+				return nil
+			}
+			if recursive && e.Type == "dir" {
+				subdirs = append(subdirs, e.Name)
+			}
+			return fn(e)
+		})
+		if err != nil {
+			return err
+		}
+		// 列表的 stream 讀完之後再深入子目錄
+		for _, sub := range subdirs {
+			if err := walk(sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk("")
+}
+
+// entryWriter 逐筆輸出列表項目；JSON 模式下以串流方式寫出一個陣列，不必先收集全部項目。
+type entryWriter struct {
+	w     io.Writer
+	json  bool
+	long  bool // 純文字的長格式，見 longEntry
+	count int
+}
+
+func newEntryWriter(w io.Writer, asJSON bool) *entryWriter {
+	return &entryWriter{w: w, json: asJSON}
+}
+
+func (ew *entryWriter) Write(e client.Entry) error {
+	if !ew.json {
+		name := e.Name
+		if e.Type == "dir" {
+			name += "/"
+		}
+		if ew.long {
+			name = longEntry(e)
+		}
+		_, err := fmt.Fprintln(ew.w, name)
+		return err
+	}
+	sep := ",\n"
+	if ew.count == 0 {
+		sep = "[\n"
+	}
+	ew.count++
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(ew.w, "%s  %s", sep, data)
+	return err
+}
+
+func (ew *entryWriter) Close() error {
+	if !ew.json {
+		return nil
+	}
+	if ew.count == 0 {
+		_, err := fmt.Fprintln(ew.w, "[]")
+		return err
+	}
+	_, err := fmt.Fprintln(ew.w, "\n]")
+	return err
+}
+
+// longEntry 以類似 `ls -l` 的格式顯示項目：權限、大小、修改時間與名稱。伺服器沒有提供權限時顯示 ?，
+// 半年以前的修改時間顯示年份而不是時刻。
+func longEntry(e client.Entry) string {
+	name, typ := e.Name, "-"
+	if e.Type == "dir" {
+		name, typ = name+"/", "d"
+	}
+	mode := "?????????"
+	if perm, ok := e.Perm(); ok {
+		mode = perm.String()[1:]
+	}
+	mtime := ""
+	if !e.Mtime.IsZero() {
+		layout := "Jan _2 15:04"
+		if time.Since(e.Mtime) > 183*24*time.Hour {
+			layout = "Jan _2  2006"
+		}
+		mtime = e.Mtime.Local().Format(layout)
+	}
+	return fmt.Sprintf("%s%s %10s %12s %s", typ, mode, humanSize(e.Size), mtime, name)
+}
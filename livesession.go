@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// clientKeepAlive 是 --keep-alive：閒置時送出 PING frame 的間隔，讓 NAT 與防火牆維持對應，
+// 也讓中斷的連線在閒置逾時後被偵測出來。0 表示不送。
+var clientKeepAlive = 10 * time.Second
+
+// liveSession 是長時間使用的連線（shell、sftp）。每次取用時檢查連線是否仍然存活，
+// 已中斷時回報原因並重新連線，使用者不必重新啟動程式。
+type liveSession struct {
+	mu     sync.Mutex
+	conn   *quic.Conn
+	server string
+	conf   *quic.Config
+}
+
+func newLiveSession(conn *quic.Conn, server string, conf *quic.Config) *liveSession {
+	return &liveSession{conn: conn, server: server, conf: conf}
+}
+
+// get 回傳可用的連線，原本的連線已中斷時重新連線。
+func (s *liveSession) get(ctx context.Context) (*quic.Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn.Context().Err() == nil {
+		return s.conn, nil
+	}
+	if ctx.Err() != nil {
+		// 使用者中斷時連線是被主動關閉的，不要重新連線
+		return nil, ctx.Err()
+	}
+	con.Printf("與 %s 的連線已中斷（%v），重新連線中…\n", s.server, context.Cause(s.conn.Context()))
+	promMetrics.retried()
+	conn, err := dial(ctx, s.server, s.conf)
+	if err != nil {
+		return nil, fmt.Errorf("無法重新連線到 %s: %w", s.server, err)
+	}
+	s.conn = conn
+	con.Printf("已重新連線到 %s (%s)\n", s.server, conn.RemoteAddr())
+	return conn, nil
+}
+
+// alive 回傳目前的連線是否仍然存活。
+func (s *liveSession) alive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.Context().Err() == nil
+}
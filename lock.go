@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// 建議性鎖定的協定：
+//
+//	lock <path> <ttl 秒數>           取得租約，回傳 "<token> <到期時間 RFC3339>"；已被持有時回 ERR
+//	renew <path> <token> <ttl 秒數>  延長租約，回傳新的到期時間
+//	unlock <path> <token>            釋放租約
+//
+// 租約到期後伺服器自動釋放，避免持有者當機後檔案永遠被鎖住。
+
+type lockResult struct {
+	Path    string    `json:"path"`
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// acquireLock 取得 path 的租約；wait > 0 時在被其他用戶端持有期間持續重試直到逾時。
+func acquireLock(ctx context.Context, session *quic.Conn, path string, ttl, wait time.Duration) (lockResult, error) {
+	c := client.New(session)
+	if err := c.CheckPath(path); err != nil {
+		return lockResult{}, err
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		reply, err := c.Request(ctx, fmt.Sprintf("lock %s %d", c.EncodePath(path), int(ttl.Seconds())))
+		if err == nil {
+			token, expires, _ := strings.Cut(reply, " ")
+			t, perr := time.Parse(time.RFC3339, expires)
+			if token == "" || perr != nil {
+				return lockResult{}, fmt.Errorf("無效的 lock 回應 %q", client.Snippet(reply))
+			}
+			return lockResult{Path: path, Token: token, Expires: t}, nil
+		}
+		if wait <= 0 || time.Now().After(deadline) {
+			return lockResult{}, err
+		}
+		time.Sleep(min(time.Second, time.Until(deadline)))
+	}
+}
+
+// runLock 實作 `lock [-ttl d] [-wait d] <path> [-- command...]`。
+// 沒有指定命令時印出 token 供之後 unlock；指定命令時在持有租約期間執行它，
+// 每隔 ttl/3 續約，命令結束後釋放租約並以命令的結束碼離開。
+func runLock(ctx context.Context, session *quic.Conn, args []string) error {
+	flags := flag.NewFlagSet("lock", flag.ExitOnError)
+	ttl := flags.Duration("ttl", time.Minute, "租約長度，到期後伺服器自動釋放")
+	wait := flags.Duration("wait", 0, "鎖被持有時最多等待多久，0 表示立即失敗")
+	flags.Parse(args)
+	if flags.NArg() < 1 || flags.NArg() > 1 && flags.Arg(1) != "--" {
+		return errors.New("用法: data_cli <ip:port> lock [-ttl 1m] [-wait 30s] <path> [-- command...]")
+	}
+	if *ttl < time.Second {
+		return errors.New("-ttl 至少要 1s")
+	}
+	path := flags.Arg(0)
+	l, err := acquireLock(ctx, session, path, *ttl, *wait)
+	if err != nil {
+		return fmt.Errorf("無法鎖定 %s: %w", path, err)
+	}
+	con.Result(l)
+	if flags.NArg() < 3 {
+		con.Printf("已鎖定 %s，token %s，到期 %s\n", path, l.Token, l.Expires.Local().Format(time.DateTime))
+		return nil
+	}
+
+	c := client.New(session)
+	renewCtx, stopRenew := context.WithCancel(ctx)
+	renewErr := make(chan error, 1)
+	go func() {
+		ticker := time.NewTicker(*ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-renewCtx.Done():
+				renewErr <- nil
+				return
+			case <-ticker.C:
+				if _, err := c.Request(renewCtx, fmt.Sprintf("renew %s %s %d", c.EncodePath(path), l.Token, int(ttl.Seconds()))); err != nil && renewCtx.Err() == nil {
+					renewErr <- err
+					return
+				}
+			}
+		}
+	}()
+
+	cmd := exec.Command(flags.Arg(2), flags.Args()[3:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	runErr := cmd.Run()
+	stopRenew()
+	if err := <-renewErr; err != nil {
+		con.Printf("續約 %s 失敗，命令執行期間租約可能已失效: %v\n", path, err)
+	}
+	if _, err := c.Request(ctx, fmt.Sprintf("unlock %s %s", c.EncodePath(path), l.Token)); err != nil {
+		con.Printf("無法釋放 %s: %v\n", path, err)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) && exitErr.ExitCode() > 0 {
+		return &exitStatusError{code: exitErr.ExitCode(), err: fmt.Errorf("%s: %w", flags.Arg(2), runErr)}
+	}
+	return runErr
+}
+
+// runUnlock 以 lock 取得的 token 釋放租約。
+func runUnlock(ctx context.Context, session *quic.Conn, args []string) error {
+	if len(args) != 2 {
+		return errors.New("用法: data_cli <ip:port> unlock <path> <token>")
+	}
+	c := client.New(session)
+	if err := c.CheckPath(args[0]); err != nil {
+		return err
+	}
+	if _, err := c.Request(ctx, fmt.Sprintf("unlock %s %s", c.EncodePath(args[0]), args[1])); err != nil {
+		return fmt.Errorf("無法釋放 %s: %w", args[0], err)
+	}
+	con.Println("已釋放:", args[0])
+	return nil
+}
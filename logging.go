@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// logOptions 是 --log-level、--log-format 與 --log-file。警告、錯誤與 --verbose 的診斷訊息都經由 slog 輸出，
+// 可以選擇以 JSON 寫到檔案，和 stdout 上的結果或進度列分開。
+type logOptions struct {
+	level  string
+	format string
+	file   string
+	out    *os.File // --log-file 開啟的檔案
+}
+
+var clientLog = &logOptions{format: "text"}
+
+// setup 依設定建立預設的 slog logger（標準 log 套件的輸出也會經過它）。
+// 沒有指定 --log-level 時預設為 info，--verbose 時為 debug。
+func (o *logOptions) setup(verbose bool) error {
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	}
+	if o.level != "" {
+		if err := level.UnmarshalText([]byte(o.level)); err != nil {
+			return fmt.Errorf("無效的 --log-level %q（可用 debug、info、warn、error）", o.level)
+		}
+	}
+	var w io.Writer = progressSafeWriter{os.Stderr}
+	o.close()
+	if o.file != "" {
+		f, err := os.OpenFile(o.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		w, o.out = f, f
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch o.format {
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		o.close()
+		return fmt.Errorf("無效的 --log-format %q（可用 text、json）", o.format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// close 關閉日誌檔。main 在記錄最後的錯誤之後才呼叫，讓錯誤也寫進 --log-file。
+func (o *logOptions) close() {
+	if o.out != nil {
+		o.out.Close()
+		o.out = nil
+	}
+}
+
+// progressSafeWriter 寫入日誌前先清除顯示中的進度列，進度列在下一次更新時重畫，
+// 日誌不會接在進度列的同一行後面。
+type progressSafeWriter struct {
+	w io.Writer
+}
+
+func (p progressSafeWriter) Write(b []byte) (int, error) {
+	if con.inline.Swap(false) {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+	return p.w.Write(b)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// manifest 收集一批下載的結果，結束後寫成 SHA256SUMS 格式（"<hex>  <path>"）。
+type manifest struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]string // 本機路徑 -> sha256
+}
+
+// newManifest 回傳寫入 path 的 manifest；path 為空時回傳 nil，其方法皆不做事。
+func newManifest(path string) *manifest {
+	if path == "" {
+		return nil
+	}
+	return &manifest{path: path, entries: make(map[string]string)}
+}
+
+func (m *manifest) Add(local, sum string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.entries[local] = sum
+	m.mu.Unlock()
+}
+
+// Write 寫出 manifest，路徑相對於 manifest 所在目錄，依路徑排序。
+func (m *manifest) Write() error {
+	if m == nil || len(m.entries) == 0 {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	base, err := filepath.Abs(filepath.Dir(m.path))
+	if err != nil {
+		return err
+	}
+	lines := make([]string, 0, len(m.entries))
+	for local, sum := range m.entries {
+		abs, err := filepath.Abs(local)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, abs)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s\n", sum, filepath.ToSlash(rel)))
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i][66:] < lines[j][66:]
+	})
+	return os.WriteFile(m.path, []byte(strings.Join(lines, "")), 0o644)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/logging"
+)
+
+// connMetrics 透過 quic-go 的 ConnectionTracer 收集連線的封包、遺失與 RTT 統計。
+type connMetrics struct {
+	packetsSent     atomic.Int64
+	bytesSent       atomic.Int64
+	packetsReceived atomic.Int64
+	bytesReceived   atomic.Int64
+	packetsLost     atomic.Int64
+	bytesLost       atomic.Int64 // 遺失後需要重傳的位元組數（以封包大小估算）
+	smoothedRTT     atomic.Int64
+	latestRTT       atomic.Int64
+	cwnd            atomic.Int64
+	bytesInFlight   atomic.Int64
+
+	mu       sync.Mutex
+	inFlight map[logging.PacketNumber]logging.ByteCount // 尚未確認的 1-RTT 封包大小
+}
+
+// metricsSnapshot 是某一時刻的連線統計。
+type metricsSnapshot struct {
+	PacketsSent     int64         `json:"packets_sent"`
+	BytesSent       int64         `json:"bytes_sent"`
+	PacketsReceived int64         `json:"packets_received"`
+	BytesReceived   int64         `json:"bytes_received"`
+	PacketsLost     int64         `json:"packets_lost"`
+	BytesLost       int64         `json:"bytes_lost"`
+	SmoothedRTT     time.Duration `json:"smoothed_rtt"`
+	LatestRTT       time.Duration `json:"latest_rtt"`
+	CongestionWnd   int64         `json:"cwnd"`
+	BytesInFlight   int64         `json:"bytes_in_flight"`
+}
+
+// LossRate 回傳送出封包中遺失的比例。
+func (s metricsSnapshot) LossRate() float64 {
+	if s.PacketsSent == 0 {
+		return 0
+	}
+	return float64(s.PacketsLost) / float64(s.PacketsSent)
+}
+
+// Bandwidth 以 cwnd / smoothed RTT 估算壅塞控制器目前允許的速率（bytes/s）。
+// 與實際速率比較可判斷瓶頸在網路還是應用層限速；注意 cwnd 是本端傳送方向的視窗。
+func (s metricsSnapshot) Bandwidth() float64 {
+	if s.SmoothedRTT <= 0 {
+		return 0
+	}
+	return float64(s.CongestionWnd) / s.SmoothedRTT.Seconds()
+}
+
+// connMetricsByConn 以連線為鍵保存統計，由 dial 登記。
+var connMetricsByConn sync.Map
+
+// metricsOf 回傳連線的統計；不是由 dial 建立的連線回傳 nil。
+func metricsOf(conn *quic.Conn) *connMetrics {
+	if m, ok := connMetricsByConn.Load(conn); ok {
+		return m.(*connMetrics)
+	}
+	return nil
+}
+
+func newConnMetrics() *connMetrics {
+	return &connMetrics{inFlight: make(map[logging.PacketNumber]logging.ByteCount)}
+}
+
+func (m *connMetrics) tracer(context.Context, logging.Perspective, logging.ConnectionID) *logging.ConnectionTracer {
+	return &logging.ConnectionTracer{
+		SentLongHeaderPacket: func(_ *logging.ExtendedHeader, size logging.ByteCount, _ logging.ECN, _ *logging.AckFrame, _ []logging.Frame) {
+			m.packetsSent.Add(1)
+			m.bytesSent.Add(int64(size))
+		},
+		SentShortHeaderPacket: func(hdr *logging.ShortHeader, size logging.ByteCount, _ logging.ECN, _ *logging.AckFrame, _ []logging.Frame) {
+			m.packetsSent.Add(1)
+			m.bytesSent.Add(int64(size))
+			m.mu.Lock()
+			m.inFlight[hdr.PacketNumber] = size
+			m.mu.Unlock()
+		},
+		ReceivedLongHeaderPacket: func(_ *logging.ExtendedHeader, size logging.ByteCount, _ logging.ECN, _ []logging.Frame) {
+			m.packetsReceived.Add(1)
+			m.bytesReceived.Add(int64(size))
+		},
+		ReceivedShortHeaderPacket: func(_ *logging.ShortHeader, size logging.ByteCount, _ logging.ECN, _ []logging.Frame) {
+			m.packetsReceived.Add(1)
+			m.bytesReceived.Add(int64(size))
+		},
+		AcknowledgedPacket: func(level logging.EncryptionLevel, pn logging.PacketNumber) {
+			if level == logging.Encryption1RTT {
+				m.mu.Lock()
+				delete(m.inFlight, pn)
+				m.mu.Unlock()
+			}
+		},
+		LostPacket: func(level logging.EncryptionLevel, pn logging.PacketNumber, _ logging.PacketLossReason) {
+			m.packetsLost.Add(1)
+			if level != logging.Encryption1RTT {
+				return
+			}
+			m.mu.Lock()
+			m.bytesLost.Add(int64(m.inFlight[pn]))
+			delete(m.inFlight, pn)
+			m.mu.Unlock()
+		},
+		UpdatedMetrics: func(rtt *logging.RTTStats, cwnd, inFlight logging.ByteCount, _ int) {
+			m.smoothedRTT.Store(int64(rtt.SmoothedRTT()))
+			m.latestRTT.Store(int64(rtt.LatestRTT()))
+			m.cwnd.Store(int64(cwnd))
+			m.bytesInFlight.Store(int64(inFlight))
+		},
+	}
+}
+
+func (m *connMetrics) Snapshot() metricsSnapshot {
+	if m == nil {
+		return metricsSnapshot{}
+	}
+	return metricsSnapshot{
+		PacketsSent:     m.packetsSent.Load(),
+		BytesSent:       m.bytesSent.Load(),
+		PacketsReceived: m.packetsReceived.Load(),
+		BytesReceived:   m.bytesReceived.Load(),
+		PacketsLost:     m.packetsLost.Load(),
+		BytesLost:       m.bytesLost.Load(),
+		SmoothedRTT:     time.Duration(m.smoothedRTT.Load()),
+		LatestRTT:       time.Duration(m.latestRTT.Load()),
+		CongestionWnd:   m.cwnd.Load(),
+		BytesInFlight:   m.bytesInFlight.Load(),
+	}
+}
+
+// progressSuffix 是 --verbose 時附加在進度列後的遺失、RTT 與頻寬估計資訊。
+func (m *connMetrics) progressSuffix() string {
+	s := m.Snapshot()
+	return fmt.Sprintf(" | loss %.2f%% (%d/%d pkts, %s retx) rtt %v cwnd %s est %s/s",
+		s.LossRate()*100, s.PacketsLost, s.PacketsSent, humanSize(s.BytesLost), s.SmoothedRTT.Round(100*time.Microsecond),
+		humanSize(s.CongestionWnd), humanSize(int64(s.Bandwidth())))
+}
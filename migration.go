@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// migrateCheckInterval 是檢查網路路徑是否改變的間隔；migrateProbeTimeout 是驗證新路徑的逾時。
+const (
+	migrateCheckInterval = 2 * time.Second
+	migrateProbeTimeout  = 5 * time.Second
+)
+
+// noMigrate 是 --no-migrate：網路改變時不嘗試遷移連線。
+var noMigrate bool
+
+// networkChanged 記錄因網路改變且無法遷移而由本機關閉的連線；retryOptions.do 對這些連線
+// 一律重新連線並續傳，不計入 --retries。
+var networkChanged sync.Map // *quic.Conn -> struct{}
+
+// migrateConnIDLength 是可遷移連線使用的 connection ID 長度。quic.DialAddr 自行建立的 socket
+// 只給一條連線使用，connection ID 長度為 0，換了位址後伺服器無法認出連線，不能遷移。
+const migrateConnIDLength = 8
+
+// dialMigratable 在自己建立的 quic.Transport 上連線，使用非空的 connection ID，讓 watchPath 可以遷移連線；
+// socket 在連線結束時關閉。
+func dialMigratable(ctx context.Context, server string, tlsConf *tls.Config, conf *quic.Config, early bool) (*quic.Conn, error) {
+	addr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		return nil, err
+	}
+	udp, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	tr := &quic.Transport{Conn: udp, ConnectionIDLength: migrateConnIDLength}
+	dial := tr.Dial
+	if early {
+		dial = tr.DialEarly
+	}
+	session, err := dial(ctx, addr, tlsConf, conf)
+	if err != nil {
+		tr.Close()
+		return nil, err
+	}
+	context.AfterFunc(session.Context(), func() { tr.Close() })
+	return session, nil
+}
+
+// routeSource 回傳系統送往 remote 時選用的本機位址。UDP 的 connect 只查路由表，不會送出封包。
+func routeSource(remote net.Addr) (netip.Addr, error) {
+	c, err := net.DialUDP("udp", nil, remote.(*net.UDPAddr))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer c.Close()
+	return c.LocalAddr().(*net.UDPAddr).AddrPort().Addr().Unmap(), nil
+}
+
+// watchPath 定期檢查送往伺服器的本機位址，例如從 Wi-Fi 換到有線網路或 VPN 連上、斷開時會改變。
+// 改變時以 QUIC 的連線遷移（以 connection ID 識別連線）把連線移到綁定新位址的 socket 上，
+// 進行中的 stream 不受影響；伺服器不允許遷移或新路徑驗證失敗時關閉連線，由重試重新連線並續傳。
+// 使用 --bind、--proxy 或 --impair 時 socket 由使用者指定，不做遷移。
+func watchPath(session *quic.Conn, server string) {
+	if noMigrate || activeImpairment != nil || clientProxy != nil || clientNet.custom() {
+		return
+	}
+	current, err := routeSource(session.RemoteAddr())
+	if err != nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(migrateCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-session.Context().Done():
+				return
+			case <-ticker.C:
+			}
+			src, err := routeSource(session.RemoteAddr())
+			if err != nil || src == current {
+				// 沒有路由（網路斷線）時等它恢復；連線若已逾時中斷由重試處理
+				continue
+			}
+			slog.Info("網路路徑改變", "server", server, "from", current, "to", src)
+			if err := migrate(session, src); err != nil {
+				slog.Warn("無法遷移連線，重新連線", "server", server, "err", err)
+				networkChanged.Store(session, struct{}{})
+				session.CloseWithError(0, "network changed")
+				return
+			}
+			current = src
+			slog.Info("連線已遷移到新的網路路徑", "server", server, "addr", src)
+			con.Event(transferEvent{Event: "migrated", File: server, Path: src.String()})
+		}
+	}()
+}
+
+// migrate 在綁定 src 的新 socket 上驗證到伺服器的路徑並切換過去；新的 socket 在連線結束時關閉。
+func migrate(session *quic.Conn, src netip.Addr) error {
+	udp, err := net.ListenUDP("udp", net.UDPAddrFromAddrPort(netip.AddrPortFrom(src, 0)))
+	if err != nil {
+		return err
+	}
+	tr := &quic.Transport{Conn: udp, ConnectionIDLength: migrateConnIDLength}
+	path, err := session.AddPath(tr)
+	if err != nil {
+		tr.Close()
+		return err
+	}
+	ctx, cancel := context.WithTimeout(session.Context(), migrateProbeTimeout)
+	defer cancel()
+	if err := path.Probe(ctx); err != nil {
+		path.Close()
+		tr.Close()
+		return err
+	}
+	if err := path.Switch(); err != nil {
+		path.Close()
+		tr.Close()
+		return err
+	}
+	context.AfterFunc(session.Context(), func() { tr.Close() })
+	return nil
+}
@@ -0,0 +1,180 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// mountListTTL 是目錄列表的快取時間，期間內的 lookup 不會重新送出 ls。
+const mountListTTL = 5 * time.Second
+
+// runMount 把遠端目錄樹以唯讀 FUSE 檔案系統掛載到 mountpoint，直到收到中斷訊號、被 umount 或連線中斷。
+// 目錄在第一次瀏覽時才以 `ls -l` 取得，檔案內容在讀取時才以 `get <file> <offset>` 下載。
+func runMount(session *quic.Conn, server, mountpoint string) error {
+	root := &remoteNode{session: session, entry: client.Entry{Type: "dir"}}
+	timeout := mountListTTL
+	srv, err := fs.Mount(mountpoint, root, &fs.Options{
+		MountOptions: fuse.MountOptions{FsName: server, Name: "quic"},
+		EntryTimeout: &timeout,
+		AttrTimeout:  &timeout,
+	})
+	if err != nil {
+		return fmt.Errorf("無法掛載 %s: %v", mountpoint, err)
+	}
+	con.Printf("已將 %s 掛載到 %s（唯讀），按 Ctrl-C 或執行 umount 卸載\n", server, mountpoint)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		select {
+		case <-sig:
+		case <-session.Context().Done():
+			con.Printf("與 %s 的連線中斷，卸載 %s\n", server, mountpoint)
+		}
+		srv.Unmount()
+	}()
+	srv.Wait()
+	return nil
+}
+
+// remoteNode 是掛載樹中的一個遠端檔案或目錄。
+type remoteNode struct {
+	fs.Inode
+	session *quic.Conn
+	path    string // 相對遠端根目錄的路徑，根目錄為空字串
+	entry   client.Entry
+
+	mu       sync.Mutex
+	children map[string]client.Entry
+	listed   time.Time
+}
+
+var (
+	_ fs.NodeLookuper  = (*remoteNode)(nil)
+	_ fs.NodeReaddirer = (*remoteNode)(nil)
+	_ fs.NodeGetattrer = (*remoteNode)(nil)
+	_ fs.NodeOpener    = (*remoteNode)(nil)
+)
+
+// list 回傳目錄內容，在 mountListTTL 內重複使用上一次的結果。
+func (n *remoteNode) list(ctx context.Context) (map[string]client.Entry, syscall.Errno) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.children != nil && time.Since(n.listed) < mountListTTL {
+		return n.children, 0
+	}
+	children := make(map[string]client.Entry)
+	err := client.New(n.session).ListFunc(ctx, n.path, true, func(e client.Entry) error {
+		children[e.Name] = e
+		return nil
+	})
+	if err != nil {
+		con.Printf("無法列出 %q: %v\n", n.path, err)
+		return nil, syscall.EIO
+	}
+	n.children, n.listed = children, time.Now()
+	return children, 0
+}
+
+func (n *remoteNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	children, errno := n.list(ctx)
+	if errno != 0 {
+		return nil, errno
+	}
+	e, ok := children[name]
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+	child := &remoteNode{session: n.session, path: path.Join(n.path, name), entry: e}
+	child.fillAttr(&out.Attr)
+	return n.NewInode(ctx, child, fs.StableAttr{Mode: child.mode()}), 0
+}
+
+func (n *remoteNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	children, errno := n.list(ctx)
+	if errno != 0 {
+		return nil, errno
+	}
+	entries := make([]fuse.DirEntry, 0, len(children))
+	for name, e := range children {
+		child := remoteNode{entry: e}
+		entries = append(entries, fuse.DirEntry{Name: name, Mode: child.mode()})
+	}
+	return fs.NewListDirStream(entries), 0
+}
+
+func (n *remoteNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	n.fillAttr(&out.Attr)
+	return 0
+}
+
+func (n *remoteNode) mode() uint32 {
+	if n.entry.Type == "dir" {
+		return syscall.S_IFDIR
+	}
+	return syscall.S_IFREG
+}
+
+func (n *remoteNode) fillAttr(a *fuse.Attr) {
+	if n.entry.Type == "dir" {
+		a.Mode = syscall.S_IFDIR | 0555
+	} else {
+		a.Mode = syscall.S_IFREG | 0444
+		a.Size = uint64(n.entry.Size)
+	}
+	if !n.entry.Mtime.IsZero() {
+		a.SetTimes(nil, &n.entry.Mtime, &n.entry.Mtime)
+	}
+}
+
+func (n *remoteNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR|syscall.O_TRUNC|syscall.O_APPEND) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+	return &remoteHandle{rr: remoteReader{session: n.session, path: n.path, size: n.entry.Size}}, fuse.FOPEN_KEEP_CACHE, 0
+}
+
+// remoteHandle 是一個開啟中的遠端檔案。
+type remoteHandle struct {
+	mu sync.Mutex
+	rr remoteReader
+}
+
+var (
+	_ fs.FileReader   = (*remoteHandle)(nil)
+	_ fs.FileReleaser = (*remoteHandle)(nil)
+)
+
+func (h *remoteHandle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n, err := h.rr.ReadAt(ctx, dest, off)
+	if err != nil && err != io.EOF {
+		con.Printf("無法讀取 %s: %v\n", h.rr.path, err)
+		return nil, syscall.EIO
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+func (h *remoteHandle) Release(ctx context.Context) syscall.Errno {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rr.Close()
+	return 0
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"errors"
+
+	"github.com/quic-go/quic-go"
+)
+
+func runMount(session *quic.Conn, server, mountpoint string) error {
+	return errors.New("此平台不支援 mount")
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// netOptions 是 --bind、--ipv4、--ipv6 與 --dial-all-timeout 的設定。
+type netOptions struct {
+	bind    string // 本機位址、位址:埠號或網路介面名稱
+	ipv4    bool
+	ipv6    bool
+	dialAll time.Duration // 同時嘗試多個位址時整體的逾時，0 表示只受 --connect-timeout 限制
+
+	network string       // udp、udp4 或 udp6，由 validate 決定
+	laddr   *net.UDPAddr // nil 表示由系統選擇
+
+	once      sync.Once
+	transport *quic.Transport
+	err       error
+}
+
+var clientNet netOptions
+
+// custom 回傳是否需要自行建立 UDP socket。
+func (o *netOptions) custom() bool {
+	return o.laddr != nil || o.ipv4 || o.ipv6
+}
+
+// validate 解析 --bind 並決定位址家族；--bind 是 IPv4 位址時只解析伺服器的 IPv4 位址，反之亦然。
+func (o *netOptions) validate() error {
+	o.network = "udp"
+	switch {
+	case o.ipv4 && o.ipv6:
+		return errors.New("--ipv4 與 --ipv6 不能同時使用")
+	case o.ipv4:
+		o.network = "udp4"
+	case o.ipv6:
+		o.network = "udp6"
+	}
+	if o.bind == "" {
+		return nil
+	}
+	host, port := o.bind, 0
+	if h, p, err := net.SplitHostPort(o.bind); err == nil {
+		if port, err = strconv.Atoi(p); err != nil || port < 0 || port > 65535 {
+			return fmt.Errorf("無效的 --bind 埠號 %q", p)
+		}
+		host = h
+	}
+	ip, err := o.bindIP(host)
+	if err != nil {
+		return err
+	}
+	switch {
+	case ip == nil:
+	case ip.To4() != nil && o.network == "udp6", ip.To4() == nil && o.network == "udp4":
+		return fmt.Errorf("--bind %s 與指定的位址家族不符", o.bind)
+	case ip.To4() != nil:
+		o.network = "udp4"
+	default:
+		o.network = "udp6"
+	}
+	o.laddr = &net.UDPAddr{IP: ip, Port: port}
+	return nil
+}
+
+// bindIP 把 --bind 的主機部分轉成 IP；也可以是網路介面名稱，此時取該介面上符合位址家族的第一個位址。
+// 空字串（例如 :5000）表示只指定埠號。
+func (o *netOptions) bindIP(host string) (net.IP, error) {
+	if host == "" {
+		return nil, nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, nil
+	}
+	iface, err := net.InterfaceByName(host)
+	if err != nil {
+		return nil, fmt.Errorf("無效的 --bind %q：不是 IP 位址或網路介面", o.bind)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if is4 := ipnet.IP.To4() != nil; (is4 && o.network != "udp6") || (!is4 && o.network != "udp4") {
+			return ipnet.IP, nil
+		}
+	}
+	return nil, fmt.Errorf("網路介面 %s 沒有可用的位址", host)
+}
+
+// sharedTransport 回傳綁定在固定埠號上的 quic.Transport。同一個 socket 不能重複綁定，
+// 因此所有連線（包括重試與平行下載）都經由這個 transport 多工。
+func (o *netOptions) sharedTransport() (*quic.Transport, error) {
+	o.once.Do(func() {
+		var udp *net.UDPConn
+		if udp, o.err = net.ListenUDP(o.network, o.laddr); o.err != nil {
+			return
+		}
+		var conn net.PacketConn = udp
+		if activeImpairment != nil {
+			conn = activeImpairment.wrap(conn)
+		}
+		o.transport = &quic.Transport{Conn: conn}
+	})
+	return o.transport, o.err
+}
+
+// dialPacketConn 自行建立 UDP socket，依序套用 --bind、--proxy 與 --impair 後建立 QUIC 連線；
+// socket 與 proxy 的控制連線在 QUIC 連線結束時關閉。
+func dialPacketConn(ctx context.Context, server string, tlsConf *tls.Config, conf *quic.Config, early bool) (*quic.Conn, error) {
+	network := cmp.Or(clientNet.network, "udp")
+	addr, err := net.ResolveUDPAddr(network, server)
+	if err != nil {
+		return nil, err
+	}
+	if clientNet.laddr != nil && clientNet.laddr.Port != 0 && clientProxy == nil {
+		tr, err := clientNet.sharedTransport()
+		if err != nil {
+			return nil, err
+		}
+		if early {
+			return tr.DialEarly(ctx, addr, tlsConf, conf)
+		}
+		return tr.Dial(ctx, addr, tlsConf, conf)
+	}
+	if clientProxy != nil && clientNet.laddr == nil {
+		// 封包送往 proxy 的 relay，伺服器的位址家族不限制本機 socket
+		network = "udp"
+	}
+	udp, err := net.ListenUDP(network, clientNet.laddr)
+	if err != nil {
+		return nil, err
+	}
+	var pconn net.PacketConn = udp
+	if clientProxy != nil {
+		if pconn, err = clientProxy.associate(ctx, udp, clientNet.laddr); err != nil {
+			udp.Close()
+			return nil, err
+		}
+	}
+	if activeImpairment != nil {
+		pconn = activeImpairment.wrap(pconn)
+	}
+	dial := quic.Dial
+	if early {
+		dial = quic.DialEarly
+	}
+	session, err := dial(ctx, pconn, addr, tlsConf, conf)
+	if err != nil {
+		pconn.Close()
+		return nil, err
+	}
+	context.AfterFunc(session.Context(), func() { pconn.Close() })
+	return session, nil
+}
+
+// connectionAttemptDelay 是 Happy Eyeballs（RFC 8305）在前一個位址還沒有結果時，
+// 開始嘗試下一個位址前等待的時間。
+const connectionAttemptDelay = 250 * time.Millisecond
+
+// resolveServer 回傳 server 解析出的所有位址（host:port），依 RFC 8305 從第一個位址的家族開始
+// 交錯排列 IPv6 與 IPv4。server 是 IP 位址、只解析出一個位址或無法解析時回傳 server 本身，
+// 錯誤留給實際連線時回報。
+func resolveServer(ctx context.Context, server string) []string {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil || net.ParseIP(host) != nil {
+		return []string{server}
+	}
+	network := "ip"
+	switch clientNet.network {
+	case "udp4":
+		network = "ip4"
+	case "udp6":
+		network = "ip6"
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, network, host)
+	if err != nil || len(ips) < 2 {
+		return []string{server}
+	}
+	var first, other []netip.Addr
+	for _, ip := range ips {
+		if ip.Unmap().Is4() == ips[0].Unmap().Is4() {
+			first = append(first, ip.Unmap())
+		} else {
+			other = append(other, ip.Unmap())
+		}
+	}
+	addrs := make([]string, 0, len(ips))
+	for i := 0; i < len(first) || i < len(other); i++ {
+		if i < len(first) {
+			addrs = append(addrs, net.JoinHostPort(first[i].String(), port))
+		}
+		if i < len(other) {
+			addrs = append(addrs, net.JoinHostPort(other[i].String(), port))
+		}
+	}
+	return addrs
+}
+
+// dialAllTimeoutError 表示 --dial-all-timeout 到期時沒有任何位址完成交握。
+type dialAllTimeoutError struct {
+	server string
+	limit  time.Duration
+	err    error
+}
+
+func (e *dialAllTimeoutError) Error() string {
+	return fmt.Sprintf("無法在 %v 內連線到 %s 的任何位址（可用 --dial-all-timeout 調整）: %v", e.limit, e.server, e.err)
+}
+
+func (e *dialAllTimeoutError) Unwrap() error { return e.err }
+
+// dialAttempt 是對單一位址的連線嘗試，回傳連線與它專屬的統計。
+type dialAttempt func(ctx context.Context, addr string) (*quic.Conn, *connMetrics, error)
+
+// happyEyeballs 在 server 解析出多個位址時依序啟動連線嘗試：前一個失敗或超過
+// connectionAttemptDelay 還沒有結果就開始下一個，採用第一個完成交握的連線並取消其餘的嘗試。
+func happyEyeballs(ctx context.Context, server string, attempt dialAttempt) (*quic.Conn, *connMetrics, error) {
+	addrs := resolveServer(ctx, server)
+	if len(addrs) == 1 {
+		return attempt(ctx, addrs[0])
+	}
+	raceCtx, cancel := context.WithCancel(ctx)
+	if clientNet.dialAll > 0 {
+		raceCtx, cancel = context.WithTimeout(ctx, clientNet.dialAll)
+	}
+	defer cancel()
+
+	type result struct {
+		addr    string
+		conn    *quic.Conn
+		metrics *connMetrics
+		err     error
+	}
+	results := make(chan result, len(addrs))
+	launched, pending := 0, 0
+	launch := func() {
+		addr := addrs[launched]
+		launched++
+		pending++
+		if clientTrace.verbose {
+			slog.Debug("嘗試連線", "server", server, "addr", addr, "attempt", launched, "addrs", len(addrs))
+		}
+		go func() {
+			conn, metrics, err := attempt(raceCtx, addr)
+			if err == nil {
+				// 0-RTT 的連線在交握完成前就會回傳，無法判斷位址是否可用，因此等交握完成才算成功
+				select {
+				case <-conn.HandshakeComplete():
+				case <-conn.Context().Done():
+					err = context.Cause(conn.Context())
+				case <-raceCtx.Done():
+					conn.CloseWithError(0, "")
+					err = raceCtx.Err()
+				}
+			}
+			results <- result{addr, conn, metrics, err}
+		}()
+	}
+	launch()
+	timer := time.NewTimer(connectionAttemptDelay)
+	defer timer.Stop()
+	var errs []error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if launched < len(addrs) {
+				launch()
+				timer.Reset(connectionAttemptDelay)
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// 其餘的嘗試隨 raceCtx 取消；已經成功的連線直接關閉
+				go func(n int) {
+					for range n {
+						if r := <-results; r.err == nil {
+							r.conn.CloseWithError(0, "")
+						}
+					}
+				}(pending)
+				return r.conn, r.metrics, nil
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", r.addr, r.err))
+			if launched < len(addrs) {
+				launch()
+				timer.Reset(connectionAttemptDelay)
+			}
+		}
+	}
+	err := errors.Join(errs...)
+	if clientNet.dialAll > 0 && ctx.Err() == nil && errors.Is(raceCtx.Err(), context.DeadlineExceeded) {
+		return nil, nil, &dialAllTimeoutError{server: server, limit: clientNet.dialAll, err: err}
+	}
+	return nil, nil, err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// localPath 把遠端路徑對應到本機路徑。預設只取檔名；parents 為真時保留目錄階層。
+// 遠端路徑一律視為相對於下載目錄，"../" 與絕對路徑不會逃出該目錄。
+func localPath(remote string, parents bool) (string, error) {
+	rel := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(remote, "\\", "/")), "/")
+	if rel == "" {
+		return "", fmt.Errorf("無效的遠端路徑 %q", remote)
+	}
+	if !parents {
+		return path.Base(rel), nil
+	}
+	return filepath.FromSlash(rel), nil
+}
+
+// outputIsDir 回報 -o 指定的是目錄：已存在的目錄，或以路徑分隔字元結尾（不存在時會建立）。
+func outputIsDir(output string) bool {
+	if strings.HasSuffix(output, "/") || strings.HasSuffix(output, string(filepath.Separator)) {
+		return true
+	}
+	info, err := os.Stat(output)
+	return err == nil && info.IsDir()
+}
+
+// destination 依 -o 決定 remote 下載到的本機路徑：未指定時同 localPath；
+// 指定目錄時放在該目錄下，否則 -o 就是目的檔案。
+func destination(remote, output string, parents bool) (string, error) {
+	local, err := localPath(remote, parents)
+	if err != nil || output == "" {
+		return local, err
+	}
+	if outputIsDir(output) {
+		return filepath.Join(output, local), nil
+	}
+	return output, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go-client/client"
+)
+
+// permissions 是 --chmod 指定的檔案與目錄權限；未指定的部分沿用行程的 umask。
+type permissions struct {
+	file, dir       os.FileMode
+	setFile, setDir bool
+}
+
+// parseChmod 解析 "0640"（僅檔案）或 rsync 風格的 "D0750,F0640"。
+func parseChmod(s string) (permissions, error) {
+	var p permissions
+	if s == "" {
+		return p, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		target := byte('F')
+		if len(part) > 0 && (part[0] == 'D' || part[0] == 'F') {
+			target = part[0]
+			part = part[1:]
+		}
+		mode, err := strconv.ParseUint(part, 8, 32)
+		if err != nil || mode > 0o7777 {
+			return p, fmt.Errorf("無效的 --chmod %q", s)
+		}
+		if target == 'D' {
+			p.dir, p.setDir = os.FileMode(mode), true
+		} else {
+			p.file, p.setFile = os.FileMode(mode), true
+		}
+	}
+	return p, nil
+}
+
+func (p permissions) applyFile(f *os.File) error {
+	if !p.setFile {
+		return nil
+	}
+	return f.Chmod(p.file)
+}
+
+// mkdirAll 建立 dir 及缺少的上層目錄，並把新建的目錄設為指定權限。
+func (p permissions) mkdirAll(dir string) error {
+	if dir == "" || dir == "." {
+		return nil
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := p.mkdirAll(filepath.Dir(dir)); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, 0o777); err != nil && !os.IsExist(err) {
+		return err
+	}
+	if p.setDir {
+		return os.Chmod(dir, p.dir)
+	}
+	return nil
+}
+
+// preserveMeta 把遠端檔案的修改時間與權限套用到下載完成的 local；--chmod 指定了檔案權限時以 --chmod 為準。
+// 伺服器沒有提供的部分維持不變。檔案已經下載完成，套用失敗只記錄警告。
+func (p permissions) preserveMeta(local string, m client.FileMeta) {
+	if m.Mode != 0 && !p.setFile {
+		if err := os.Chmod(local, m.Mode); err != nil {
+			slog.Warn("無法設定權限", "file", local, "err", err)
+		}
+	}
+	if !m.Mtime.IsZero() {
+		if err := os.Chtimes(local, m.Mtime, m.Mtime); err != nil {
+			slog.Warn("無法設定修改時間", "file", local, "err", err)
+		}
+	}
+}
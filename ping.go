@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+type pingResult struct {
+	Server    string          `json:"server"`
+	Handshake time.Duration   `json:"handshake"`
+	Version   string          `json:"quic_version"`
+	ALPN      string          `json:"alpn"`
+	Used0RTT  bool            `json:"used_0rtt"`
+	RTT       []time.Duration `json:"rtt"`
+}
+
+// runPing 量測交握時間與應用層 RTT：每個樣本開一個新 stream 送出 `ping`，等待伺服器回一行。
+func runPing(ctx context.Context, server string, args []string) error {
+	flags := flag.NewFlagSet("ping", flag.ExitOnError)
+	count := flags.Int("n", 5, "RTT 樣本數")
+	interval := flags.Duration("i", 200*time.Millisecond, "樣本間隔")
+	flags.Parse(args)
+
+	start := time.Now()
+	session, err := dial(ctx, server, nil)
+	if err != nil {
+		return err
+	}
+	defer session.CloseWithError(0, "")
+	state := session.ConnectionState()
+	res := pingResult{
+		Server:    server,
+		Handshake: time.Since(start),
+		Version:   state.Version.String(),
+		ALPN:      state.TLS.NegotiatedProtocol,
+		Used0RTT:  state.Used0RTT,
+	}
+	con.Printf("已連線到 %s (%s): 交握 %v, QUIC %s, ALPN %s\n", server, session.RemoteAddr(), res.Handshake.Round(time.Microsecond), res.Version, res.ALPN)
+
+	for i := 0; i < *count; i++ {
+		if i > 0 {
+			time.Sleep(*interval)
+		}
+		rtt, err := pingOnce(ctx, session)
+		if err != nil {
+			return fmt.Errorf("ping 失敗: %w", err)
+		}
+		res.RTT = append(res.RTT, rtt)
+		con.Printf("seq=%d rtt=%v\n", i+1, rtt.Round(time.Microsecond))
+	}
+	if len(res.RTT) > 0 {
+		lo, hi, sum := res.RTT[0], res.RTT[0], time.Duration(0)
+		for _, r := range res.RTT {
+			lo, hi, sum = min(lo, r), max(hi, r), sum+r
+		}
+		con.Printf("rtt min/avg/max = %v/%v/%v\n", lo.Round(time.Microsecond), (sum / time.Duration(len(res.RTT))).Round(time.Microsecond), hi.Round(time.Microsecond))
+	}
+	con.Result(res)
+	return nil
+}
+
+// pingOnce 在新的 stream 上送出 `ping` 並量測收到回應所需的時間。
+func pingOnce(ctx context.Context, session *quic.Conn) (time.Duration, error) {
+	start := time.Now()
+	stream, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer stream.Close()
+	if _, err := fmt.Fprintln(stream, "ping"); err != nil {
+		return 0, err
+	}
+	if _, err := client.ReadHeaderLine(bufio.NewReader(stream)); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
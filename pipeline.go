@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// 管線模式：在同一個 stream 上先送出 `pipeline`，之後每行一個 "<tag> <command>"，
+// 不必等待前一個回應。伺服器依請求順序回應，每個回應是
+//
+//	<tag> <OK|ERR> <length>\n<length 個位元組的內容>
+//
+// ERR 的內容是錯誤訊息。高延遲連線上執行腳本時可省下每個指令一次的往返時間。
+
+// pipelineResult 是管線中一個指令的結果（--json 時輸出）。
+type pipelineResult struct {
+	Tag     int    `json:"tag"`
+	Command string `json:"command"`
+	OK      bool   `json:"ok"`
+	Body    string `json:"body,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// maxPipelineJSONBody 是 --json 時放進結果的內容上限；一般模式下內容直接寫到 stdout，不受此限。
+const maxPipelineJSONBody = 1 << 20
+
+// runPipeline 從 in 讀取指令（每行一個），一次全部送出，再依序輸出回應。
+// 有任何指令失敗時回傳錯誤，但仍會處理完所有回應。
+func runPipeline(ctx context.Context, session *quic.Conn, in io.Reader) error {
+	var cmds []string
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			cmds = append(cmds, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	stream, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		return err
+	}
+	// 請求在另一個 goroutine 送出，避免回應塞滿流量控制視窗時雙方互相等待
+	sendErr := make(chan error, 1)
+	go func() {
+		w := bufio.NewWriter(stream)
+		fmt.Fprintln(w, "pipeline")
+		for i, c := range cmds {
+			fmt.Fprintf(w, "%d %s\n", i+1, c)
+		}
+		err := w.Flush()
+		stream.Close()
+		sendErr <- err
+	}()
+
+	r := bufio.NewReader(stream)
+	failed := 0
+	for i, c := range cmds {
+		res, err := readPipelineResponse(r, i+1, c)
+		if err != nil {
+			stream.CancelRead(0)
+			return err
+		}
+		if !res.OK {
+			failed++
+			con.Printf("%s: %s\n", c, res.Error)
+		}
+		con.Result(res)
+	}
+	if err := <-sendErr; err != nil {
+		return fmt.Errorf("送出指令失敗: %w", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 個指令中有 %d 個失敗", len(cmds), failed)
+	}
+	return nil
+}
+
+// readPipelineResponse 讀取一個回應；一般模式下成功的內容直接寫到 stdout。
+func readPipelineResponse(r *bufio.Reader, tag int, cmd string) (pipelineResult, error) {
+	header, err := client.ReadHeaderLine(r)
+	if err != nil {
+		return pipelineResult{}, fmt.Errorf("無法讀取第 %d 個回應: %v", tag, err)
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return pipelineResult{}, fmt.Errorf("無效的管線回應 %q", client.Snippet(header))
+	}
+	gotTag, err := strconv.Atoi(fields[0])
+	if err != nil || gotTag != tag {
+		return pipelineResult{}, fmt.Errorf("管線回應順序錯誤: 預期 %d，收到 %q", tag, fields[0])
+	}
+	length, err := client.ParseSize(fields[2])
+	if err != nil {
+		return pipelineResult{}, err
+	}
+	res := pipelineResult{Tag: tag, Command: cmd, OK: fields[1] == "OK"}
+
+	body := io.LimitReader(r, length)
+	if res.OK && !con.json && con.format == nil {
+		_, err = io.Copy(os.Stdout, body)
+	} else {
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, io.LimitReader(body, maxPipelineJSONBody))
+		if err == nil {
+			_, err = io.Copy(io.Discard, body)
+		}
+		if res.OK {
+			res.Body = buf.String()
+		} else {
+			res.Error = strings.TrimSpace(buf.String())
+		}
+	}
+	if err != nil {
+		return res, fmt.Errorf("無法讀取第 %d 個回應: %v", tag, err)
+	}
+	return res, nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	_ "net/http/pprof"
+)
+
+// startPprof 在 addr 上提供 net/http/pprof，用於長時間傳輸時擷取 CPU/記憶體 profile。
+func startPprof(addr string) {
+	go func() {
+		if err := http.ListenAndServe(addr, nil); err != nil {
+			slog.Error("pprof 伺服器停止", "err", err)
+		}
+	}()
+}
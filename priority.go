@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+type priority int
+
+const (
+	priorityLow priority = iota
+	priorityNormal
+	priorityHigh
+)
+
+func parsePriority(s string) (priority, error) {
+	switch s {
+	case "low":
+		return priorityLow, nil
+	case "normal", "":
+		return priorityNormal, nil
+	case "high":
+		return priorityHigh, nil
+	}
+	return priorityNormal, fmt.Errorf("無效的優先權 %q，可用值: high|normal|low", s)
+}
+
+func (p priority) String() string {
+	return [...]string{"low", "normal", "high"}[p]
+}
+
+// priorityGate 讓低優先權的 stream 在有較高優先權的 stream 進行中時暫停讀取。
+// 未被讀取的資料會填滿該 stream 的流量控制視窗，伺服器因此把連線頻寬讓給高優先權的 stream。
+type priorityGate struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	active [priorityHigh + 1]int
+}
+
+func newPriorityGate() *priorityGate {
+	g := &priorityGate{}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// streamPriorities 是同一個行程內所有 stream 共用的優先權排程。
+var streamPriorities = newPriorityGate()
+
+func (g *priorityGate) enter(p priority) {
+	g.mu.Lock()
+	g.active[p]++
+	g.mu.Unlock()
+}
+
+func (g *priorityGate) leave(p priority) {
+	g.mu.Lock()
+	g.active[p]--
+	g.mu.Unlock()
+	g.cond.Broadcast()
+}
+
+func (g *priorityGate) wait(p priority) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for g.higherActive(p) {
+		g.cond.Wait()
+	}
+}
+
+func (g *priorityGate) higherActive(p priority) bool {
+	for q := p + 1; q <= priorityHigh; q++ {
+		if g.active[q] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type prioritizedReader struct {
+	r      io.Reader
+	p      priority
+	gate   *priorityGate
+	closed atomic.Bool
+}
+
+// NewPrioritizedReader 在 gate 中登記一個以 p 優先權讀取的 stream。呼叫端必須在傳輸結束時呼叫 Close 登出：
+// 讀取可能在讀到 EOF 之前就停止（內容以 io.LimitReader 限制、區段被其他連線接手、寫入失敗），
+// 只在讀到錯誤時登出會讓較低優先權的 stream 永遠等下去。
+func NewPrioritizedReader(r io.Reader, p priority, gate *priorityGate) *prioritizedReader {
+	gate.enter(p)
+	return &prioritizedReader{r: r, p: p, gate: gate}
+}
+
+func (pr *prioritizedReader) Read(b []byte) (int, error) {
+	if !pr.closed.Load() {
+		pr.gate.wait(pr.p)
+	}
+	return pr.r.Read(b)
+}
+
+// Close 從 gate 登出，可以重複呼叫；不關閉底層的 reader。
+func (pr *prioritizedReader) Close() error {
+	if pr.closed.CompareAndSwap(false, true) {
+		pr.gate.leave(pr.p)
+	}
+	return nil
+}
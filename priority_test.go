@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrioritizedReaderClose(t *testing.T) {
+	g := newPriorityGate()
+	high := NewPrioritizedReader(strings.NewReader("high"), priorityHigh, g)
+	other := NewPrioritizedReader(strings.NewReader("high"), priorityHigh, g)
+	defer other.Close()
+	// 重複 Close 只登出一次：另一個高優先權的 stream 仍在進行，一般優先權要繼續等
+	high.Close()
+	high.Close()
+	if !g.higherActive(priorityNormal) {
+		t.Fatal("closing one high-priority reader twice released the other")
+	}
+
+	normal := NewPrioritizedReader(strings.NewReader("normal"), priorityNormal, g)
+	defer normal.Close()
+	done := make(chan struct{})
+	go func() {
+		normal.Read(make([]byte, 1))
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("normal-priority read did not wait for the high-priority stream")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// 沒讀到 EOF 也能以 Close 登出
+	other.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("normal-priority read still blocked after Close")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkPrivateDir 確認 dir 是目前的使用者擁有、權限為 0700 的目錄，而不是符號連結或別人建立的目錄。
+func checkPrivateDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || !ok || int(st.Uid) != os.Getuid() || info.Mode().Perm() != 0o700 {
+		return fmt.Errorf("%s 不是目前的使用者擁有、權限為 0700 的目錄，拒絕使用（可能被其他使用者搶先建立）", dir)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// checkPrivateDir 在沒有 unix 擁有者與權限位元的平台上只確認 dir 存在；這些平台的暫存目錄是每個使用者各自的。
+func checkPrivateDir(dir string) error {
+	_, err := os.Stat(dir)
+	return err
+}
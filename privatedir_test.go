@@ -0,0 +1,47 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPrivateDir(t *testing.T) {
+	root := t.TempDir()
+	private := filepath.Join(root, "private")
+	os.Mkdir(private, 0o700)
+	if err := checkPrivateDir(private); err != nil {
+		t.Errorf("0700 directory: %v", err)
+	}
+
+	shared := filepath.Join(root, "shared")
+	os.Mkdir(shared, 0o700)
+	os.Chmod(shared, 0o777)
+	link := filepath.Join(root, "link")
+	os.Symlink(private, link)
+	file := filepath.Join(root, "file")
+	os.WriteFile(file, nil, 0o700)
+	for _, dir := range []string{shared, link, file, filepath.Join(root, "missing")} {
+		if err := checkPrivateDir(dir); err == nil {
+			t.Errorf("checkPrivateDir(%s) accepted it", filepath.Base(dir))
+		}
+	}
+}
+
+func TestControlDirRejectsShared(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	// 其他使用者搶先建立了所有人都可寫入的控制目錄
+	os.Mkdir(controlDir(), 0o700)
+	os.Chmod(controlDir(), 0o777)
+	if _, err := ensureControlDir(); err == nil {
+		t.Error("ensureControlDir accepted a world-writable directory")
+	}
+	if _, err := controlSockets(); err == nil {
+		t.Error("controlSockets listed a world-writable directory")
+	}
+	if err := runCtl([]string{"status"}); err == nil {
+		t.Error("ctl connected through a world-writable directory")
+	}
+}
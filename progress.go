@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// progressInterval 是進度列的更新間隔；JSON 進度事件仍每秒一次。
+const progressInterval = 250 * time.Millisecond
+
+// rateSmoothing 是速度指數移動平均的權重，越小越平滑。
+const rateSmoothing = 0.3
+
+// ProgressReader 計算讀取量並在終端機上顯示進度列：長條、已傳輸/總大小、平滑後的速度與 ETA。
+// StartMonitor 之後必須呼叫 Stop，完成、失敗或中斷時才會正確收尾。
+type ProgressReader struct {
+	r         io.Reader
+	suffix    func() string // 不為 nil 時附加在進度列後（--verbose）
+	wire      func() int64  // 壓縮傳輸時回傳線上（壓縮後）的位元組數
+	name      string        // 進度列與進度事件中的檔名
+	totalSize int64
+	readBytes atomic.Int64 // Read 與監看 goroutine 同時存取
+	lastBytes int64
+
+	rate     float64   // 平滑後的速度 (bytes/sec)
+	lastLine time.Time // progressPlain 模式上次印出進度的時間
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func NewProgressReader(r io.Reader, totalSize int64) *ProgressReader {
+	return &ProgressReader{r: r, totalSize: totalSize}
+}
+
+func (pr *ProgressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.readBytes.Add(int64(n))
+	return n, err
+}
+
+// Wrap 回傳把讀取量計入 pr 的 reader，讓多個 stream 共用同一條進度列。
+func (pr *ProgressReader) Wrap(r io.Reader) io.Reader {
+	return &progressCounter{r: r, pr: pr}
+}
+
+type progressCounter struct {
+	r  io.Reader
+	pr *ProgressReader
+}
+
+func (pc *progressCounter) Read(p []byte) (int, error) {
+	n, err := pc.r.Read(p)
+	pc.pr.readBytes.Add(int64(n))
+	return n, err
+}
+
+func (pr *ProgressReader) StartMonitor() {
+	pr.stop = make(chan struct{})
+	pr.stopped = make(chan struct{})
+	pr.lastBytes = pr.readBytes.Load()
+	go func() {
+		defer close(pr.stopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		last, lastEvent := time.Now(), time.Now()
+		for {
+			select {
+			case <-pr.stop:
+				pr.finish()
+				return
+			case now := <-ticker.C:
+				pr.update(now.Sub(last))
+				last = now
+				if now.Sub(lastEvent) >= time.Second {
+					lastEvent = now
+					con.Event(transferEvent{Event: "progress", File: pr.name, Bytes: pr.readBytes.Load(), Total: pr.totalSize, Rate: pr.rate})
+				}
+				pr.render(false)
+			}
+		}
+	}()
+}
+
+// Stop 停止更新並收尾：已讀完時印出完成的進度列，否則保留目前的進度列並換行。
+// 可重複呼叫，pr 為 nil 或未啟動時不做事。
+func (pr *ProgressReader) Stop() {
+	if pr == nil || pr.stop == nil {
+		return
+	}
+	pr.stopOnce.Do(func() { close(pr.stop) })
+	<-pr.stopped
+}
+
+// update 以這段時間的讀取量更新平滑後的速度。
+func (pr *ProgressReader) update(elapsed time.Duration) {
+	n := pr.readBytes.Load()
+	if elapsed <= 0 {
+		return
+	}
+	speed := float64(n-pr.lastBytes) / elapsed.Seconds()
+	pr.lastBytes = n
+	if pr.rate == 0 {
+		pr.rate = speed
+	} else {
+		pr.rate = rateSmoothing*speed + (1-rateSmoothing)*pr.rate
+	}
+}
+
+func (pr *ProgressReader) finish() {
+	pr.lastLine = time.Time{} // progressPlain 模式也印出最後的進度
+	if pr.totalSize > 0 && pr.readBytes.Load() >= pr.totalSize {
+		pr.render(true)
+	} else {
+		pr.render(false)
+	}
+	con.endBar()
+}
+
+// render 在 progressBar 模式下以 \r 覆寫目前這一行；progressPlain 模式下每 plainProgressInterval
+// 才印一行，完成時也印一行，不使用控制序列。
+func (pr *ProgressReader) render(done bool) {
+	switch con.progress {
+	case progressNone:
+		return
+	case progressPlain:
+		now := time.Now()
+		if !done && now.Sub(pr.lastLine) < plainProgressInterval {
+			return
+		}
+		pr.lastLine = now
+	}
+	n := pr.readBytes.Load()
+	var extra string
+	if pr.suffix != nil {
+		extra = pr.suffix()
+	}
+	var b strings.Builder
+	if pr.name != "" {
+		b.WriteString(truncateLeft(pr.name, 24) + " ")
+	}
+	if pr.totalSize > 0 {
+		frac := min(float64(n)/float64(pr.totalSize), 1)
+		width := progressBarWidth()
+		filled := int(frac * float64(width))
+		b.WriteString("[" + strings.Repeat("=", filled) + strings.Repeat(" ", width-filled) + "]")
+		fmt.Fprintf(&b, " %5.1f%% %s/%s", frac*100, humanSize(n), humanSize(pr.totalSize))
+	} else {
+		b.WriteString(humanSize(n))
+	}
+	switch {
+	case done:
+		b.WriteString(" 完成")
+	case pr.rate > 0:
+		fmt.Fprintf(&b, " %s/s", humanSize(int64(pr.rate)))
+		if pr.totalSize > 0 {
+			b.WriteString(" ETA " + formatDuration(eta(pr.totalSize-n, pr.rate)))
+		}
+	}
+	if pr.wire != nil {
+		if w := pr.wire(); n > 0 {
+			fmt.Fprintf(&b, " (線上 %s，%.0f%%)", humanSize(w), float64(w)/float64(n)*100)
+		}
+	}
+	b.WriteString(extra)
+	if con.progress == progressPlain {
+		fmt.Fprintln(os.Stderr, b.String())
+		return
+	}
+	con.bar(b.String())
+}
+
+// progressBarWidth 依終端機寬度決定長條的寬度。
+func progressBarWidth() int {
+	cols, _, err := term.GetSize(int(os.Stderr.Fd()))
+	if err != nil || cols <= 0 {
+		return 20
+	}
+	// 保留約 60 欄給檔名、百分比、大小、速度與 ETA
+	return min(max(cols-60, 10), 40)
+}
+
+// truncateLeft 只保留 s 的最後 n 個字元，檔名較長時保留結尾。
+func truncateLeft(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return "…" + string(r[len(r)-n+1:])
+}
+
+// eta 回傳以 rate bytes/sec 傳完 remaining 個位元組所需的時間。
+func eta(remaining int64, rate float64) time.Duration {
+	return clampDuration(float64(remaining) / rate)
+}
+
+// formatDuration 把 ETA 格式化為 m:ss 或 h:mm:ss。
+func formatDuration(d time.Duration) string {
+	s := int64(d.Round(time.Second).Seconds())
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// progressMode 決定進度的顯示方式。進度一律寫到 stderr，stdout 只有結果、JSON 或 -o - 的資料。
+type progressMode int
+
+const (
+	progressBar   progressMode = iota // 以 \r 覆寫同一行的進度列
+	progressPlain                     // 每隔一段時間印一行純文字，適合 CI 紀錄或導向檔案
+	progressNone
+)
+
+// plainProgressInterval 是 progressPlain 模式下印出進度的間隔。
+const plainProgressInterval = 10 * time.Second
+
+// parseProgressMode 解析 --progress。auto 時 stderr 是支援控制序列的終端機才顯示進度列，
+// 否則（導向檔案、CI、TERM=dumb、不支援 ANSI 的 Windows 主控台）改為定期的純文字進度。
+func parseProgressMode(s string) (progressMode, error) {
+	switch s {
+	case "auto":
+		if !term.IsTerminal(int(os.Stderr.Fd())) || os.Getenv("TERM") == "dumb" || !enableVT(os.Stderr) {
+			return progressPlain, nil
+		}
+		return progressBar, nil
+	case "bar":
+		return progressBar, nil
+	case "plain":
+		return progressPlain, nil
+	case "none":
+		return progressNone, nil
+	}
+	return 0, fmt.Errorf("無效的 --progress %q（可用 auto、bar、plain、none）", s)
+}
+
+// bar 以 line 覆寫 stderr 上目前的進度列。
+func (c *console) bar(line string) {
+	fmt.Fprintf(os.Stderr, "\r%s\033[K", line)
+	c.inline.Store(true)
+}
+
+// endBar 在進度列之後換行，讓接下來的輸出從新的一行開始。
+func (c *console) endBar() {
+	if c.inline.Swap(false) {
+		fmt.Fprintln(os.Stderr)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// histogram 是 Prometheus 的累積直方圖：counts[i] 為不超過 bounds[i] 的觀測數。
+type histogram struct {
+	bounds []float64
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+func newHistogram(bounds ...float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]int64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) write(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for i, b := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, b, h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %g\n%s_count %d\n", name, h.count, name, h.sum, name, h.count)
+}
+
+// transferMetrics 以 Prometheus 文字格式提供傳輸統計（--metrics 與 daemon 的 /metrics），
+// 給 daemon、watch 與 sync 這類長時間執行的模式接上既有的監控。
+// 檔案層級的數字來自傳輸事件；線路上的位元組數與速率每秒從各連線的 connMetrics 取樣。
+type transferMetrics struct {
+	mu       sync.Mutex
+	done     int64
+	failed   int64
+	bytes    int64 // 完成的傳輸的檔案大小總和
+	retries  int64
+	duration *histogram
+	size     *histogram
+	wire     [2]int64   // 收到、送出的 UDP 酬載位元組數
+	rate     [2]float64 // 最近一秒收到、送出的速率（bytes/s）
+	last     map[*connMetrics][2]int64
+	sampler  sync.Once
+}
+
+var promMetrics = &transferMetrics{
+	duration: newHistogram(0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600),
+	size:     newHistogram(1<<10, 64<<10, 1<<20, 16<<20, 256<<20, 1<<30, 4<<30, 16<<30),
+	last:     make(map[*connMetrics][2]int64),
+}
+
+// observe 記錄一個傳輸事件，只計算 done 與 error。
+func (m *transferMetrics) observe(e transferEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch e.Event {
+	case "done":
+		m.done++
+		m.bytes += e.Bytes
+		m.duration.observe(e.Time.Seconds())
+		m.size.observe(float64(e.Bytes))
+	case "error":
+		m.failed++
+	}
+}
+
+// retried 記錄一次斷線後的重新連線。
+func (m *transferMetrics) retried() {
+	m.mu.Lock()
+	m.retries++
+	m.mu.Unlock()
+}
+
+// sample 累加各連線自上次取樣後收送的位元組數，並以此計算目前的速率。
+// 已關閉的連線會從 connMetricsByConn 移除，因此自行保存每條連線上次的數字，避免總數倒退。
+func (m *transferMetrics) sample(elapsed time.Duration) {
+	seen := make(map[*connMetrics][2]int64)
+	connMetricsByConn.Range(func(_, v any) bool {
+		c := v.(*connMetrics)
+		seen[c] = [2]int64{c.bytesReceived.Load(), c.bytesSent.Load()}
+		return true
+	})
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var delta [2]int64
+	for c, now := range seen {
+		prev := m.last[c]
+		delta[0] += now[0] - prev[0]
+		delta[1] += now[1] - prev[1]
+	}
+	for i := range delta {
+		m.wire[i] += delta[i]
+		m.rate[i] = float64(delta[i]) / elapsed.Seconds()
+	}
+	m.last = seen
+}
+
+// start 開始每秒取樣，可重複呼叫。
+func (m *transferMetrics) start() {
+	m.sampler.Do(func() {
+		go func() {
+			const interval = time.Second
+			for range time.Tick(interval) {
+				m.sample(interval)
+			}
+		}()
+	})
+}
+
+func (m *transferMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sessions := 0
+	connMetricsByConn.Range(func(any, any) bool {
+		sessions++
+		return true
+	})
+	var b strings.Builder
+	m.mu.Lock()
+	counter := func(name, help string, values map[string]float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		writeSamples(&b, name, values)
+	}
+	gauge := func(name, help string, values map[string]float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		writeSamples(&b, name, values)
+	}
+	counter("quic_client_transfers_total", "結束的檔案傳輸數，依結果分類。",
+		map[string]float64{`result="done"`: float64(m.done), `result="error"`: float64(m.failed)})
+	counter("quic_client_transfer_bytes_total", "完成的傳輸的檔案位元組數。", map[string]float64{"": float64(m.bytes)})
+	counter("quic_client_retries_total", "斷線後重新連線的次數。", map[string]float64{"": float64(m.retries)})
+	counter("quic_client_wire_bytes_total", "所有 QUIC 連線收送的位元組數。",
+		map[string]float64{`direction="received"`: float64(m.wire[0]), `direction="sent"`: float64(m.wire[1])})
+	gauge("quic_client_throughput_bytes_per_second", "最近一秒所有 QUIC 連線的速率。",
+		map[string]float64{`direction="received"`: m.rate[0], `direction="sent"`: m.rate[1]})
+	gauge("quic_client_sessions_open", "目前開啟的 QUIC 連線數。", map[string]float64{"": float64(sessions)})
+	m.duration.write(&b, "quic_client_transfer_duration_seconds", "完成的檔案傳輸所花的時間。")
+	m.size.write(&b, "quic_client_transfer_size_bytes", "完成的檔案傳輸的大小。")
+	m.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	io.WriteString(w, b.String())
+}
+
+// writeSamples 依標籤排序寫出一個指標的各個值；標籤為空字串表示沒有標籤。
+func writeSamples(w io.Writer, name string, values map[string]float64) {
+	labels := make([]string, 0, len(values))
+	for l := range values {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	for _, l := range labels {
+		if l == "" {
+			fmt.Fprintf(w, "%s %g\n", name, values[l])
+		} else {
+			fmt.Fprintf(w, "%s{%s} %g\n", name, l, values[l])
+		}
+	}
+}
+
+// startMetrics 實作 --metrics：在 addr 上提供 /metrics。先同步監聽，位址無法使用時直接回報錯誤。
+func startMetrics(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("無法在 %s 提供 metrics: %v", addr, err)
+	}
+	promMetrics.start()
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promMetrics)
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			slog.Error("metrics 伺服器停止", "err", err)
+		}
+	}()
+	return nil
+}
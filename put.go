@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// putMeta 回傳上傳時要伺服器套用的中繼資料，preserve 為 false 時不送。
+func putMeta(info os.FileInfo, preserve bool) client.FileMeta {
+	if !preserve {
+		return client.FileMeta{}
+	}
+	return client.FileMeta{Mtime: info.ModTime(), Mode: info.Mode().Perm()}
+}
+
+// sendFile 以 `put <remote> <size> [compress=<codec>:<level>] [mtime=<time>] [mode=<perm>]` 上傳本機檔案：標頭後緊接 size 個位元組的內容並關閉寫入端，
+// 伺服器寫入完成後回一行 OK，失敗時回 ERR。上傳與下載共用限速、進度顯示與控制 socket。
+func sendFile(ctx context.Context, session *quic.Conn, local, remote string, opts getOptions) (int64, error) {
+	f, err := os.Open(local)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return 0, fmt.Errorf("%s 不是一般檔案", local)
+	}
+	size := info.Size()
+	var src io.Reader = f
+	if len(opts.decrypt) > 0 {
+		// 上傳解密後的內容；大小可由加密檔的大小算出，不必先解密一次
+		if src, size, err = ageDecryptFile(f, size, opts.decrypt); err != nil {
+			return 0, fmt.Errorf("%s: %w", local, err)
+		}
+	}
+
+	compress := opts.compress.For(remote)
+	u, err := client.New(session).OpenUpload(ctx, remote, client.PutOptions{
+		Size:        size,
+		Compression: compress.codec,
+		Level:       compress.level,
+		Meta:        putMeta(info, opts.preserve),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	hasher := sha256.New()
+	reader := opts.stats.Track(int64(u.StreamID()), remote, io.TeeReader(io.LimitReader(src, size), hasher))
+	bucket := newTokenBucket()
+	opts.upload.Join(bucket, opts.weight)
+	defer opts.upload.Leave(bucket)
+	limited := bucket.Reader(reader)
+	ctl := newTransferControl(remote, size, limited, opts.upload)
+	stopKeys := func() {}
+	if !opts.noKeys {
+		stopKeys = watchKeys(ctl)
+	}
+	stopControl, err := serveControl(ctl, u.Cancel)
+	if err != nil {
+		slog.Warn("無法建立控制 socket", "err", err)
+		stopControl = func() {}
+	}
+	progressReader := NewProgressReader(ctl, size)
+	progressReader.name = remote
+	if m := metricsOf(session); opts.verbose && m != nil {
+		progressReader.suffix = m.progressSuffix
+	}
+	if compress.codec != "" {
+		progressReader.wire = u.WireBytes
+	}
+	progressReader.StartMonitor()
+
+	con.Event(transferEvent{Event: "start", File: remote, Total: size, Local: local})
+	start := time.Now()
+	n, err := io.Copy(u, progressReader)
+	progressReader.Stop()
+	stopKeys()
+	stopControl()
+	if err != nil {
+		u.Cancel()
+		return n, fmt.Errorf("上傳失敗: %w", err)
+	}
+	if n != size {
+		u.Cancel()
+		return n, fmt.Errorf("上傳期間 %s 大小改變", local)
+	}
+	if err := u.Close(); err != nil {
+		return n, err
+	}
+	if compress.codec != "" {
+		opts.stats.AddWire(u.WireBytes())
+	}
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	opts.stats.Finished(sum)
+	con.Event(transferEvent{Event: "done", File: remote, Bytes: n, Total: size, Local: local, SHA256: sum, Time: time.Since(start)})
+	return n, nil
+}
+
+// runPut 實作 `put <localfile> [remotename]`，遠端名稱預設為本機檔名。
+func runPut(ctx context.Context, session *quic.Conn, args []string, opts getOptions) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("用法: data_cli [--limit rate] <ip:port> put <localfile> [remotename]")
+	}
+	local := args[0]
+	remote := filepath.Base(local)
+	if len(opts.decrypt) > 0 {
+		remote = strings.TrimSuffix(remote, ".age")
+	}
+	if len(args) == 2 {
+		remote = args[1]
+	}
+	n, err := sendFile(ctx, session, local, remote, opts)
+	if err != nil {
+		con.Event(transferEvent{Event: "error", File: remote, Error: err.Error()})
+		return err
+	}
+	con.Printf("檔案上傳完成: %s -> %s (%s)\n", local, remote, humanSize(n))
+	return nil
+}
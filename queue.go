@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// transferQueue 是同一個行程內的傳輸工作佇列：最多 limit 個工作同時執行，
+// 高優先權的工作先開始，同優先權依加入的順序。
+type transferQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending [priorityHigh + 1][]queuedJob
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// newTransferQueue 建立佇列並啟動 limit 個 worker。
+func newTransferQueue(limit int) *transferQueue {
+	q := &transferQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	q.wg.Add(limit)
+	for range limit {
+		go q.worker()
+	}
+	return q
+}
+
+type queuedJob struct {
+	name     string
+	priority priority
+	run      func()
+}
+
+// Push 以優先權 p 加入工作；name 是排隊時在 `jobs` 中顯示的名稱。
+func (q *transferQueue) Push(p priority, name string, job func()) {
+	q.mu.Lock()
+	q.pending[p] = append(q.pending[p], queuedJob{name, p, job})
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// Close 表示不會再加入工作；worker 執行完佇列中剩下的工作後結束。
+func (q *transferQueue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// Wait 等待 Close 之後所有工作完成。
+func (q *transferQueue) Wait() {
+	q.wg.Wait()
+}
+
+func (q *transferQueue) next() func() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		for p := priorityHigh; p >= priorityLow; p-- {
+			if len(q.pending[p]) > 0 {
+				job := q.pending[p][0]
+				q.pending[p] = q.pending[p][1:]
+				return job.run
+			}
+		}
+		if q.closed {
+			return nil
+		}
+		q.cond.Wait()
+	}
+}
+
+// Pending 依開始的順序回傳尚未開始的工作。
+func (q *transferQueue) Pending() []queuedJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var jobs []queuedJob
+	for p := priorityHigh; p >= priorityLow; p-- {
+		jobs = append(jobs, q.pending[p]...)
+	}
+	return jobs
+}
+
+func (q *transferQueue) worker() {
+	defer q.wg.Done()
+	for job := q.next(); job != nil; job = q.next() {
+		job()
+	}
+}
+
+// priorityRules 是 --priority 的設定：high|normal|low 是預設的優先權，pattern=high 等規則
+// 設定符合的檔案的優先權（可重複，第一條符合的規則生效）。優先權決定佇列中的檔案何時開始傳輸，
+// 也決定同一條連線上的 stream 誰先讀取。
+type priorityRules struct {
+	def   priority
+	rules []struct {
+		re *regexp.Regexp
+		p  priority
+	}
+}
+
+func (r *priorityRules) Set(s string) error {
+	pattern, level, ok := strings.Cut(s, "=")
+	if !ok {
+		p, err := parsePriority(s)
+		r.def = p
+		return err
+	}
+	p, err := parsePriority(level)
+	if err != nil {
+		return err
+	}
+	re, err := regexp.Compile("(^|/)" + globToRegexp(pattern) + "$")
+	if err != nil {
+		return fmt.Errorf("無效的樣式 %q: %v", pattern, err)
+	}
+	r.rules = append(r.rules, struct {
+		re *regexp.Regexp
+		p  priority
+	}{re, p})
+	return nil
+}
+
+func (r *priorityRules) String() string {
+	if r == nil {
+		return "normal"
+	}
+	return r.def.String()
+}
+
+// For 回傳第一條符合 name 的規則的優先權，沒有規則符合時回傳 def。
+func (r priorityRules) For(name string, def priority) priority {
+	for _, rule := range r.rules {
+		if rule.re.MatchString(name) {
+			return rule.p
+		}
+	}
+	return def
+}
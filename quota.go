@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// quotaEntry 是伺服器回報的一筆用量：使用者本身或某個目錄的配額。
+type quotaEntry struct {
+	Scope string `json:"scope"` // user | dir
+	Name  string `json:"name"`
+	Used  int64  `json:"used"`
+	Limit int64  `json:"limit"` // 0 表示不限制
+	Files int64  `json:"files,omitempty"`
+}
+
+// Avail 回傳剩餘空間；不限制時回傳 -1。
+func (q quotaEntry) Avail() int64 {
+	if q.Limit <= 0 {
+		return -1
+	}
+	return max(q.Limit-q.Used, 0)
+}
+
+// fetchQuota 送出 `quota [path]`；伺服器每行回傳一個 JSON 物件，涵蓋目前使用者與 path 所在的各層目錄。
+func fetchQuota(ctx context.Context, session *quic.Conn, path string) ([]quotaEntry, error) {
+	stream, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	req := "quota"
+	if path != "" {
+		req += " " + client.New(session).EncodePath(path)
+	}
+	fmt.Fprintln(stream, req)
+
+	var entries []quotaEntry
+	r := bufio.NewReader(stream)
+	for {
+		line, err := client.ReadHeaderLine(r)
+		if err != nil {
+			if err == io.EOF {
+				return entries, nil
+			}
+			return nil, err
+		}
+		if err := client.CheckServerError(line); err != nil {
+			return nil, err
+		}
+		var q quotaEntry
+		if err := json.Unmarshal([]byte(line), &q); err != nil {
+			return nil, fmt.Errorf("無效的 quota 回應 %q", client.Snippet(line))
+		}
+		entries = append(entries, q)
+	}
+}
+
+// runQuota 列出用量與上限，讓使用者在大量上傳前確認剩餘空間。
+func runQuota(ctx context.Context, session *quic.Conn, args []string) error {
+	entries, err := fetchQuota(ctx, session, strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(con.Text(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SCOPE\tNAME\tUSED\tLIMIT\tAVAIL\tUSE%")
+	for _, q := range entries {
+		con.Result(q)
+		limit, avail, pct := "-", "-", "-"
+		if q.Limit > 0 {
+			limit, avail = humanSize(q.Limit), humanSize(q.Avail())
+			pct = fmt.Sprintf("%.0f%%", float64(q.Used)/float64(q.Limit)*100)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", q.Scope, q.Name, humanSize(q.Used), limit, avail, pct)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// minRangeSize 是 --streams 分段的最小長度，太小的檔案不值得多開 stream。
+const minRangeSize = 1 << 20
+
+// getRanges 把檔案分成數段，在同一連線的多個 stream 上平行下載並直接寫入輸出檔的對應位置。
+// 第一段沿用已讀過標頭的 first，其餘各段送出 `get <file> <offset> len=<n>`；
+// 不認得 len 的伺服器會送到檔尾，讀滿該段後即取消讀取。st 不為 nil 時依 .state 續傳。
+func getRanges(ctx context.Context, session *quic.Conn, filename, local string, first *client.Download, st *transferState, opts getOptions) error {
+	n := int(max(min(int64(opts.streams), first.Size/minRangeSize), 1))
+	conns := make([]*quic.Conn, n)
+	for i := range conns {
+		conns[i] = session
+	}
+	return getParallel(ctx, conns, 1, filename, local, first, st, opts)
+}
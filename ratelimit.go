@@ -0,0 +1,155 @@
+package main
+
+import (
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultPacingInterval 是未指定 --limit-interval 時令牌桶容量對應的時間。
+const defaultPacingInterval = 100 * time.Millisecond
+
+// limitCatchUp 是落後速度上限時最多可以補回的時間。網路停頓後只補回這麼多，
+// 不會因為長時間停頓而以全速連續傳送。
+const limitCatchUp = time.Second
+
+// tokenBucket 是限速器：以整段傳輸計算，從 origin 起已支付 paid 個位元組，
+// 依速度上限應在 origin + paid/rate 時才讀完，提早讀完時等待到該時間為止。
+// 與逐次補充令牌的做法不同，短讀取或緩衝造成的空檔可以在之後補回（最多 limitCatchUp），
+// 實際的平均速度貼近 rate。讀取後才支付，因此同一個桶可由多個 stream 同時使用，
+// 總速度仍不超過 rate。
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     int64         // bytes/sec，0 表示不限速
+	burst    int64         // 允許超前的量，0 表示 rate * interval
+	interval time.Duration // 0 表示 defaultPacingInterval
+	origin   time.Time
+	paid     float64      // 從 origin 起支付的位元組數
+	read     atomic.Int64 // 經由此桶讀取的位元組數
+	meter    *usageMeter  // 不為 nil 時記錄用量並套用 --quota，由 limiterGroup.Join 設定
+}
+
+func newTokenBucket() *tokenBucket {
+	return &tokenBucket{}
+}
+
+// size 回傳允許超前的量，也是單次讀取的上限。呼叫時須持有 mu。
+func (b *tokenBucket) size() int64 {
+	if b.burst > 0 {
+		return b.burst
+	}
+	interval := b.interval
+	if interval <= 0 {
+		interval = defaultPacingInterval
+	}
+	return max(clampInt64(float64(b.rate)*interval.Seconds()), 1)
+}
+
+// due 回傳依目前的速度上限讀完已支付的位元組的時間。呼叫時須持有 mu，且 rate > 0。
+func (b *tokenBucket) due() time.Time {
+	return b.origin.Add(clampDuration(b.paid / float64(b.rate)))
+}
+
+func (b *tokenBucket) Limit() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rate
+}
+
+// SetLimit 可在傳輸進行中調整速度上限，0 表示不限速。尚未等待完的量換算成新的速度繼續計算。
+func (b *tokenBucket) SetLimit(rate int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if rate == b.rate {
+		return
+	}
+	now := time.Now()
+	var owed float64
+	if b.rate > 0 && !b.origin.IsZero() {
+		// 以位元組計算，不經過 due()：欠下的量可能超出 time.Duration 的範圍
+		owed = max(b.paid-now.Sub(b.origin).Seconds()*float64(b.rate), 0)
+	}
+	b.rate = rate
+	b.origin, b.paid = now, owed
+}
+
+// SetBurst 設定允許超前的量（--limit-burst），或其對應的時間（--limit-interval）。
+func (b *tokenBucket) SetBurst(burst int64, interval time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.burst, b.interval = burst, interval
+}
+
+// take 支付 n 個位元組並回傳需要等待的時間。
+func (b *tokenBucket) take(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 {
+		return 0
+	}
+	now := time.Now()
+	if b.origin.IsZero() {
+		b.origin = now
+	}
+	if lag := now.Sub(b.due()); lag > limitCatchUp {
+		b.origin = b.origin.Add(lag - limitCatchUp)
+	}
+	b.paid += float64(n)
+	ahead := clampDuration(float64(b.size()) / float64(b.rate))
+	return max(b.due().Sub(now)-ahead, 0)
+}
+
+// Reader 回傳受此令牌桶限速的 reader。
+func (b *tokenBucket) Reader(r io.Reader) *rateLimitedReader {
+	return &rateLimitedReader{r: r, tokenBucket: b}
+}
+
+// rateLimitedReader 從 r 讀取並向共用的令牌桶支付讀到的位元組數。
+type rateLimitedReader struct {
+	r io.Reader
+	*tokenBucket
+}
+
+func (rl *rateLimitedReader) Read(p []byte) (int, error) {
+	if err := rl.meter.wait(); err != nil {
+		return 0, err
+	}
+	if rl.Limit() <= 0 {
+		n, err := rl.r.Read(p)
+		rl.read.Add(int64(n))
+		rl.meter.add(n)
+		return n, err
+	}
+	rl.mu.Lock()
+	size := rl.size()
+	rl.mu.Unlock()
+	if int64(len(p)) > size {
+		p = p[:size]
+	}
+	n, err := rl.r.Read(p)
+	rl.read.Add(int64(n))
+	rl.meter.add(n)
+	if wait := rl.take(n); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
+
+// clampDuration 把秒數換算成 time.Duration。數 TB 的量在很低的速度上限下會超出 time.Duration 的範圍，
+// 此時取最大值，而不是溢位成負值變成不限速。
+func clampDuration(seconds float64) time.Duration {
+	if seconds >= math.MaxInt64/float64(time.Second) {
+		return math.MaxInt64
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// clampInt64 把 float64 轉成 int64，超出範圍時取最大值。
+func clampInt64(f float64) int64 {
+	if f >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(f)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+const tebibyte = 1 << 40
+
+func TestParseSizeLarge(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"5T", 5 * tebibyte},
+		{"5.5TiB", 5.5 * tebibyte},
+		{"8388607T", 8388607 * tebibyte},
+		{"9223372036854775000", 9223372036854774784}, // float64 的精度
+	}
+	for _, tt := range tests {
+		if got, err := parseSize(tt.in); err != nil || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"8388608T", "9223372036854775808", "1e30", "inf"} {
+		if got, err := parseSize(in); err == nil {
+			t.Errorf("parseSize(%q) = %d, want an error", in, got)
+		}
+	}
+	if got, err := parseRate("4T/s"); err != nil || got != 4*tebibyte {
+		t.Errorf("parseRate(4T/s) = %d, %v", got, err)
+	}
+}
+
+func TestHumanSizeLarge(t *testing.T) {
+	for n, want := range map[int64]string{
+		5 * tebibyte:    "5.0 TiB",
+		3000 * tebibyte: "2.9 PiB",
+		math.MaxInt64:   "8.0 EiB",
+	} {
+		if got := humanSize(n); got != want {
+			t.Errorf("humanSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestTokenBucketMultiTerabyte(t *testing.T) {
+	b := newTokenBucket()
+	b.SetLimit(tebibyte)
+	b.mu.Lock()
+	chunk := b.size()
+	b.mu.Unlock()
+	// 以 1 TiB/s 讀完 50 個 pacing 間隔的量（約 5 TiB）需要 5 秒，最後可以超前一個 pacing 間隔
+	var wait time.Duration
+	for range 50 {
+		wait = b.take(int(chunk))
+	}
+	want := 5*time.Second - defaultPacingInterval
+	if wait < want-time.Second || wait > want+time.Millisecond {
+		t.Errorf("wait after 5 TiB at 1 TiB/s = %v, want about %v", wait, want)
+	}
+}
+
+func TestTokenBucketOverflow(t *testing.T) {
+	// 極大的速度上限與間隔：單次讀取上限不能溢位成 1 或負值
+	b := newTokenBucket()
+	b.SetLimit(math.MaxInt64)
+	b.SetBurst(0, time.Hour)
+	b.mu.Lock()
+	size := b.size()
+	b.mu.Unlock()
+	if size < tebibyte {
+		t.Errorf("size() with rate MaxInt64 and a 1h interval = %d", size)
+	}
+
+	// 以 1 B/s 支付數 TB：等待時間是極大的正值，不能溢位成負值而不限速
+	b = newTokenBucket()
+	b.SetLimit(1)
+	if wait := b.take(4 * tebibyte); wait < 1000*time.Hour {
+		t.Errorf("wait after paying 4 TiB at 1 B/s = %v", wait)
+	}
+	// 調整速度上限時，欠下的量換算成新的速度繼續計算
+	b.SetLimit(tebibyte)
+	if wait := b.take(1); wait < time.Second || wait > 5*time.Second {
+		t.Errorf("wait after raising the limit to 1 TiB/s = %v, want about 4s", wait)
+	}
+}
+
+func TestLimiterGroupLarge(t *testing.T) {
+	g := &limiterGroup{total: math.MaxInt64 / 2, members: make(map[*tokenBucket]float64), start: time.Now()}
+	a, b := newTokenBucket(), newTokenBucket()
+	g.Join(a, 3)
+	g.Join(b, 1)
+	if ra, rb := a.Limit(), b.Limit(); ra != 3*rb || math.Abs(float64(ra+rb-g.Total())) > 1e6 {
+		t.Errorf("limits = %d + %d, want a 3:1 split of %d", ra, rb, g.Total())
+	}
+	// 調升不能溢位成 1 B/s
+	if got := g.Nudge(4); got != math.MaxInt64 {
+		t.Errorf("Nudge(4) near MaxInt64 = %d, want MaxInt64", got)
+	}
+	if a.Limit() < tebibyte {
+		t.Errorf("member limit after Nudge = %d", a.Limit())
+	}
+}
+
+func TestETAOverflow(t *testing.T) {
+	// 以 1 B/s 下載 4 TiB 的剩餘時間超出 time.Duration 的範圍
+	if d := eta(4*tebibyte, 1); d <= 0 {
+		t.Errorf("eta(4 TiB, 1 B/s) = %v", d)
+	}
+	if d := eta(5*tebibyte, tebibyte); d != 5*time.Second {
+		t.Errorf("eta(5 TiB, 1 TiB/s) = %v", d)
+	}
+}
+
+func TestWeightRulesAdd(t *testing.T) {
+	var rules weightRules
+	for _, s := range []string{"*.iso=NaN", "*.iso=nan", "*.iso=Inf", "*.iso=+Inf", "*.iso=-Inf", "*.iso=0", "*.iso=-1", "*.iso=x", "*.iso"} {
+		if err := rules.add(s); err == nil {
+			t.Errorf("add(%q) accepted an invalid weight", s)
+		}
+	}
+	if err := rules.add("*.iso=2.5"); err != nil {
+		t.Fatal(err)
+	}
+	if w := rules.For("dir/a.iso"); w != 2.5 {
+		t.Errorf("For(dir/a.iso) = %v, want 2.5", w)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// remoteReader 以 `get <file> <offset>` 隨機讀取遠端檔案。循序讀取會沿用同一個下載，
+// 跳躍讀取時才以新的 offset 重新送出 get。不可同時使用。
+type remoteReader struct {
+	session *quic.Conn
+	path    string
+	size    int64
+
+	d   *client.Download
+	pos int64
+}
+
+func (rr *remoteReader) ReadAt(ctx context.Context, p []byte, off int64) (int, error) {
+	if off >= rr.size {
+		return 0, io.EOF
+	}
+	if rr.d == nil || off != rr.pos {
+		rr.Close()
+		if err := rr.seek(ctx, off); err != nil {
+			return 0, err
+		}
+	}
+	n, err := io.ReadFull(rr.d, p)
+	rr.pos += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if err != nil && err != io.EOF {
+		rr.Close()
+	}
+	return n, err
+}
+
+// seek 開一個新的 stream 從 off 開始下載檔案。
+func (rr *remoteReader) seek(ctx context.Context, off int64) error {
+	d, err := client.New(rr.session).Open(ctx, rr.path, client.GetOptions{Offset: off})
+	if err != nil {
+		return err
+	}
+	rr.d, rr.pos = d, off
+	return nil
+}
+
+func (rr *remoteReader) Close() error {
+	if rr.d != nil {
+		rr.d.Close()
+		rr.d = nil
+	}
+	return nil
+}
+
+// statRemote 列出上層目錄以取得 p 的資訊；空字串或 "." 表示遠端根目錄。
+func statRemote(ctx context.Context, session *quic.Conn, p string) (client.Entry, error) {
+	p = strings.TrimPrefix(path.Clean(p), "/")
+	if p == "." || p == "" {
+		return client.Entry{Name: "/", Type: "dir"}, nil
+	}
+	dir, name := path.Split(p)
+	var found *client.Entry
+	err := client.New(session).ListFunc(ctx, strings.TrimSuffix(dir, "/"), true, func(e client.Entry) error {
+		if e.Name == name {
+			found = &e
+		}
+		return nil
+	})
+	if err != nil {
+		return client.Entry{}, err
+	}
+	if found == nil {
+		return client.Entry{}, os.ErrNotExist
+	}
+	return *found, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+type repairResult struct {
+	Remote   string `json:"remote"`
+	Local    string `json:"local"`
+	Size     int64  `json:"size"`
+	Blocks   int    `json:"blocks"`
+	Repaired int    `json:"repaired"`
+	Fetched  int64  `json:"fetched"`
+}
+
+// fetchBlockHashes 送出 `blocks <file> <blocksize>`；伺服器先回 "<size> <blocksize>"，
+// 再依序每行回傳一個區塊的 SHA-256。
+func fetchBlockHashes(ctx context.Context, session *quic.Conn, remote string, blockSize int64) (size int64, hashes []string, err error) {
+	stream, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer stream.Close()
+	fmt.Fprintf(stream, "blocks %s %d\n", client.New(session).EncodePath(remote), blockSize)
+
+	r := bufio.NewReader(stream)
+	header, err := client.ReadHeaderLine(r)
+	if err != nil {
+		return 0, nil, fmt.Errorf("無法讀取區塊清單: %w", err)
+	}
+	if err := client.CheckServerError(header); err != nil {
+		return 0, nil, err
+	}
+	sizeField, bsField, _ := strings.Cut(header, " ")
+	size, err = client.ParseSize(sizeField)
+	if err != nil {
+		return 0, nil, err
+	}
+	if bs, err := client.ParseSize(bsField); err != nil || bs != blockSize {
+		return 0, nil, fmt.Errorf("伺服器使用不同的區塊大小 %q", bsField)
+	}
+	want := int((size + blockSize - 1) / blockSize)
+	for len(hashes) < want {
+		line, err := client.ReadHeaderLine(r)
+		if err != nil {
+			return 0, nil, fmt.Errorf("區塊清單不完整: %v", err)
+		}
+		hashes = append(hashes, line)
+	}
+	return size, hashes, nil
+}
+
+// runRepair 實作 `repair [-bs 1M] <remote> [local]`：逐區塊比對本機檔案與伺服器的雜湊，
+// 只重新下載損毀或缺少的區塊，最後以整個檔案的 SHA-256 確認修復結果。
+func runRepair(ctx context.Context, session *quic.Conn, args []string) error {
+	flags := flag.NewFlagSet("repair", flag.ExitOnError)
+	bs := flags.String("bs", "1M", "比對的區塊大小")
+	flags.Parse(args)
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return errors.New("用法: data_cli <ip:port> repair [-bs 1M] <remote> [local]")
+	}
+	blockSize, err := parseSize(*bs)
+	if err != nil {
+		return err
+	}
+	if blockSize <= 0 {
+		return errors.New("-bs 必須大於 0")
+	}
+	remote := flags.Arg(0)
+	local := flags.Arg(1)
+	if local == "" {
+		if local, err = localPath(remote, false); err != nil {
+			return err
+		}
+	}
+
+	size, hashes, err := fetchBlockHashes(ctx, session, remote, blockSize)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(local, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := f.Truncate(size); err != nil {
+		return err
+	}
+
+	res := repairResult{Remote: remote, Local: local, Size: size, Blocks: len(hashes)}
+	rr := remoteReader{session: session, path: remote, size: size}
+	defer rr.Close()
+	buf := make([]byte, blockSize)
+	for i, want := range hashes {
+		off := int64(i) * blockSize
+		block := buf[:min(blockSize, size-off)]
+		n, err := f.ReadAt(block, off)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		sum := sha256.Sum256(block[:n])
+		if n == len(block) && hex.EncodeToString(sum[:]) == want {
+			continue
+		}
+		if _, err := rr.ReadAt(ctx, block, off); err != nil && err != io.EOF {
+			return fmt.Errorf("無法下載區塊 %d: %v", i, err)
+		}
+		if sum := sha256.Sum256(block); hex.EncodeToString(sum[:]) != want {
+			return fmt.Errorf("區塊 %d 下載後仍不符，遠端檔案可能正在變更", i)
+		}
+		if _, err := f.WriteAt(block, off); err != nil {
+			return err
+		}
+		res.Repaired++
+		res.Fetched += int64(len(block))
+		if con.progress == progressBar {
+			con.bar(fmt.Sprintf("已修復 %d 個區塊 (%s)", res.Repaired, humanSize(res.Fetched)))
+		}
+	}
+	con.endBar()
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	c := client.New(session)
+	want, err := c.Request(ctx, "hash "+c.EncodePath(remote))
+	if err != nil {
+		return fmt.Errorf("無法取得遠端雜湊: %w", err)
+	}
+	got, err := fileSHA256(local)
+	if err != nil {
+		return err
+	}
+	if got != strings.TrimSpace(want) {
+		return fmt.Errorf("%s 修復後的 SHA-256 仍與伺服器不符", local)
+	}
+	con.Printf("%s: %d 個區塊中修復 %d 個，下載 %s / %s\n", local, res.Blocks, res.Repaired, humanSize(res.Fetched), humanSize(size))
+	con.Result(res)
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// maxRetryBackoff 是兩次重試之間的最長等待時間。
+const maxRetryBackoff = 30 * time.Second
+
+// retryOptions 是 --retries 與 --retry-backoff。
+type retryOptions struct {
+	retries int
+	backoff time.Duration // 第一次重試前的等待時間，之後每次加倍
+}
+
+var clientRetry = retryOptions{backoff: time.Second}
+
+// do 執行 fn；若失敗時連線已經中斷，重新連線後再執行，最多重試 retries 次。
+// 連線仍正常時的失敗（伺服器回報錯誤、校驗失敗等）重試也不會成功，直接回傳。
+// 因網路改變而無法遷移的連線（見 watchPath）一律立即重新連線，不計入次數。
+// fn 的 retry 參數在重試時為 true，讓傳輸從已寫入的位置續傳。
+func (r retryOptions) do(ctx context.Context, server string, conf *quic.Config, session *quic.Conn, fn func(session *quic.Conn, retry bool) error) error {
+	err := fn(session, false)
+	wait := r.backoff
+	for attempt := 1; err != nil; attempt++ {
+		if ctx.Err() != nil || session.Context().Err() == nil {
+			return err
+		}
+		if _, changed := networkChanged.LoadAndDelete(session); changed {
+			slog.Info("網路改變，重新連線並續傳", "server", server)
+			attempt--
+		} else if attempt > r.retries {
+			return err
+		} else {
+			slog.Warn("連線中斷，稍後重新連線", "err", err, "wait", wait, "attempt", attempt, "retries", r.retries)
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(wait):
+			}
+			wait = min(wait*2, maxRetryBackoff)
+		}
+		promMetrics.retried()
+		s, derr := dial(ctx, server, conf)
+		if derr != nil {
+			err = derr
+			continue
+		}
+		session = s
+		err = fn(session, true)
+	}
+	return err
+}
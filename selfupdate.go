@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// 預設的發行版本查詢位置；updatePublicKey 為發行清單簽章的 ed25519 公鑰（base64），
+// 可在建置時以 -ldflags "-X main.updatePublicKey=..." 內嵌。
+var (
+	updateURL       = "https://api.github.com/repos/chuhan-cheng/quic-client/releases/latest"
+	updatePublicKey = ""
+)
+
+// maxBinarySize 是下載新版執行檔的大小上限。
+const maxBinarySize = 256 << 20
+
+type release struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// updateManifestAsset 是發行版本附帶的清單，updateManifestAsset+".sig" 是它的 ed25519 簽章（base64）。
+const updateManifestAsset = "data_cli_manifest.json"
+
+// updateManifest 把版本號、平台與執行檔的 SHA-256 綁在同一份簽章之下。只驗證執行檔本身的簽章不夠：
+// 舊版或其他平台的執行檔同樣有合法的簽章，可以被換進發行資訊，讓用戶端「更新」成已知有漏洞的版本。
+type updateManifest struct {
+	Version string `json:"version"`
+	Files   []struct {
+		OS     string `json:"os"`
+		Arch   string `json:"arch"`
+		Name   string `json:"name"`
+		SHA256 string `json:"sha256"`
+	} `json:"files"`
+}
+
+// errUpToDate 表示發行的版本與目前的版本相同。
+var errUpToDate = errors.New("已是最新版本")
+
+// target 回傳清單中 goos/goarch 的執行檔名稱與 SHA-256。清單的版本必須是有效的語意化版本、
+// 與發行標籤相同，而且嚴格新於 current；相同時回傳 errUpToDate。
+func (m *updateManifest) target(tag, current, goos, goarch string) (name string, sum []byte, err error) {
+	if !semver.IsValid(m.Version) {
+		return "", nil, fmt.Errorf("發行清單的版本 %q 無效", m.Version)
+	}
+	if m.Version != tag {
+		return "", nil, fmt.Errorf("發行清單的版本 %s 與發行標籤 %s 不符", m.Version, tag)
+	}
+	if !semver.IsValid(current) {
+		return "", nil, fmt.Errorf("目前的版本 %q 不是發行版本，無法確認 %s 較新", current, m.Version)
+	}
+	switch c := semver.Compare(m.Version, current); {
+	case c == 0:
+		return "", nil, errUpToDate
+	case c < 0:
+		return "", nil, fmt.Errorf("拒絕從 %s 降級到 %s", current, m.Version)
+	}
+	for _, f := range m.Files {
+		if f.OS != goos || f.Arch != goarch {
+			continue
+		}
+		sum, err := hex.DecodeString(f.SHA256)
+		if err != nil || len(sum) != sha256.Size || f.Name == "" {
+			return "", nil, fmt.Errorf("發行清單中 %s/%s 的項目無效", goos, goarch)
+		}
+		return f.Name, sum, nil
+	}
+	return "", nil, fmt.Errorf("版本 %s 沒有 %s/%s 的執行檔", m.Version, goos, goarch)
+}
+
+// runSelfUpdate 查詢最新發行版本，以 fetchUpdate 下載並驗證符合本機平台的執行檔後，
+// 以同目錄的暫存檔原子地取代目前的執行檔。
+func runSelfUpdate(keyPath string) error {
+	pub, err := updateKey(keyPath)
+	if err != nil {
+		return err
+	}
+	to, bin, err := fetchUpdate(&http.Client{Timeout: 5 * time.Minute}, pub)
+	if err == errUpToDate {
+		con.Println("已是最新版本:", version)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := replaceExecutable(bin); err != nil {
+		return err
+	}
+	con.Printf("已更新 %s -> %s\n", version, to)
+	con.Result(struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}{version, to})
+	return nil
+}
+
+// fetchUpdate 下載發行清單與其簽章，驗證簽章、版本與平台後下載執行檔並比對 SHA-256，
+// 回傳新版本號與執行檔內容。
+func fetchUpdate(client *http.Client, pub ed25519.PublicKey) (string, []byte, error) {
+	var rel release
+	if err := fetchJSON(client, updateURL, &rel); err != nil {
+		return "", nil, fmt.Errorf("無法查詢最新版本: %v", err)
+	}
+	if rel.TagName == "" {
+		return "", nil, errors.New("發行資訊缺少版本號")
+	}
+	assets := make(map[string]string)
+	for _, a := range rel.Assets {
+		assets[a.Name] = a.URL
+	}
+	manifestURL, sigURL := assets[updateManifestAsset], assets[updateManifestAsset+".sig"]
+	if manifestURL == "" || sigURL == "" {
+		return "", nil, fmt.Errorf("版本 %s 沒有 %s 或其簽章", rel.TagName, updateManifestAsset)
+	}
+	data, err := fetch(client, manifestURL, 1<<20)
+	if err != nil {
+		return "", nil, err
+	}
+	sig, err := fetch(client, sigURL, 4096)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := verifySignature(pub, data, string(sig)); err != nil {
+		return "", nil, fmt.Errorf("%s: 發行清單簽章驗證失敗", rel.TagName)
+	}
+	var m updateManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return "", nil, fmt.Errorf("無效的發行清單: %v", err)
+	}
+	name, want, err := m.target(rel.TagName, version, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return "", nil, err
+	}
+	binURL := assets[name]
+	if binURL == "" {
+		return "", nil, fmt.Errorf("版本 %s 沒有 %s", m.Version, name)
+	}
+	bin, err := fetch(client, binURL, maxBinarySize)
+	if err != nil {
+		return "", nil, err
+	}
+	if got := sha256.Sum256(bin); !bytes.Equal(got[:], want) {
+		return "", nil, fmt.Errorf("%s 的 SHA-256 與發行清單不符", name)
+	}
+	return m.Version, bin, nil
+}
+
+func updateKey(keyPath string) (ed25519.PublicKey, error) {
+	if keyPath != "" {
+		return loadPublicKey(keyPath)
+	}
+	if updatePublicKey == "" {
+		return nil, errors.New("沒有可用的發行簽章公鑰，請以 --update-key 指定")
+	}
+	raw, err := base64.StdEncoding.DecodeString(updatePublicKey)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, errors.New("內嵌的發行簽章公鑰無效")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+func fetch(client *http.Client, url string, limit int64) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s: 檔案過大", url)
+	}
+	return data, nil
+}
+
+func fetchJSON(client *http.Client, url string, v any) error {
+	data, err := fetch(client, url, 1<<20)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// replaceExecutable 把新版寫到執行檔同目錄的暫存檔後 rename 取代，過程中不會留下半寫的執行檔。
+// Windows 無法覆寫執行中的檔案，因此先把舊檔改名為 .old。
+func replaceExecutable(bin []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+".new-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bin); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o755); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := strings.TrimSuffix(exe, ".exe") + ".old.exe"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), exe)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// releaseFixture 是一個假的發行版本：tag、清單內容與簽章、各資產的內容。
+type releaseFixture struct {
+	tag      string
+	manifest []byte
+	sig      string
+	assets   map[string][]byte
+}
+
+func newRelease(t *testing.T, priv ed25519.PrivateKey, tag string, bin []byte) *releaseFixture {
+	t.Helper()
+	name := "data_cli_" + runtime.GOOS + "_" + runtime.GOARCH
+	sum := sha256.Sum256(bin)
+	m := map[string]any{
+		"version": tag,
+		"files": []map[string]string{
+			{"os": "plan9", "arch": "386", "name": "data_cli_plan9_386", "sha256": strings.Repeat("00", 32)},
+			{"os": runtime.GOOS, "arch": runtime.GOARCH, "name": name, "sha256": hex.EncodeToString(sum[:])},
+		},
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &releaseFixture{tag: tag, assets: map[string][]byte{name: bin}}
+	r.sign(priv, data)
+	return r
+}
+
+func (r *releaseFixture) sign(priv ed25519.PrivateKey, manifest []byte) {
+	r.manifest = manifest
+	r.sig = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, manifest))
+}
+
+// serve 以 httptest 提供發行資訊與資產，並把 updateURL 指向它。
+func (r *releaseFixture) serve(t *testing.T) {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	files := map[string][]byte{
+		updateManifestAsset:          r.manifest,
+		updateManifestAsset + ".sig": []byte(r.sig),
+	}
+	for name, b := range r.assets {
+		files[name] = b
+	}
+	var rel release
+	rel.TagName = r.tag
+	for name, b := range files {
+		mux.HandleFunc("/download/"+name, func(w http.ResponseWriter, _ *http.Request) { w.Write(b) })
+		rel.Assets = append(rel.Assets, struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		}{name, srv.URL + "/download/" + name})
+	}
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, _ *http.Request) { json.NewEncoder(w).Encode(rel) })
+
+	oldURL, oldVersion := updateURL, version
+	updateURL, version = srv.URL+"/latest", "v1.2.0"
+	t.Cleanup(func() { updateURL, version = oldURL, oldVersion })
+}
+
+func TestFetchUpdate(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	bin := []byte("new binary")
+
+	tests := []struct {
+		name    string
+		release func(t *testing.T) *releaseFixture
+		want    string // 錯誤訊息的片段；空字串表示成功
+	}{
+		{"newer", func(t *testing.T) *releaseFixture { return newRelease(t, priv, "v1.3.0", bin) }, ""},
+		{"same version", func(t *testing.T) *releaseFixture { return newRelease(t, priv, "v1.2.0", bin) }, "已是最新版本"},
+		{"older", func(t *testing.T) *releaseFixture { return newRelease(t, priv, "v1.1.9", bin) }, "降級"},
+		{"prerelease of current", func(t *testing.T) *releaseFixture { return newRelease(t, priv, "v1.2.0-rc.1", bin) }, "降級"},
+		{"wrong key", func(t *testing.T) *releaseFixture { return newRelease(t, otherKey, "v1.3.0", bin) }, "簽章驗證失敗"},
+		{"tampered binary", func(t *testing.T) *releaseFixture {
+			r := newRelease(t, priv, "v1.3.0", bin)
+			for name := range r.assets {
+				r.assets[name] = []byte("evil binary")
+			}
+			return r
+		}, "SHA-256"},
+		{"tampered manifest", func(t *testing.T) *releaseFixture {
+			r := newRelease(t, priv, "v1.3.0", bin)
+			r.manifest = []byte(strings.Replace(string(r.manifest), "v1.3.0", "v9.0.0", 1))
+			r.tag = "v9.0.0"
+			return r
+		}, "簽章驗證失敗"},
+		{"old manifest under a new tag", func(t *testing.T) *releaseFixture {
+			r := newRelease(t, priv, "v1.1.0", bin)
+			r.tag = "v1.3.0"
+			return r
+		}, "不符"},
+		{"no build for this platform", func(t *testing.T) *releaseFixture {
+			r := newRelease(t, priv, "v1.3.0", bin)
+			m := strings.ReplaceAll(string(r.manifest), `"os":"`+runtime.GOOS+`"`, `"os":"other"`)
+			r.sign(priv, []byte(m))
+			return r
+		}, "沒有 " + runtime.GOOS + "/" + runtime.GOARCH},
+		{"invalid version", func(t *testing.T) *releaseFixture { return newRelease(t, priv, "latest", bin) }, "無效"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.release(t).serve(t)
+			to, got, err := fetchUpdate(http.DefaultClient, pub)
+			if tt.want == "" {
+				if err != nil || string(got) != string(bin) || to != "v1.3.0" {
+					t.Fatalf("fetchUpdate = %q, %q, %v", to, got, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchUpdateDevBuild(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	newRelease(t, priv, "v1.3.0", []byte("x")).serve(t)
+	version = "dev"
+	if _, _, err := fetchUpdate(http.DefaultClient, pub); err == nil || !strings.Contains(err.Error(), "不是發行版本") {
+		t.Errorf("err = %v, want a refusal for a non-release build", err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// earlyCommands 是可以在 0-RTT 送出請求的指令。0-RTT 資料可能被重送，只允許不修改伺服器狀態的指令。
+var earlyCommands = []string{"ls", "get"}
+
+var (
+	no0RTT    bool // --no-0rtt：仍恢復 TLS session，但等交握完成才送出請求
+	dialEarly bool // 這次執行的指令可使用 0-RTT
+)
+
+// sessionCacheDir 回傳 session ticket 的快取目錄（$XDG_CACHE_HOME/quic-client/sessions 或 ~/.cache/quic-client/sessions）。
+func sessionCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "quic-client", "sessions"), nil
+}
+
+// diskSessionCache 把 TLS session ticket 存在磁碟上，每個伺服器一個檔案，
+// 讓下一次執行可以恢復 session 並以 0-RTT 送出第一個請求。
+type diskSessionCache struct {
+	dir string
+}
+
+var sessionCache = sync.OnceValue(func() tls.ClientSessionCache {
+	dir, err := sessionCacheDir()
+	if err != nil {
+		return nil
+	}
+	return diskSessionCache{dir: dir}
+})
+
+type cachedSession struct {
+	Ticket []byte `json:"ticket"`
+	State  []byte `json:"state"`
+}
+
+func (c diskSessionCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:16]))
+}
+
+func (c diskSessionCache) Get(key string) (*tls.ClientSessionState, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var cs cachedSession
+	if json.Unmarshal(data, &cs) != nil {
+		return nil, false
+	}
+	state, err := tls.ParseSessionState(cs.State)
+	if err != nil {
+		return nil, false
+	}
+	session, err := tls.NewResumptionState(cs.Ticket, state)
+	if err != nil {
+		return nil, false
+	}
+	return session, true
+}
+
+// Put 儲存伺服器發出的新 ticket；session 為 nil 表示 ticket 已失效，刪除快取。
+// 寫入失敗只會讓下次連線做完整交握，不回報錯誤。
+func (c diskSessionCache) Put(key string, session *tls.ClientSessionState) {
+	p := c.path(key)
+	if session == nil {
+		os.Remove(p)
+		return
+	}
+	ticket, state, err := session.ResumptionState()
+	if err != nil {
+		return
+	}
+	b, err := state.Bytes()
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(cachedSession{Ticket: ticket, State: b})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, ".ticket-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || os.Rename(tmp.Name(), p) != nil {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"golang.org/x/term"
+
+	"go-client/client"
+)
+
+const sftpHelp = `可用指令（與 sftp 相同）:
+  ls [-l] [path]        列出遠端目錄
+  cd path               切換遠端工作目錄
+  pwd                   顯示遠端工作目錄
+  get remote            下載檔案到本機工作目錄
+  mget pattern...       下載所有符合樣式的檔案
+  put local [remote]    上傳檔案
+  mput pattern...       上傳所有符合樣式的本機檔案
+  lcd path              切換本機工作目錄
+  lls [path]            列出本機目錄
+  lpwd                  顯示本機工作目錄
+  ping                  量測應用層 RTT（連線中斷時會先重新連線）
+  help                  顯示此說明
+  bye | exit | quit     結束
+批次模式下任何指令失敗即中止，指令前加上 - 則忽略該指令的錯誤。
+`
+
+// sftpShell 是 sftp 模式的狀態：遠端工作目錄與 get 使用的傳輸選項。
+// session 是目前的連線，每個遠端指令執行前由 live 確認仍然存活。
+type sftpShell struct {
+	ctx     context.Context
+	live    *liveSession
+	session *quic.Conn
+	opts    getOptions
+	cwd     string // 遠端工作目錄，空字串為根目錄
+}
+
+// runSFTP 提供與 sftp 相似的指令介面。batch 不為空時從檔案讀取指令（如 sftp -b），
+// 否則讀取 stdin；非互動模式下第一個失敗的指令會中止執行。
+func runSFTP(ctx context.Context, live *liveSession, opts getOptions, batch string) error {
+	var in io.Reader = os.Stdin
+	interactive := batch == "" && term.IsTerminal(int(os.Stdin.Fd()))
+	if batch != "" && batch != "-" {
+		f, err := os.Open(batch)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	// sftp 模式自己讀取 stdin，傳輸時不監聽 p/r/+/- 按鍵
+	opts.noKeys = true
+	sh := &sftpShell{ctx: ctx, live: live, opts: opts}
+
+	scanner := bufio.NewScanner(in)
+	for {
+		if interactive {
+			fmt.Fprint(os.Stderr, "sftp> ")
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ignoreErr := strings.HasPrefix(line, "-")
+		fields := strings.Fields(strings.TrimPrefix(line, "-"))
+		if len(fields) == 0 {
+			continue
+		}
+		if !interactive {
+			con.Printf("sftp> %s\n", line)
+		}
+		switch fields[0] {
+		case "bye", "exit", "quit":
+			return nil
+		}
+		if err := sh.exec(fields[0], fields[1:]); err != nil {
+			con.Printf("%s: %v\n", fields[0], err)
+			if (!interactive && !ignoreErr) || ctx.Err() != nil {
+				return err
+			}
+		}
+	}
+}
+
+// remote 把指令中的遠端路徑轉成相對遠端根目錄的路徑；以 / 開頭的路徑視為絕對路徑。
+func (sh *sftpShell) remote(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join("/", sh.cwd, p)
+	}
+	return strings.TrimPrefix(path.Clean(p), "/")
+}
+
+func (sh *sftpShell) exec(cmd string, args []string) error {
+	switch cmd {
+	case "help", "?", "pwd", "lcd", "lpwd", "lls":
+	default:
+		session, err := sh.live.get(sh.ctx)
+		if err != nil {
+			return err
+		}
+		sh.session = session
+	}
+	switch cmd {
+	case "help", "?":
+		con.Printf("%s", sftpHelp)
+	case "pwd":
+		con.Printf("遠端工作目錄: /%s\n", sh.cwd)
+	case "cd":
+		target := ""
+		if len(args) > 0 {
+			target = sh.remote(args[0])
+		}
+		e, err := statRemote(sh.ctx, sh.session, target)
+		if err != nil {
+			return err
+		}
+		if e.Type != "dir" {
+			return fmt.Errorf("%s 不是目錄", args[0])
+		}
+		sh.cwd = target
+	case "ls", "dir":
+		return sh.ls(args)
+	case "get":
+		if len(args) != 1 {
+			return errors.New("用法: get remote")
+		}
+		return runGet(sh.ctx, sh.session, sh.remote(args[0]), sh.opts)
+	case "mget":
+		return sh.mget(args)
+	case "put":
+		if len(args) < 1 || len(args) > 2 {
+			return errors.New("用法: put local [remote]")
+		}
+		remote := filepath.Base(args[0])
+		if len(args) == 2 {
+			remote = args[1]
+		}
+		return sh.put(args[0], sh.remote(remote))
+	case "mput":
+		for _, pattern := range args {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return err
+			}
+			if len(matches) == 0 {
+				return fmt.Errorf("%s: 沒有符合的檔案", pattern)
+			}
+			for _, m := range matches {
+				if err := sh.put(m, sh.remote(filepath.Base(m))); err != nil {
+					return err
+				}
+			}
+		}
+	case "ping":
+		rtt, err := pingOnce(sh.ctx, sh.session)
+		if err != nil {
+			return err
+		}
+		con.Printf("%s: rtt=%v\n", sh.session.RemoteAddr(), rtt.Round(time.Microsecond))
+	case "lcd":
+		if len(args) != 1 {
+			return errors.New("用法: lcd path")
+		}
+		return os.Chdir(args[0])
+	case "lpwd":
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		con.Printf("本機工作目錄: %s\n", wd)
+	case "lls":
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if e.IsDir() {
+				name += "/"
+			}
+			con.Println(name)
+		}
+	default:
+		return fmt.Errorf("未知指令，輸入 help 查看可用指令")
+	}
+	return nil
+}
+
+func (sh *sftpShell) ls(args []string) error {
+	long := false
+	if len(args) > 0 && args[0] == "-l" {
+		long, args = true, args[1:]
+	}
+	dir := sh.cwd
+	if len(args) > 0 {
+		dir = sh.remote(args[0])
+	}
+	return client.New(sh.session).ListFunc(sh.ctx, dir, long, func(e client.Entry) error {
+		name := e.Name
+		if e.Type == "dir" {
+			name += "/"
+		}
+		if long {
+			name = longEntry(e)
+		}
+		con.Println(name)
+		return nil
+	})
+}
+
+// mget 下載遠端目錄中所有符合樣式的檔案；樣式只比對最後一段路徑。
+func (sh *sftpShell) mget(patterns []string) error {
+	if len(patterns) == 0 {
+		return errors.New("用法: mget pattern...")
+	}
+	for _, pattern := range patterns {
+		full := sh.remote(pattern)
+		dir, base := path.Split(full)
+		dir = strings.TrimSuffix(dir, "/")
+		var matches []string
+		err := client.New(sh.session).ListFunc(sh.ctx, dir, false, func(e client.Entry) error {
+			if ok, _ := path.Match(base, e.Name); ok && e.Type != "dir" {
+				matches = append(matches, path.Join(dir, e.Name))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("%s: 沒有符合的檔案", pattern)
+		}
+		for _, m := range matches {
+			if err := runGet(sh.ctx, sh.session, m, sh.opts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (sh *sftpShell) put(local, remote string) error {
+	n, err := sendFile(sh.ctx, sh.session, local, remote, sh.opts)
+	if err != nil {
+		return err
+	}
+	con.Printf("已上傳 %s -> /%s (%s)\n", local, remote, humanSize(n))
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+
+	"go-client/client"
+)
+
+// shellCommands 是互動模式可補全的指令，與 sftp 模式相同。
+var shellCommands = []string{"bye", "cd", "dir", "exit", "get", "help", "lcd", "lls", "lpwd", "ls", "mget", "mput", "ping", "put", "pwd", "quit"}
+
+// listingTTL 是補全使用的遠端列表快取時間。
+const listingTTL = 30 * time.Second
+
+// interactiveShell 在 sftp 指令之上加入行編輯、歷史紀錄與 Tab 補全。
+type interactiveShell struct {
+	*sftpShell
+	term *term.Terminal
+
+	mu    sync.Mutex
+	cache map[string]cachedListing // 遠端目錄 -> 列表
+}
+
+type cachedListing struct {
+	entries []client.Entry
+	at      time.Time
+}
+
+// runShell 實作 `shell`：在同一個連線上互動地執行 ls、cd、get、put 等指令，
+// Tab 補全指令名稱與遠端或本機路徑。stdin 不是終端機時等同 sftp 模式。
+// 連線中斷時在指令結束後回報，下一個遠端指令執行前自動重新連線。
+func runShell(ctx context.Context, live *liveSession, server string, opts getOptions) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return runSFTP(ctx, live, opts, "")
+	}
+	opts.noKeys = true
+	sh := &interactiveShell{
+		sftpShell: &sftpShell{ctx: ctx, live: live, session: live.conn, opts: opts},
+		term: term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stderr}, ""),
+		cache: make(map[string]cachedListing),
+	}
+	sh.term.AutoCompleteCallback = sh.complete
+	con.Printf("已連線到 %s，輸入 help 查看可用指令，Tab 補全路徑\n", server)
+
+	for {
+		sh.term.SetPrompt(fmt.Sprintf("%s:/%s> ", server, sh.cwd))
+		// 只在讀取指令時切換到 raw mode，指令執行期間的進度列需要正常的終端機
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		line, err := sh.term.ReadLine()
+		term.Restore(fd, state)
+		if err == io.EOF {
+			con.Println()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "bye", "exit", "quit":
+			return nil
+		case "put", "mput":
+			sh.invalidate()
+		}
+		if err := sh.exec(fields[0], fields[1:]); err != nil {
+			con.Printf("%s: %v\n", fields[0], err)
+			if ctx.Err() != nil {
+				return err
+			}
+		}
+		if !live.alive() {
+			con.Printf("與 %s 的連線已中斷，下一個遠端指令會重新連線\n", server)
+		}
+	}
+}
+
+// complete 是 term.Terminal 的補全回呼：只處理 Tab，補全游標前的最後一個字。
+// 只有一個候選時直接補全；有多個時補到共同前綴，已無法再補時列出所有候選。
+func (sh *interactiveShell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	head := line[:pos]
+	start := strings.LastIndex(head, " ") + 1
+	word := head[start:]
+
+	var candidates []string
+	if strings.TrimSpace(head[:start]) == "" {
+		for _, c := range shellCommands {
+			if strings.HasPrefix(c, word) {
+				candidates = append(candidates, c+" ")
+			}
+		}
+	} else {
+		switch strings.Fields(head)[0] {
+		case "ls", "dir", "cd", "get", "mget":
+			candidates = sh.remoteCandidates(word)
+		case "put", "mput", "lcd", "lls":
+			candidates = localCandidates(word)
+		}
+	}
+	if len(candidates) == 0 {
+		return line, pos, true
+	}
+	completed := candidates[0]
+	if len(candidates) > 1 {
+		completed = commonPrefix(candidates)
+		if completed == word {
+			fmt.Fprintln(sh.term, strings.Join(candidates, "  "))
+			return line, pos, true
+		}
+	}
+	head = head[:start] + completed
+	return head + line[pos:], len(head), true
+}
+
+// remoteCandidates 列出遠端目錄中以 word 最後一段為前綴的項目，目錄以 / 結尾。
+func (sh *interactiveShell) remoteCandidates(word string) []string {
+	dir, prefix := path.Split(word)
+	entries, err := sh.list(sh.remote(dir))
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name, prefix) {
+			continue
+		}
+		if e.Type == "dir" {
+			out = append(out, dir+e.Name+"/")
+		} else {
+			out = append(out, dir+e.Name+" ")
+		}
+	}
+	return out
+}
+
+// list 回傳遠端目錄的列表，listingTTL 內重複補全時使用快取。
+func (sh *interactiveShell) list(dir string) ([]client.Entry, error) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if c, ok := sh.cache[dir]; ok && time.Since(c.at) < listingTTL {
+		return c.entries, nil
+	}
+	var entries []client.Entry
+	err := client.New(sh.session).ListFunc(sh.ctx, dir, true, func(e client.Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sh.cache[dir] = cachedListing{entries, time.Now()}
+	return entries, nil
+}
+
+// invalidate 在上傳等會改變遠端內容的指令前清除列表快取。
+func (sh *interactiveShell) invalidate() {
+	sh.mu.Lock()
+	clear(sh.cache)
+	sh.mu.Unlock()
+}
+
+func localCandidates(word string) []string {
+	dir, prefix := filepath.Split(word)
+	entries, err := os.ReadDir(filepath.Join(".", dir))
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+		if e.IsDir() {
+			out = append(out, dir+e.Name()+string(filepath.Separator))
+		} else {
+			out = append(out, dir+e.Name()+" ")
+		}
+	}
+	return out
+}
+
+func commonPrefix(words []string) string {
+	prefix := slices.Min(words)
+	last := slices.Max(words)
+	i := 0
+	for i < len(prefix) && i < len(last) && prefix[i] == last[i] {
+		i++
+	}
+	return prefix[:i]
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// maxManifestSize 是伺服器提供的 manifest 大小上限。
+const maxManifestSize = 64 << 20
+
+// loadPublicKey 讀取 ed25519 公鑰，支援 PEM（PKIX）或 base64 編碼的 32 位元組原始金鑰。
+func loadPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: 不是 ed25519 公鑰", path)
+		}
+		return pub, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%s: 無效的 ed25519 公鑰", path)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// verifySignature 驗證 data 的 base64 簽章。
+func verifySignature(pub ed25519.PublicKey, data []byte, sig string) error {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(sig))
+	if err != nil || !ed25519.Verify(pub, data, raw) {
+		return errors.New("manifest 簽章驗證失敗")
+	}
+	return nil
+}
+
+// runFetchManifest 以 `manifest <dir>` 向伺服器取得簽章過的 SHA256SUMS。
+// 回應第一行為 "<size> <base64 簽章>"，接著是 size 位元組的 manifest。
+// 簽章通過後才寫入 out 及 out.sig，供之後以 `verify` 離線檢查。
+func runFetchManifest(ctx context.Context, session *quic.Conn, dir, out string, pub ed25519.PublicKey) error {
+	stream, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stream, "manifest %s\n", client.New(session).EncodePath(dir))
+
+	r := bufio.NewReader(stream)
+	header, err := client.ReadHeaderLine(r)
+	if err != nil {
+		return fmt.Errorf("無法讀取 manifest 標頭: %v", err)
+	}
+	if err := client.CheckServerError(header); err != nil {
+		return err
+	}
+	sizeField, sig, ok := strings.Cut(header, " ")
+	size, err := strconv.ParseInt(sizeField, 10, 64)
+	if !ok || err != nil || size < 0 || size > maxManifestSize {
+		return fmt.Errorf("無效的 manifest 標頭 %q", header)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return fmt.Errorf("無法讀取 manifest: %v", err)
+	}
+	if err := verifySignature(pub, body, sig); err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, body, 0o644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(out+".sig", []byte(sig+"\n"), 0o644); err != nil {
+		return err
+	}
+	con.Println("manifest 簽章正確，已寫入:", out)
+	con.Result(struct {
+		Manifest  string `json:"manifest"`
+		Signature string `json:"signature"`
+	}{out, "valid"})
+	return nil
+}
+
+// verifyManifestSignature 檢查 manifest 旁的 .sig 檔。
+func verifyManifestSignature(path string, pub ed25519.PublicKey) error {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sig, err := os.ReadFile(path + ".sig")
+	if err != nil {
+		return fmt.Errorf("找不到 manifest 簽章: %v", err)
+	}
+	return verifySignature(pub, body, string(sig))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// humanSize 以 1024 為基數把位元組數格式化為 "1.5 MiB" 之類的字串。
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 5; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// parseSize 解析 "1048576"、"500k"、"1.5G" 之類的大小，單位以 1024 為基數。
+func parseSize(s string) (int64, error) {
+	str := strings.TrimSpace(s)
+	str = strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(str), "b"), "i")
+	mult := 1.0
+	if n := len(str); n > 0 {
+		switch str[n-1] {
+		case 'k':
+			mult = 1 << 10
+		case 'm':
+			mult = 1 << 20
+		case 'g':
+			mult = 1 << 30
+		case 't':
+			mult = 1 << 40
+		}
+		if mult != 1 {
+			str = str[:n-1]
+		}
+	}
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil || v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("無效的大小 %q", s)
+	}
+	v *= mult
+	if v >= math.MaxInt64 {
+		return 0, fmt.Errorf("大小 %q 超出範圍", s)
+	}
+	return int64(v), nil
+}
+
+// parseRate 解析 --limit 之類的速度，例如 "500k"、"2M"、"1.5m/s"，單位為 bytes/sec。
+func parseRate(s string) (int64, error) {
+	str := strings.TrimSpace(s)
+	str = strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(str), "/s"), "ps")
+	n, err := parseSize(str)
+	if err != nil {
+		return 0, fmt.Errorf("無效的速度 %q", s)
+	}
+	return n, nil
+}
+
+// humanRate 把 bytes/sec 格式化為 "1.5 MiB/s"，0 表示不限速。
+func humanRate(n int64) string {
+	if n <= 0 {
+		return "不限速"
+	}
+	return humanSize(n) + "/s"
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"os"
+	"time"
+
+	"go-client/client"
+)
+
+// transferState 是下載期間與暫存檔並存的 .state 旁檔，定期在 fsync 暫存檔之後更新。
+// 當機或斷電後 --resume 依它驗證暫存檔中已寫入的部分並從記錄的位置接續，
+// 不必相信暫存檔的大小（斷電時尾端可能是未寫入的資料）。
+// 循序下載記錄 Written 與這段的 SHA-256；--streams 與 --connections 的分段下載記錄每個區段的進度。
+type transferState struct {
+	Remote  string       `json:"remote"`
+	Size    int64        `json:"size"`
+	Mtime   time.Time    `json:"mtime,omitzero"`
+	Written int64        `json:"written,omitempty"`
+	SHA256  string       `json:"sha256,omitempty"` // 前 Written 個位元組的 SHA-256
+	Chunks  []stateChunk `json:"chunks,omitempty"`
+}
+
+// stateChunk 是分段下載的一個區段 [Off, End)，其中 [Off, Pos) 已寫入，SHA256 為這部分的雜湊。
+type stateChunk struct {
+	Off    int64  `json:"off"`
+	Pos    int64  `json:"pos"`
+	End    int64  `json:"end"`
+	SHA256 string `json:"sha256"`
+}
+
+func statePath(local string) string {
+	return partialPath(local) + ".state"
+}
+
+// loadState 讀取 local 的 .state；不存在、無法解析或屬於其他遠端檔案時回傳 nil。
+func loadState(local, remote string) *transferState {
+	data, err := os.ReadFile(statePath(local))
+	if err != nil {
+		return nil
+	}
+	var st transferState
+	if json.Unmarshal(data, &st) != nil || st.Remote != remote {
+		return nil
+	}
+	return &st
+}
+
+// matches 判斷遠端檔案自記錄後是否未變更。
+func (st *transferState) matches(size int64, meta client.FileMeta) bool {
+	if st.Size != size {
+		return false
+	}
+	return st.Mtime.IsZero() || meta.Mtime.IsZero() || st.Mtime.Equal(meta.Mtime)
+}
+
+// save 以暫存檔加 rename 原子地寫入 .state，當機時不會留下寫到一半的內容。
+func (st *transferState) save(local string) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	path := statePath(local)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func removeState(local string) {
+	os.Remove(statePath(local))
+}
+
+// verifyPrefix 重新計算暫存檔 [off, pos) 的 SHA-256 並與記錄比對，相符時回傳已包含這部分的雜湊，
+// 讓後續寫入接著計算。
+func verifyPrefix(f io.ReaderAt, off, pos int64, want string) (hash.Hash, bool) {
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, off, pos-off)); err != nil {
+		return nil, false
+	}
+	return h, hex.EncodeToString(h.Sum(nil)) == want
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// streamStat 是單一 stream 的傳輸統計。
+type streamStat struct {
+	StreamID int64         `json:"stream_id"`
+	Label    string        `json:"label"`
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+	Rate     float64       `json:"rate"` // bytes/sec
+
+	bytes atomic.Int64
+	start time.Time
+	end   time.Time
+}
+
+// transferStats 收集一次執行中所有 stream 的統計，在摘要中列出各 stream 的差異。
+type transferStats struct {
+	mu      sync.Mutex
+	streams []*streamStat
+	conns   []*connMetrics // 傳輸用過的連線（重試時會重新連線），用來加總重傳量
+	sha256  []string       // 完成的檔案的 SHA-256
+
+	start   time.Time
+	total   atomic.Int64
+	wire    atomic.Int64 // 壓縮傳輸時線上的位元組數
+	peak    atomic.Int64 // 每秒取樣的最高速度（bytes/s）
+	retries atomic.Int64
+	sampler sync.Once
+	done    chan struct{}
+}
+
+func newTransferStats() *transferStats {
+	return &transferStats{start: time.Now(), done: make(chan struct{})}
+}
+
+// transferSummary 是 get/put 完成後輸出的統計摘要。
+type transferSummary struct {
+	Bytes         int64         `json:"bytes"`
+	Duration      time.Duration `json:"duration"`
+	AvgRate       float64       `json:"avg_rate"`  // bytes/sec
+	PeakRate      float64       `json:"peak_rate"` // bytes/sec，每秒取樣
+	Retries       int64         `json:"retries"`
+	Retransmitted int64         `json:"retransmitted_bytes"`
+	SHA256        string        `json:"sha256,omitempty"`     // 只傳輸一個檔案時提供
+	WireBytes     int64         `json:"wire_bytes,omitempty"` // 有壓縮時線上（壓縮後）的位元組數
+}
+
+// Connection 登記傳輸使用的連線，摘要中的重傳量取自它的統計。
+func (ts *transferStats) Connection(session *quic.Conn) {
+	m := metricsOf(session)
+	if ts == nil || m == nil {
+		return
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if !slices.Contains(ts.conns, m) {
+		ts.conns = append(ts.conns, m)
+	}
+}
+
+// Retried 記錄一次重新連線後的重試。
+func (ts *transferStats) Retried() {
+	if ts != nil {
+		ts.retries.Add(1)
+	}
+}
+
+// Finished 記錄一個完成的檔案的 SHA-256。
+func (ts *transferStats) Finished(sum string) {
+	if ts == nil {
+		return
+	}
+	ts.mu.Lock()
+	ts.sha256 = append(ts.sha256, sum)
+	ts.mu.Unlock()
+}
+
+// AddWire 記錄一個壓縮傳輸在線上實際傳送的位元組數。
+func (ts *transferStats) AddWire(n int64) {
+	if ts != nil {
+		ts.wire.Add(n)
+	}
+}
+
+// sample 每秒記錄一次所有 stream 合計的速度，保留最高值。
+func (ts *transferStats) sample() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	last := ts.total.Load()
+	for {
+		select {
+		case <-ts.done:
+			return
+		case <-ticker.C:
+			n := ts.total.Load()
+			if d := n - last; d > ts.peak.Load() {
+				ts.peak.Store(d)
+			}
+			last = n
+		}
+	}
+}
+
+// Summary 停止取樣並輸出傳輸量、耗時、平均與最高速度、重試次數、重傳量與最後的校驗和。
+func (ts *transferStats) Summary() {
+	if ts == nil {
+		return
+	}
+	close(ts.done)
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	s := transferSummary{
+		Bytes:     ts.total.Load(),
+		Duration:  time.Since(ts.start),
+		PeakRate:  float64(ts.peak.Load()),
+		Retries:   ts.retries.Load(),
+		WireBytes: ts.wire.Load(),
+	}
+	if secs := s.Duration.Seconds(); secs > 0 {
+		s.AvgRate = float64(s.Bytes) / secs
+	}
+	// 不到一秒的傳輸沒有取樣
+	s.PeakRate = max(s.PeakRate, s.AvgRate)
+	for _, m := range ts.conns {
+		s.Retransmitted += m.Snapshot().BytesLost
+	}
+	if len(ts.sha256) == 1 {
+		s.SHA256 = ts.sha256[0]
+	}
+	con.Printf("共 %s，耗時 %v，平均 %s/s，最高 %s/s，重試 %d 次，重傳 %s\n",
+		humanSize(s.Bytes), s.Duration.Round(time.Millisecond), humanSize(int64(s.AvgRate)), humanSize(int64(s.PeakRate)),
+		s.Retries, humanSize(s.Retransmitted))
+	if s.WireBytes > 0 && s.Bytes > 0 {
+		con.Printf("壓縮後 %s（%.0f%%）\n", humanSize(s.WireBytes), float64(s.WireBytes)/float64(s.Bytes)*100)
+	}
+	if s.SHA256 != "" {
+		con.Printf("sha256 %s\n", s.SHA256)
+	}
+	con.Result(s)
+}
+
+// Track 登記一個 stream，回傳的 reader 會計算讀取的位元組數；讀到結尾或錯誤時記錄完成時間。
+func (ts *transferStats) Track(id int64, label string, r io.Reader) io.Reader {
+	if ts == nil {
+		return r
+	}
+	ts.sampler.Do(func() { go ts.sample() })
+	s := &streamStat{StreamID: id, Label: label, start: time.Now()}
+	ts.mu.Lock()
+	ts.streams = append(ts.streams, s)
+	ts.mu.Unlock()
+	return &statReader{r: r, s: s, total: &ts.total}
+}
+
+type statReader struct {
+	r     io.Reader
+	s     *streamStat
+	total *atomic.Int64
+}
+
+func (sr *statReader) Read(p []byte) (int, error) {
+	n, err := sr.r.Read(p)
+	sr.s.bytes.Add(int64(n))
+	sr.total.Add(int64(n))
+	if err != nil && sr.s.end.IsZero() {
+		sr.s.end = time.Now()
+	}
+	return n, err
+}
+
+// Report 在使用多個 stream 時輸出每個 stream 的位元組數、速度與完成時間。
+func (ts *transferStats) Report() {
+	if ts == nil {
+		return
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if len(ts.streams) < 2 {
+		return
+	}
+	w := tabwriter.NewWriter(con.Text(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STREAM\tLABEL\tBYTES\tRATE\tDURATION")
+	for _, s := range ts.streams {
+		end := s.end
+		if end.IsZero() {
+			end = time.Now()
+		}
+		s.Bytes = s.bytes.Load()
+		s.Duration = end.Sub(s.start)
+		if secs := s.Duration.Seconds(); secs > 0 {
+			s.Rate = float64(s.Bytes) / secs
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s/s\t%v\n", s.StreamID, s.Label, humanSize(s.Bytes), humanSize(int64(s.Rate)), s.Duration.Round(time.Millisecond))
+		con.Result(s)
+	}
+	w.Flush()
+}
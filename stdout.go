@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// getToStdout 實作 `-o -`：把檔案內容寫到 stdout 供管線使用。沒有本機檔案可以續傳或改名，
+// 因此不使用 --resume、--streams 與檢查點；校驗失敗時內容已經送出，只能回傳錯誤讓管線的結束狀態反映出來。
+func getToStdout(ctx context.Context, session *quic.Conn, filename string, opts getOptions) error {
+	compress := opts.compress.For(filename)
+	d, err := client.New(session).Open(ctx, filename, client.GetOptions{
+		Compression: compress.codec,
+		Level:       compress.level,
+		Checksum:    opts.checksum,
+	})
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	hasher := sha256.New()
+	hashes := io.Writer(hasher)
+	var verifier hash.Hash
+	if d.Checksum != nil && !opts.noVerify {
+		verifier = hasher
+		if d.Checksum.Algorithm != "sha256" {
+			verifier = d.Checksum.New()
+			hashes = io.MultiWriter(hasher, verifier)
+		}
+	}
+
+	prioritized := NewPrioritizedReader(opts.stats.Track(int64(d.StreamID()), filename, d), opts.priority, streamPriorities)
+	defer prioritized.Close()
+	bucket := newTokenBucket()
+	opts.limiter.Join(bucket, opts.weight)
+	defer opts.limiter.Leave(bucket)
+	progress := NewProgressReader(bucket.Reader(prioritized), d.Size)
+	progress.name = filename
+	progress.StartMonitor()
+
+	con.Event(transferEvent{Event: "start", File: filename, Total: d.Size, Local: "-"})
+	start := time.Now()
+	// 與 runGet 相同：多送的內容不寫出，少送時即使沒有可校驗的雜湊也要以錯誤結束
+	n, err := io.Copy(io.MultiWriter(os.Stdout, hashes), io.LimitReader(progress, d.Size))
+	progress.Stop()
+	if err != nil {
+		if interrupted.Load() {
+			return errInterrupted
+		}
+		return err
+	}
+	if n != d.Size {
+		return fmt.Errorf("下載提早結束 (%d/%d bytes)，stdout 上的內容不完整", n, d.Size)
+	}
+	if verifier != nil {
+		if err := d.Checksum.Verify(verifier); err != nil {
+			return err
+		}
+	}
+	finishGet(session, filename, "-", d.Size, hex.EncodeToString(hasher.Sum(nil)), start, opts)
+	return nil
+}
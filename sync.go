@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// runSync 實作 `sync [-j N] [--delete] <remotedir> <localdir>`：比對遠端列表的大小、修改時間與雜湊，
+// 只下載本機缺少或內容不同的檔案，下載後把本機的修改時間設成遠端的值，下次比對時即可略過。
+// --delete 刪除遠端已不存在的本機檔案。--dry-run 時只列出會下載、覆寫與刪除的檔案。
+func runSync(ctx context.Context, session *quic.Conn, args []string, filters filterRules, maxDepth int, weights weightRules, opts getOptions) error {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	jobs := flags.Int("j", opts.concurrency, "同時下載的檔案數")
+	del := flags.Bool("delete", false, "刪除遠端已不存在的本機檔案")
+	flags.Parse(args)
+	if flags.NArg() != 2 || *jobs < 1 {
+		return fmt.Errorf("用法: data_cli <ip:port> sync [-j N] [--delete] <remotedir> <localdir>")
+	}
+	dir := strings.Trim(path.Clean("/"+flags.Arg(0)), "/")
+	root := flags.Arg(1)
+
+	var files []remoteFile
+	remote := make(map[string]bool)
+	unchanged := 0
+	err := walkRemote(ctx, session, dir, filters, maxDepth, func(rel string, e client.Entry) error {
+		remote[rel] = true
+		local := filepath.Join(root, filepath.FromSlash(rel))
+		changed, err := syncChanged(local, e, opts.dryRun)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			unchanged++
+			return nil
+		}
+		files = append(files, remoteFile{remote: path.Join(dir, rel), local: local, size: e.Size, mtime: e.Mtime})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	con.Printf("%s -> %s: %d 個檔案需要更新，%d 個未變更\n", dir, root, len(files), unchanged)
+
+	// 同步的目的就是讓本機與遠端一致，變更的檔案一律覆寫
+	opts.clobber = clobberForce
+	if len(files) > 0 {
+		if err := getFiles(ctx, session, dir, files, *jobs, weights, opts); err != nil {
+			return err
+		}
+	}
+	if !*del {
+		return nil
+	}
+	deleted := 0
+	err = walkLocal(root, filters, maxDepth, func(rel string, d fs.DirEntry) error {
+		if remote[rel] || strings.HasSuffix(rel, ".partial") || strings.HasSuffix(rel, ".partial.state") {
+			return nil
+		}
+		local := filepath.Join(root, filepath.FromSlash(rel))
+		deleted++
+		if opts.dryRun {
+			dryRunAction{Action: "delete", Local: local}.print()
+			return nil
+		}
+		if err := os.Remove(local); err != nil {
+			return err
+		}
+		con.Printf("已刪除 %s\n", rel)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	switch {
+	case deleted > 0 && opts.dryRun:
+		con.Printf("試執行: 會刪除 %d 個遠端不存在的本機檔案\n", deleted)
+	case deleted > 0:
+		con.Printf("已刪除 %d 個遠端不存在的本機檔案\n", deleted)
+	}
+	return err
+}
+
+// syncChanged 判斷本機檔案是否需要重新下載：不存在或大小不同即需要；
+// 修改時間相同視為未變更；修改時間不同但伺服器提供雜湊時，比對內容後只更新修改時間（dryRun 時不更新）。
+func syncChanged(local string, e client.Entry, dryRun bool) (bool, error) {
+	info, err := os.Stat(local)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() || info.Size() != e.Size {
+		return true, nil
+	}
+	if e.Mtime.IsZero() || info.ModTime().Truncate(time.Second).Equal(e.Mtime.Truncate(time.Second)) {
+		return false, nil
+	}
+	want := strings.TrimPrefix(e.Hash, "sha256:")
+	if want == "" {
+		return true, nil
+	}
+	sum, err := fileSHA256(local)
+	if err != nil {
+		return false, err
+	}
+	if !strings.EqualFold(sum, want) {
+		return true, nil
+	}
+	if dryRun {
+		return false, nil
+	}
+	return false, os.Chtimes(local, e.Mtime, e.Mtime)
+}
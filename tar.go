@@ -0,0 +1,233 @@
+package main
+
+import (
+	"archive/tar"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// runGetTar 實作 `get --tar <dir>`：要求伺服器以 tar 串流送出整個目錄，邊收邊解開到本機，
+// 大量小檔案時不必逐一請求。本機目錄的決定方式與 get -r 相同；只解開一般檔案與目錄。
+func runGetTar(ctx context.Context, session *quic.Conn, args []string, opts getOptions) error {
+	if len(args) != 1 {
+		return fmt.Errorf("用法: data_cli <ip:port> get --tar <dir>")
+	}
+	dir := strings.Trim(path.Clean("/"+args[0]), "/")
+	root, err := destination(dir, opts.output, opts.parents)
+	if err != nil {
+		root = cmp.Or(opts.output, ".")
+	}
+	compress := opts.compress.For(dir + ".tar")
+	d, err := client.New(session).OpenTar(ctx, dir, client.GetOptions{Compression: compress.codec, Level: compress.level})
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	reader := opts.stats.Track(int64(d.StreamID()), dir+".tar", d)
+	bucket := newTokenBucket()
+	opts.limiter.Join(bucket, opts.weight)
+	defer opts.limiter.Leave(bucket)
+	progress := NewProgressReader(bucket.Reader(reader), 0)
+	progress.name = dir + ".tar"
+	progress.StartMonitor()
+
+	con.Event(transferEvent{Event: "start", File: dir, Local: root})
+	start := time.Now()
+	files, size, err := extractTar(tar.NewReader(progress), root, opts)
+	progress.Stop()
+	if err != nil {
+		if interrupted.Load() {
+			return errInterrupted
+		}
+		return err
+	}
+	con.Event(transferEvent{Event: "done", File: dir, Bytes: size, Total: size, Local: root, Time: time.Since(start)})
+	con.Printf("目錄下載完成: %s -> %s（%d 個檔案，%s）\n", dir, root, files, humanSize(size))
+	return nil
+}
+
+// extractTar 把 tar 串流解開到 root。成員路徑一律視為相對於 root，不會寫到 root 之外；
+// 每個檔案先寫到 .partial 再依 --force/--backup/--no-clobber 取代既有檔案。
+func extractTar(tr *tar.Reader, root string, opts getOptions) (files int, size int64, err error) {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, size, nil
+		}
+		if err != nil {
+			return files, size, err
+		}
+		rel := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		if rel == "" {
+			continue
+		}
+		local := filepath.Join(root, filepath.FromSlash(rel))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := opts.perms.mkdirAll(local); err != nil {
+				return files, size, err
+			}
+			continue
+		case tar.TypeReg:
+		default:
+			slog.Warn("略過：只解開一般檔案與目錄", "name", hdr.Name)
+			continue
+		}
+		if err := opts.clobber.check(local); err != nil {
+			if err == errSkipped {
+				con.Printf("%s 已存在，略過\n", local)
+				continue
+			}
+			return files, size, err
+		}
+		if err := opts.perms.mkdirAll(filepath.Dir(local)); err != nil {
+			return files, size, err
+		}
+		n, err := extractFile(tr, hdr, local, opts)
+		size += n
+		if err != nil {
+			return files, size, err
+		}
+		files++
+	}
+}
+
+func extractFile(r io.Reader, hdr *tar.Header, local string, opts getOptions) (int64, error) {
+	out, err := os.Create(partialPath(local))
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, r)
+	if err == nil {
+		if opts.perms.setFile {
+			err = opts.perms.applyFile(out)
+		} else {
+			err = out.Chmod(fs.FileMode(hdr.Mode).Perm())
+		}
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return n, err
+	}
+	if err := commitOutput(out, local, opts.clobber); err != nil {
+		return n, err
+	}
+	return n, os.Chtimes(local, hdr.ModTime, hdr.ModTime)
+}
+
+// runPutTar 實作 `put --tar <localdir> [remotedir]`：把本機目錄打包成 tar 串流上傳，由伺服器解開到 remotedir
+// （預設為本機目錄名稱）。套用 --include/--exclude、--max-depth 與目錄中的 .quicignore。
+func runPutTar(ctx context.Context, session *quic.Conn, args []string, filters filterRules, maxDepth int, opts getOptions) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("用法: data_cli <ip:port> put --tar <localdir> [remotedir]")
+	}
+	root := args[0]
+	if info, err := os.Stat(root); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s 不是目錄", root)
+	}
+	remote := filepath.Base(filepath.Clean(root))
+	if len(args) == 2 {
+		remote = args[1]
+	}
+	ignored, err := loadIgnoreFiles(root)
+	if err != nil {
+		return err
+	}
+	rules := append(append(filterRules{}, filters...), ignored...)
+
+	u, err := client.New(session).OpenTarUpload(ctx, remote)
+	if err != nil {
+		return err
+	}
+	pr, pw := io.Pipe()
+	var files int
+	go func() {
+		n, err := writeTar(pw, root, rules, maxDepth)
+		files = n
+		pw.CloseWithError(err)
+	}()
+
+	reader := opts.stats.Track(int64(u.StreamID()), remote+".tar", pr)
+	bucket := newTokenBucket()
+	opts.upload.Join(bucket, opts.weight)
+	defer opts.upload.Leave(bucket)
+	progress := NewProgressReader(bucket.Reader(reader), 0)
+	progress.name = remote + ".tar"
+	progress.StartMonitor()
+
+	con.Event(transferEvent{Event: "start", File: remote, Local: root})
+	start := time.Now()
+	n, err := io.Copy(u, progress)
+	progress.Stop()
+	if err != nil {
+		u.Cancel()
+		pr.CloseWithError(err)
+		return fmt.Errorf("上傳失敗: %w", err)
+	}
+	if err := u.Close(); err != nil {
+		return err
+	}
+	con.Event(transferEvent{Event: "done", File: remote, Bytes: n, Total: n, Local: root, Time: time.Since(start)})
+	con.Printf("目錄上傳完成: %s -> %s（%d 個檔案，tar %s）\n", root, remote, files, humanSize(n))
+	return nil
+}
+
+// writeTar 把 root 底下未被 rules 排除的一般檔案依序寫成 tar，回傳寫入的檔案數。
+func writeTar(w io.Writer, root string, rules filterRules, maxDepth int) (int, error) {
+	tw := tar.NewWriter(w)
+	files := 0
+	err := walkLocal(root, rules, maxDepth, func(rel string, d fs.DirEntry) error {
+		if !d.Type().IsRegular() {
+			slog.Warn("略過：只上傳一般檔案", "name", rel)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		f, err := os.Open(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		// 打包期間檔案變大時只送出標頭宣告的長度
+		if _, err := io.CopyN(tw, f, hdr.Size); err != nil {
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("打包期間 %s 變小了", rel)
+			}
+			return err
+		}
+		files++
+		return nil
+	})
+	if err != nil {
+		return files, err
+	}
+	return files, tw.Close()
+}
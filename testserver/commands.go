@@ -0,0 +1,438 @@
+package testserver
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// session 是一條連線的狀態。
+type session struct {
+	srv     *Server
+	conn    *quic.Conn
+	version int // 協商的協定版本，2 起路徑以百分比編碼
+	authed  atomic.Bool
+}
+
+// handle 讀取 stream 上的一行指令並回應，回應完關閉 stream。
+func (s *session) handle(stream *quic.Stream) {
+	defer stream.Close()
+	br := bufio.NewReader(stream)
+	line, err := client.ReadHeaderLine(br)
+	if err != nil {
+		stream.CancelRead(0)
+		return
+	}
+	f := strings.Fields(line)
+	if len(f) == 0 {
+		replyError(stream, client.StatusBadRequest, "empty request")
+		return
+	}
+	if f[0] == "auth" {
+		if len(f) != 2 || f[1] != s.srv.opts.Token {
+			replyError(stream, client.StatusUnauthorized, "invalid token")
+			return
+		}
+		s.authed.Store(true)
+		fmt.Fprintln(stream, "OK")
+		return
+	}
+	if !s.authed.Load() {
+		replyError(stream, client.StatusUnauthorized, "authentication required")
+		return
+	}
+	if s.version >= 2 {
+		for i, v := range f {
+			if f[i], err = url.PathUnescape(v); err != nil {
+				replyError(stream, client.StatusBadRequest, "invalid escape in "+v)
+				return
+			}
+		}
+	}
+	s.srv.mu.Lock()
+	s.srv.requests = append(s.srv.requests, strings.Join(f, " "))
+	s.srv.mu.Unlock()
+
+	switch f[0] {
+	case "ls":
+		err = s.srv.list(stream, f[1:])
+	case "get":
+		err = s.get(stream, f[1:])
+	case "put":
+		err = s.srv.put(stream, br, f[1:])
+	case "stat":
+		err = s.srv.stat(stream, f[1:])
+	case "hash":
+		err = s.srv.hash(stream, f[1:])
+	case "rm":
+		err = s.srv.remove(stream, f[1:])
+	case "mkdir":
+		err = s.srv.mkdir(stream, f[1:])
+	case "mv":
+		err = s.srv.rename(stream, f[1:])
+	default:
+		err = requestError{client.StatusBadRequest, "unknown command " + f[0]}
+	}
+	if err != nil {
+		// 錯誤訊息不透露 Root 的位置
+		replyError(stream, statusOf(err), strings.ReplaceAll(err.Error(), s.srv.Root+string(filepath.Separator), ""))
+	}
+}
+
+// requestError 是格式錯誤的請求，以 Code 回應。
+type requestError struct {
+	code int
+	msg  string
+}
+
+func (e requestError) Error() string { return e.msg }
+
+func statusOf(err error) int {
+	var re requestError
+	switch {
+	case errors.As(err, &re):
+		return re.code
+	case errors.Is(err, fs.ErrNotExist):
+		return client.StatusNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return client.StatusForbidden
+	case errors.Is(err, fs.ErrExist):
+		return client.StatusConflict
+	}
+	return client.StatusInternal
+}
+
+func replyError(w io.Writer, code int, msg string) {
+	fmt.Fprintf(w, "ERR %d %s\n", code, strings.ReplaceAll(msg, "\n", " "))
+}
+
+func usage(format string) error {
+	return requestError{client.StatusBadRequest, "usage: " + format}
+}
+
+// resolve 把遠端路徑轉成 Root 之下的本機路徑；.. 不會超出 Root。
+func (s *Server) resolve(p string) string {
+	return filepath.Join(s.Root, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+// entry 回傳 p 的列表項目；withHash 為 true 時一併計算檔案的 SHA-256。
+func (s *Server) entry(p string, info fs.FileInfo, withHash bool) (client.Entry, error) {
+	e := client.Entry{Name: info.Name(), Size: info.Size(), Mtime: info.ModTime().UTC(), Type: "file", Mode: fmt.Sprintf("%04o", info.Mode().Perm())}
+	if info.IsDir() {
+		e.Type, e.Size = "dir", 0
+		return e, nil
+	}
+	if withHash {
+		sum, err := fileSum(p, sha256.New())
+		if err != nil {
+			return e, err
+		}
+		e.Hash = "sha256:" + sum
+	}
+	return e, nil
+}
+
+// list 實作 `ls [-l] [cursor=<n>] [dir]`；cursor 是下一頁第一個項目的索引。
+func (s *Server) list(w io.Writer, args []string) error {
+	long := len(args) > 0 && args[0] == "-l"
+	if long {
+		args = args[1:]
+	}
+	start := 0
+	if len(args) > 0 && strings.HasPrefix(args[0], "cursor=") {
+		n, err := strconv.Atoi(strings.TrimPrefix(args[0], "cursor="))
+		if err != nil || n < 0 {
+			return requestError{client.StatusBadRequest, "invalid cursor"}
+		}
+		start, args = n, args[1:]
+	}
+	if len(args) > 1 {
+		return usage("ls [-l] [cursor=<n>] [dir]")
+	}
+	dir := s.resolve(strings.Join(args, ""))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	end := len(entries)
+	if size := s.opts.PageSize; size > 0 && start+size < end {
+		end = start + size
+	}
+	bw := bufio.NewWriter(w)
+	for _, de := range entries[min(start, len(entries)):end] {
+		if !long {
+			name := de.Name()
+			if de.IsDir() {
+				name += "/"
+			}
+			fmt.Fprintln(bw, name)
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		e, _ := s.entry(filepath.Join(dir, de.Name()), info, false)
+		data, _ := json.Marshal(e)
+		bw.Write(append(data, '\n'))
+	}
+	if end < len(entries) {
+		fmt.Fprintf(bw, "MORE %d\n", end)
+	}
+	return bw.Flush()
+}
+
+// get 實作 `get <name> [offset] [compress=<codec>:<level>] [len=<n>] [sum=<algorithm>]`。
+// 不支援壓縮，compress 被略過、以未壓縮的內容回應；雜湊一律是完整檔案的雜湊。
+func (s *session) get(stream *quic.Stream, args []string) error {
+	if len(args) == 0 {
+		return usage("get <name> [offset] [len=<n>] [sum=<algorithm>]")
+	}
+	var offset, length int64
+	alg := "sha256"
+	for i, t := range args[1:] {
+		var err error
+		switch {
+		case i == 0 && !strings.Contains(t, "="):
+			offset, err = strconv.ParseInt(t, 10, 64)
+		case strings.HasPrefix(t, "len="):
+			length, err = strconv.ParseInt(strings.TrimPrefix(t, "len="), 10, 64)
+		case strings.HasPrefix(t, "sum="):
+			alg = strings.TrimPrefix(t, "sum=")
+		}
+		if err != nil || offset < 0 || length < 0 {
+			return requestError{client.StatusBadRequest, "invalid argument " + t}
+		}
+	}
+	var h hash.Hash
+	switch alg {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return requestError{client.StatusBadRequest, "unsupported checksum " + alg}
+	}
+
+	p := s.srv.resolve(args[0])
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return requestError{client.StatusBadRequest, args[0] + " is a directory"}
+	}
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if offset > info.Size() {
+		return requestError{client.StatusBadRequest, "offset beyond end of file"}
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	var body io.Reader = f
+	if s.srv.opts.Corrupt && offset < info.Size() {
+		var b [1]byte
+		if _, err := io.ReadFull(f, b[:]); err != nil {
+			return err
+		}
+		b[0] ^= 0xff
+		body = io.MultiReader(bytes.NewReader(b[:]), f)
+	}
+	fmt.Fprintf(stream, "%d %s=%s mtime=%s mode=%04o\n", info.Size()+s.srv.opts.Missing, alg, hex.EncodeToString(h.Sum(nil)),
+		info.ModTime().UTC().Format(time.RFC3339Nano), info.Mode().Perm())
+	remaining := info.Size() - offset
+	if length > 0 {
+		remaining = min(remaining, length)
+	}
+	if n := s.srv.opts.AbortAfter; n > 0 && n < remaining {
+		if _, err := io.CopyN(stream, body, n); err == nil {
+			// 關閉連線會捨棄還沒送出的資料，先等已寫入的內容抵達用戶端
+			time.Sleep(abortDelay)
+			s.conn.CloseWithError(0, "aborted")
+		}
+		return nil
+	}
+	io.CopyN(stream, body, remaining)
+	return nil
+}
+
+// abortDelay 是 AbortAfter 送完內容後到關閉連線之間的等待時間。
+const abortDelay = 100 * time.Millisecond
+
+// put 實作 `put <name> <size> [mtime=<time>] [mode=<perm>]`：內容先寫到暫存檔，收完 size 個位元組後才改名。
+func (s *Server) put(stream *quic.Stream, br *bufio.Reader, args []string) error {
+	if len(args) < 2 {
+		return usage("put <name> <size> [mtime=<time>] [mode=<perm>]")
+	}
+	size, err := client.ParseSize(args[1])
+	if err != nil {
+		return requestError{client.StatusBadRequest, "invalid size " + args[1]}
+	}
+	if slices.ContainsFunc(args[2:], func(t string) bool { return strings.HasPrefix(t, "compress=") }) {
+		stream.CancelRead(0)
+		return requestError{client.StatusBadRequest, "compression not supported"}
+	}
+	meta := client.ParseFileMeta(args[2:])
+
+	p := s.resolve(args[0])
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, io.LimitReader(br, size))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return requestError{client.StatusBadRequest, fmt.Sprintf("short upload (%d/%d bytes)", n, size)}
+	}
+	mode := meta.Mode
+	if mode == 0 {
+		mode = 0o644
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if !meta.Mtime.IsZero() {
+		if err := os.Chtimes(tmp.Name(), meta.Mtime, meta.Mtime); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return err
+	}
+	fmt.Fprintln(stream, "OK")
+	return nil
+}
+
+// stat 實作 `stat <path>`，回應一行與 `ls -l` 相同的 JSON，檔案另外附上雜湊。
+func (s *Server) stat(w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return usage("stat <path>")
+	}
+	p := s.resolve(args[0])
+	info, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	e, err := s.entry(p, info, true)
+	if err != nil {
+		return err
+	}
+	data, _ := json.Marshal(e)
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// hash 實作 `hash <path>`，回應檔案 SHA-256 的十六進位字串。
+func (s *Server) hash(w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return usage("hash <path>")
+	}
+	sum, err := fileSum(s.resolve(args[0]), sha256.New())
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, sum)
+	return err
+}
+
+// remove 實作 `rm <path>`。測試伺服器沒有垃圾桶，直接刪除；目錄連同內容一起刪除。
+func (s *Server) remove(w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return usage("rm <path>")
+	}
+	p := s.resolve(args[0])
+	if p == s.Root {
+		return requestError{client.StatusForbidden, "cannot remove the root directory"}
+	}
+	if _, err := os.Lstat(p); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(p); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w, "OK")
+	return err
+}
+
+// mkdir 實作 `mkdir [-p] <dir>`。
+func (s *Server) mkdir(w io.Writer, args []string) error {
+	parents := len(args) > 0 && args[0] == "-p"
+	if parents {
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return usage("mkdir [-p] <dir>")
+	}
+	var err error
+	if parents {
+		err = os.MkdirAll(s.resolve(args[0]), 0o755)
+	} else {
+		err = os.Mkdir(s.resolve(args[0]), 0o755)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, "OK")
+	return err
+}
+
+// rename 實作 `mv <from> <to>`。
+func (s *Server) rename(w io.Writer, args []string) error {
+	if len(args) != 2 {
+		return usage("mv <from> <to>")
+	}
+	if err := os.Rename(s.resolve(args[0]), s.resolve(args[1])); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w, "OK")
+	return err
+}
+
+func fileSum(p string, h hash.Hash) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
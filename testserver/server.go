@@ -0,0 +1,205 @@
+// Package testserver 是在行程內執行的 data-transfer 伺服器，給端對端測試與嵌入 client 套件的程式當作測試環境：
+// 以 quic-go 在 127.0.0.1 的隨機埠上監聽，使用自簽憑證，檔案放在一個目錄中（預設為暫存目錄，Close 時刪除）。
+//
+// 它實作 auth、ls（含 -l 與分頁）、get（offset、len 與 sum）、put（mtime 與 mode）、stat、hash、
+// rm、mkdir 與 mv，並接受第 2 版協定的百分比編碼路徑；不支援壓縮、datagram 控制通道與 HTTP/3。
+//
+//	srv, err := testserver.New(testserver.Options{})
+//	defer srv.Close()
+//	c, err := srv.Dial(ctx)
+package testserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// Options 是伺服器的設定，零值即可使用。
+type Options struct {
+	// Root 是檔案所在的目錄，空字串表示建立暫存目錄並在 Close 時刪除
+	Root string
+	// Token 不為空時，連線必須先以 `auth <token>` 認證，否則請求以 ERR 401 拒絕
+	Token string
+	// PageSize 是 ls 每頁的項目數，超過時以 `MORE <cursor>` 分頁；0 表示不分頁
+	PageSize int
+	// AbortAfter 大於 0 時，每個 get 送出這麼多位元組的內容後關閉連線，模擬連線中斷（用來測試重試與續傳）
+	AbortAfter int64
+	// Missing 大於 0 時，get 宣告的大小比實際送出的內容多這麼多位元組，模擬內容提早結束的伺服器
+	Missing int64
+	// Corrupt 為 true 時，get 送出的內容第一個位元組被反轉，與宣告的雜湊不符
+	Corrupt bool
+	// Protocols 是接受的 ALPN，預設為 client.DefaultALPN
+	Protocols []string
+}
+
+// Server 是執行中的測試伺服器。
+type Server struct {
+	// Root 是伺服器提供的目錄，測試可以直接在其中建立或檢查檔案
+	Root string
+
+	opts Options
+	temp bool
+	ln   *quic.Listener
+	pool *x509.CertPool
+	done chan struct{}
+
+	mu       sync.Mutex
+	conns    map[*quic.Conn]struct{}
+	requests []string
+}
+
+// New 建立並啟動伺服器。
+func New(opts Options) (*Server, error) {
+	s := &Server{Root: opts.Root, opts: opts, done: make(chan struct{}), conns: make(map[*quic.Conn]struct{})}
+	if s.Root == "" {
+		dir, err := os.MkdirTemp("", "testserver-")
+		if err != nil {
+			return nil, err
+		}
+		s.Root, s.temp = dir, true
+	}
+	cert, err := selfSigned()
+	if err != nil {
+		s.cleanup()
+		return nil, err
+	}
+	s.pool = x509.NewCertPool()
+	s.pool.AddCert(cert.Leaf)
+	protos := opts.Protocols
+	if len(protos) == 0 {
+		protos = client.DefaultALPN
+	}
+	tlsConf := &tls.Config{Certificates: []tls.Certificate{cert}, NextProtos: slices.Clone(protos)}
+	if s.ln, err = quic.ListenAddr("127.0.0.1:0", tlsConf, &quic.Config{}); err != nil {
+		s.cleanup()
+		return nil, err
+	}
+	go s.serve()
+	return s, nil
+}
+
+// selfSigned 產生 127.0.0.1 與 localhost 的自簽憑證。
+func selfSigned() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "testserver"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// Addr 回傳伺服器的 ip:port。
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// TLSConfig 回傳信任伺服器憑證的用戶端設定；NextProtos 為空，client.Dial 會使用預設的 ALPN。
+func (s *Server) TLSConfig() *tls.Config {
+	return &tls.Config{RootCAs: s.pool}
+}
+
+// Dial 建立一條連到伺服器的連線，設定了 Token 時一併認證。
+func (s *Server) Dial(ctx context.Context) (*client.Client, error) {
+	c, err := client.Dial(ctx, s.Addr(), s.TLSConfig(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.opts.Token != "" {
+		if err := c.Authenticate(ctx, s.opts.Token); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Requests 回傳目前為止收到的請求行（解碼後的路徑，不含 auth），讓測試檢查例如續傳時送出的 offset。
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+// Close 關閉所有連線並停止監聽；Root 是暫存目錄時一併刪除。
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	<-s.done
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.CloseWithError(0, "")
+	}
+	s.mu.Unlock()
+	s.cleanup()
+	return err
+}
+
+func (s *Server) cleanup() {
+	if s.temp {
+		os.RemoveAll(s.Root)
+	}
+}
+
+func (s *Server) serve() {
+	defer close(s.done)
+	for {
+		conn, err := s.ln.Accept(context.Background())
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn *quic.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+	sess := &session{srv: s, conn: conn, version: client.ProtocolVersion(conn.ConnectionState().TLS.NegotiatedProtocol)}
+	sess.authed.Store(s.opts.Token == "")
+	for {
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		go sess.handle(stream)
+	}
+}
@@ -0,0 +1,202 @@
+package testserver_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go-client/client"
+	"go-client/testserver"
+)
+
+func start(t *testing.T, opts testserver.Options) (*testserver.Server, *client.Client) {
+	t.Helper()
+	srv, err := testserver.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	c, err := srv.Dial(testContext(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return srv, c
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestPutGetChecksum(t *testing.T) {
+	srv, c := start(t, testserver.Options{})
+	ctx := testContext(t)
+	data := bytes.Repeat([]byte("0123456789abcdef"), 40000)
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := c.Put(ctx, "dir/a b.bin", bytes.NewReader(data), client.PutOptions{Meta: client.FileMeta{Mtime: mtime, Mode: 0o600}}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(srv.Root, "dir", "a b.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(data)) || !info.ModTime().Equal(mtime) || info.Mode().Perm() != 0o600 {
+		t.Errorf("uploaded file: size %d mtime %v mode %v", info.Size(), info.ModTime(), info.Mode().Perm())
+	}
+
+	for _, alg := range client.ChecksumAlgorithms() {
+		var buf bytes.Buffer
+		n, err := c.Get(ctx, "dir/a b.bin", &buf, client.GetOptions{Checksum: alg})
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("%s: got %d bytes, want %d", alg, n, len(data))
+		}
+	}
+	if got := srv.Requests(); !slices.Contains(got, "get dir/a b.bin 0 sum=sha512") {
+		t.Errorf("requests = %q", got)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	srv, c := start(t, testserver.Options{Corrupt: true})
+	os.WriteFile(filepath.Join(srv.Root, "a.bin"), []byte("hello"), 0o644)
+	var buf bytes.Buffer
+	_, err := c.Get(testContext(t), "a.bin", &buf, client.GetOptions{})
+	var ce *client.ChecksumError
+	if !errors.As(err, &ce) {
+		t.Fatalf("err = %v, want *client.ChecksumError", err)
+	}
+	if buf.Len() != 5 || buf.Bytes()[0] != 'h'^0xff {
+		t.Errorf("body = %q", buf.Bytes())
+	}
+}
+
+func TestMissing(t *testing.T) {
+	srv, c := start(t, testserver.Options{Missing: 10})
+	os.WriteFile(filepath.Join(srv.Root, "a.bin"), []byte("hello"), 0o644)
+	d, err := c.Open(testContext(t), "a.bin", client.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if d.Size != 15 {
+		t.Errorf("Size = %d, want 15", d.Size)
+	}
+	if _, err := c.Get(testContext(t), "a.bin", new(bytes.Buffer), client.GetOptions{}); err == nil {
+		t.Error("Get of a short body succeeded")
+	}
+}
+
+func TestAbortAfter(t *testing.T) {
+	srv, c := start(t, testserver.Options{AbortAfter: 100})
+	os.WriteFile(filepath.Join(srv.Root, "a.bin"), make([]byte, 1000), 0o644)
+	os.WriteFile(filepath.Join(srv.Root, "small.bin"), make([]byte, 100), 0o644)
+	var buf bytes.Buffer
+	if _, err := c.Get(testContext(t), "small.bin", &buf, client.GetOptions{}); err != nil {
+		t.Fatalf("file that fits in AbortAfter: %v", err)
+	}
+	buf.Reset()
+	if _, err := c.Get(testContext(t), "a.bin", &buf, client.GetOptions{}); err == nil {
+		t.Fatal("Get succeeded despite AbortAfter")
+	}
+	if buf.Len() != 100 {
+		t.Errorf("received %d bytes before the abort, want 100", buf.Len())
+	}
+	select {
+	case <-c.Conn().Context().Done():
+	case <-time.After(5 * time.Second):
+		t.Error("connection still open after the abort")
+	}
+
+	// 新的連線從中斷處續傳
+	c2, err := srv.Dial(testContext(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	if _, err := c2.Get(testContext(t), "a.bin", &buf, client.GetOptions{Offset: 100, Length: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Requests(); got[len(got)-1] != "get a.bin 100 len=100" {
+		t.Errorf("last request = %q", got[len(got)-1])
+	}
+}
+
+func TestToken(t *testing.T) {
+	srv, c := start(t, testserver.Options{Token: "secret"})
+	ctx := testContext(t)
+	if _, err := c.List(ctx, ""); err != nil {
+		t.Fatalf("authenticated List: %v", err)
+	}
+	anon, err := client.Dial(ctx, srv.Addr(), srv.TLSConfig(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer anon.Close()
+	if _, err := anon.List(ctx, ""); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("unauthenticated List: err = %v, want ErrUnauthorized", err)
+	}
+	if err := anon.Authenticate(ctx, "wrong"); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("wrong token: err = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv, c := start(t, testserver.Options{})
+	ctx := testContext(t)
+	os.Mkdir(filepath.Join(srv.Root, "d"), 0o755)
+
+	_, err := c.Get(ctx, "missing.bin", new(bytes.Buffer), client.GetOptions{})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("get missing: err = %v, want 404", err)
+	}
+	if err := c.Mkdir(ctx, "d", false); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("mkdir existing: err = %v, want 409", err)
+	}
+	if err := c.Remove(ctx, "/"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("rm root: err = %v, want 403", err)
+	}
+	// 錯誤訊息不透露伺服器的目錄
+	if _, err := c.Stat(ctx, "../../missing"); err == nil || strings.Contains(err.Error(), srv.Root) {
+		t.Errorf("stat outside root: err = %v", err)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	srv, c := start(t, testserver.Options{PageSize: 2})
+	names := []string{"a", "b", "c", "d", "e"}
+	for _, n := range names {
+		os.WriteFile(filepath.Join(srv.Root, n), []byte(n), 0o644)
+	}
+	entries, err := c.List(testContext(t), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name)
+	}
+	if !slices.Equal(got, names) {
+		t.Errorf("List = %q, want %q", got, names)
+	}
+	var pages int
+	for _, r := range srv.Requests() {
+		if strings.HasPrefix(r, "ls") {
+			pages++
+		}
+	}
+	if pages != 3 {
+		t.Errorf("%d ls requests, want 3: %q", pages, srv.Requests())
+	}
+}
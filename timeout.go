@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// timeoutOptions 是 --connect-timeout 與 --idle-timeout；0 表示使用 quic-go 的預設值（交握 5 秒、閒置 30 秒）。
+type timeoutOptions struct {
+	connect time.Duration
+	idle    time.Duration
+}
+
+var clientTimeouts timeoutOptions
+
+// apply 把逾時設定寫入 conf，並回傳套用連線逾時的 dial context。
+func (t timeoutOptions) apply(ctx context.Context, conf *quic.Config) (context.Context, context.CancelFunc) {
+	if t.idle > 0 {
+		conf.MaxIdleTimeout = t.idle
+	}
+	if t.connect <= 0 {
+		return ctx, func() {}
+	}
+	conf.HandshakeIdleTimeout = t.connect
+	return context.WithTimeout(ctx, t.connect)
+}
+
+// explainDialTimeout 把交握逾時轉成說明是哪個伺服器、等了多久的錯誤。
+func (t timeoutOptions) explainDialTimeout(server string, err error) error {
+	if errors.As(err, new(*dialAllTimeoutError)) {
+		return err
+	}
+	if !errors.Is(err, context.DeadlineExceeded) && !errors.As(err, new(*quic.HandshakeTimeoutError)) {
+		return err
+	}
+	limit := t.connect
+	if limit <= 0 {
+		limit = 5 * time.Second
+	}
+	return fmt.Errorf("無法在 %v 內連線到 %s，伺服器沒有回應（可用 --connect-timeout 調整）: %w", limit, server, err)
+}
+
+// watchIdleTimeout 在連線因閒置逾時而中斷時印出說明；此時進行中的操作會各自回傳錯誤。
+func (t timeoutOptions) watchIdleTimeout(session *quic.Conn, server string) {
+	context.AfterFunc(session.Context(), func() {
+		if errors.As(context.Cause(session.Context()), new(*quic.IdleTimeoutError)) {
+			limit := t.idle
+			if limit <= 0 {
+				limit = 30 * time.Second
+			}
+			slog.Warn("伺服器沒有回應，連線已中斷（可用 --idle-timeout 調整）", "server", server, "idle", limit)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"go-client/client"
+)
+
+// tlsOptions 是驗證伺服器憑證的設定，由 --ca、--insecure、--pin 與 --tofu 決定。
+type tlsOptions struct {
+	caFile   string
+	insecure bool     // 不驗證憑證鏈與主機名稱（--pin 仍然生效）
+	pins     [][]byte // 伺服器公鑰（SubjectPublicKeyInfo）的 SHA-256，符合任一個即可
+	certFile string   // mTLS 用戶端憑證（PEM）
+	keyFile  string
+	proto    string   // --proto：data（data-transfer ALPN）或 h3
+	alpn     []string // --alpn：data 協定宣告的 ALPN，依偏好排列；空的時候為 client.DefaultALPN
+	// tofu 以 known_hosts 取代 CA 驗證（trust on first use）
+	tofu bool
+}
+
+// clientTLS 由 run 依命令列設定，dial 以它建立 tls.Config。
+var clientTLS tlsOptions
+
+// http3Commands 是 --proto h3 時可用的指令：HTTP/3 伺服器只提供 GET 與 PUT。
+var http3Commands = []string{"get", "put"}
+
+var errPinMismatch = errors.New("伺服器公鑰與 --pin 不符")
+
+// addPin 解析 --pin：十六進位（可含冒號）或 curl 風格的 sha256//<base64>。
+func (o *tlsOptions) addPin(s string) error {
+	var pin []byte
+	var err error
+	if b64, ok := strings.CutPrefix(s, "sha256//"); ok {
+		pin, err = base64.StdEncoding.DecodeString(b64)
+	} else {
+		pin, err = hex.DecodeString(strings.ReplaceAll(s, ":", ""))
+	}
+	if err != nil || len(pin) != sha256.Size {
+		return fmt.Errorf("無效的 --pin %q，需為 SHA-256 十六進位值或 sha256//<base64>", s)
+	}
+	o.pins = append(o.pins, pin)
+	return nil
+}
+
+// config 回傳連線到 server 用的 tls.Config。
+func (o tlsOptions) config(server string) (*tls.Config, error) {
+	conf := &tls.Config{NextProtos: client.DefaultALPN, InsecureSkipVerify: o.insecure}
+	switch {
+	case o.proto == "h3":
+		conf.NextProtos = []string{client.ALPNHTTP3}
+	case len(o.alpn) > 0:
+		conf.NextProtos = o.alpn
+	}
+	if host, _, err := net.SplitHostPort(server); err == nil {
+		conf.ServerName = host
+	}
+	if o.caFile != "" {
+		data, err := os.ReadFile(o.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("無法讀取 --ca: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("--ca %s 中沒有 PEM 格式的憑證", o.caFile)
+		}
+		conf.RootCAs = pool
+	}
+	if o.certFile != "" || o.keyFile != "" {
+		cert, err := o.loadClientCert()
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	var checks []func(tls.ConnectionState) error
+	if o.tofu {
+		conf.InsecureSkipVerify = true
+		checks = append(checks, verifyKnownHost(server))
+	}
+	if len(o.pins) > 0 {
+		checks = append(checks, func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errPinMismatch
+			}
+			sum := sha256.Sum256(cs.PeerCertificates[0].RawSubjectPublicKeyInfo)
+			for _, pin := range o.pins {
+				if bytes.Equal(pin, sum[:]) {
+					return nil
+				}
+			}
+			return fmt.Errorf("%w（伺服器為 sha256//%s）", errPinMismatch, base64.StdEncoding.EncodeToString(sum[:]))
+		})
+	}
+	if len(checks) > 0 {
+		// VerifyConnection 在 InsecureSkipVerify 時也會執行
+		conf.VerifyConnection = func(cs tls.ConnectionState) error {
+			for _, check := range checks {
+				if err := check(cs); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return conf, nil
+}
+
+// loadClientCert 載入 --cert 與 --key，分別檢查檔案是否存在與格式，讓錯誤訊息指出是哪個檔案的問題。
+func (o tlsOptions) loadClientCert() (tls.Certificate, error) {
+	if o.certFile == "" || o.keyFile == "" {
+		return tls.Certificate{}, errors.New("--cert 與 --key 必須同時指定")
+	}
+	certPEM, err := os.ReadFile(o.certFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("無法讀取 --cert: %v", err)
+	}
+	keyPEM, err := os.ReadFile(o.keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("無法讀取 --key: %v", err)
+	}
+	if block, _ := pem.Decode(certPEM); block == nil || block.Type != "CERTIFICATE" {
+		return tls.Certificate{}, fmt.Errorf("--cert %s 不是 PEM 格式的憑證", o.certFile)
+	}
+	if block, _ := pem.Decode(keyPEM); block == nil || !strings.Contains(block.Type, "PRIVATE KEY") {
+		return tls.Certificate{}, fmt.Errorf("--key %s 不是 PEM 格式的私鑰", o.keyFile)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("無法載入用戶端憑證 %s 與私鑰 %s: %v", o.certFile, o.keyFile, err)
+	}
+	return cert, nil
+}
+
+// explainTLSError 在憑證驗證失敗時附上可用的選項提示。
+func explainTLSError(err error) error {
+	var unknown x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	if errors.As(err, &unknown) || errors.As(err, &hostname) || errors.As(err, &invalid) ||
+		strings.Contains(err.Error(), "certificate") {
+		return fmt.Errorf("%w\n無法驗證伺服器憑證：可用 --ca 指定簽發的 CA、--pin 固定公鑰，或明確加上 --insecure 略過驗證", err)
+	}
+	return err
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/logging"
+	"github.com/quic-go/quic-go/qlog"
+
+	"go-client/client"
+)
+
+// traceOptions 是 --qlog 與 --verbose：前者把每條連線的 qlog 寫到目錄中，
+// 後者在 stderr 記錄交握資訊與 stream 的開啟、結束。
+type traceOptions struct {
+	qlogDir string
+	verbose bool
+}
+
+var clientTrace traceOptions
+
+var (
+	dialedMu sync.Mutex
+	dialed   []*quic.Conn   // dial 建立的連線，結束前由 closeConnections 關閉
+	tracing  sync.WaitGroup // 尚未結束的連線追蹤，結束時才會寫出 qlog
+)
+
+// trackConnection 登記 dial 建立的連線。
+func trackConnection(session *quic.Conn) {
+	dialedMu.Lock()
+	dialed = append(dialed, session)
+	dialedMu.Unlock()
+}
+
+// closeConnections 在行程結束前正常關閉所有連線，並等待 qlog 寫完（最多一秒）。
+func closeConnections() {
+	dialedMu.Lock()
+	conns := dialed
+	dialed = nil
+	dialedMu.Unlock()
+	for _, c := range conns {
+		c.CloseWithError(0, "")
+	}
+	done := make(chan struct{})
+	go func() {
+		tracing.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+	}
+}
+
+// tracer 組合連線統計、qlog 與 --verbose 的 stream 記錄。
+func (t traceOptions) tracer(metrics *connMetrics) func(context.Context, logging.Perspective, logging.ConnectionID) *logging.ConnectionTracer {
+	return func(ctx context.Context, p logging.Perspective, odcid logging.ConnectionID) *logging.ConnectionTracer {
+		tracers := []*logging.ConnectionTracer{metrics.tracer(ctx, p, odcid)}
+		if t.qlogDir != "" {
+			if tr, err := newQlogTracer(t.qlogDir, p, odcid); err != nil {
+				slog.Warn("無法建立 qlog", "err", err)
+			} else {
+				tracers = append(tracers, tr)
+			}
+		}
+		if t.verbose {
+			tracers = append(tracers, verboseTracer(metrics))
+		}
+		tracing.Add(1)
+		tracers = append(tracers, &logging.ConnectionTracer{Close: tracing.Done})
+		return logging.NewMultiplexedConnectionTracer(tracers...)
+	}
+}
+
+// newQlogTracer 把連線的 qlog 寫到 dir/<odcid>_client.sqlog，可用 qvis 等工具檢視。
+func newQlogTracer(dir string, p logging.Perspective, odcid logging.ConnectionID) (*logging.ConnectionTracer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s_client.sqlog", odcid))
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return qlog.NewConnectionTracer(&bufferedFile{Writer: bufio.NewWriter(f), f: f}, p, odcid), nil
+}
+
+// bufferedFile 在 Close 時先寫出緩衝區再關閉檔案。
+type bufferedFile struct {
+	*bufio.Writer
+	f *os.File
+}
+
+func (b *bufferedFile) Close() error {
+	if err := b.Flush(); err != nil {
+		b.f.Close()
+		return err
+	}
+	return b.f.Close()
+}
+
+// verboseTracer 從收送的 STREAM、RESET_STREAM 與 STOP_SENDING frame 記錄 stream 的開啟與結束，
+// 連線關閉時記錄 RTT 與封包統計。
+func verboseTracer(metrics *connMetrics) *logging.ConnectionTracer {
+	var mu sync.Mutex
+	seen := make(map[logging.StreamID]bool)
+	frames := func(dir string, fs []logging.Frame) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, f := range fs {
+			switch f := f.(type) {
+			case *logging.StreamFrame:
+				if !seen[f.StreamID] {
+					seen[f.StreamID] = true
+					slog.Debug(dir+"第一個 frame", "stream", f.StreamID)
+				}
+				if f.Fin {
+					slog.Debug(dir+" FIN", "stream", f.StreamID, "bytes", int64(f.Offset)+int64(f.Length))
+				}
+			case *logging.ResetStreamFrame:
+				slog.Debug(dir+" RESET_STREAM", "stream", f.StreamID, "code", f.ErrorCode, "bytes", f.FinalSize)
+			case *logging.StopSendingFrame:
+				slog.Debug(dir+" STOP_SENDING", "stream", f.StreamID, "code", f.ErrorCode)
+			}
+		}
+	}
+	return &logging.ConnectionTracer{
+		// 0-RTT 的 stream 資料在 long header 封包中
+		SentLongHeaderPacket: func(_ *logging.ExtendedHeader, _ logging.ByteCount, _ logging.ECN, _ *logging.AckFrame, fs []logging.Frame) {
+			frames("送出", fs)
+		},
+		SentShortHeaderPacket: func(_ *logging.ShortHeader, _ logging.ByteCount, _ logging.ECN, _ *logging.AckFrame, fs []logging.Frame) {
+			frames("送出", fs)
+		},
+		ReceivedShortHeaderPacket: func(_ *logging.ShortHeader, _ logging.ByteCount, _ logging.ECN, fs []logging.Frame) {
+			frames("收到", fs)
+		},
+		ClosedConnection: func(err error) {
+			s := metrics.Snapshot()
+			slog.Debug("連線結束", "reason", err, "rtt", s.SmoothedRTT.Round(time.Microsecond),
+				"packets_sent", s.PacketsSent, "bytes_sent", s.BytesSent,
+				"packets_received", s.PacketsReceived, "bytes_received", s.BytesReceived, "packets_lost", s.PacketsLost)
+		},
+	}
+}
+
+// logHandshake 在交握完成後記錄 QUIC 版本、ALPN、TLS 參數與 RTT。
+func (t traceOptions) logHandshake(session *quic.Conn, server string, start time.Time) {
+	if !t.verbose {
+		return
+	}
+	go func() {
+		select {
+		case <-session.HandshakeComplete():
+		case <-session.Context().Done():
+			return
+		}
+		state := session.ConnectionState()
+		slog.Debug("已連線", "server", server, "addr", session.RemoteAddr(), "handshake", time.Since(start).Round(time.Microsecond),
+			"quic", state.Version, "alpn", state.TLS.NegotiatedProtocol, "protocol", client.ProtocolVersion(state.TLS.NegotiatedProtocol), "tls", tls.VersionName(state.TLS.Version),
+			"cipher", tls.CipherSuiteName(state.TLS.CipherSuite), "resumed", state.TLS.DidResume, "0rtt", state.Used0RTT)
+		if m := metricsOf(session); m != nil {
+			slog.Debug("初始 RTT", "rtt", m.Snapshot().SmoothedRTT.Round(time.Microsecond))
+		}
+	}()
+}
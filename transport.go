@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/quic-go/quic-go"
+)
+
+// quic-go 預設的最大接收視窗。頻寬延遲積（BDP）大的路徑上，例如 1 Gbit/s、100ms RTT
+// 需要約 12 MiB 的視窗，預設值會限制吞吐量。
+const (
+	defaultMaxStreamWindow = 6 << 20
+	defaultMaxConnWindow   = 15 << 20
+)
+
+// transportOptions 是調整 quic.Config 的旗標，0 表示使用 quic-go 的預設值。
+// quic-go 沒有開放選擇擁塞控制演算法，固定使用 Cubic。
+type transportOptions struct {
+	streamWindow    uint64 // 每個 stream 的初始接收視窗
+	maxStreamWindow uint64 // 自動調整後的上限
+	connWindow      uint64
+	maxConnWindow   uint64
+	incomingStreams int64 // 伺服器可以同時開啟的 stream 數，-1 表示不允許
+	datagrams       bool
+}
+
+var clientTransport transportOptions
+
+// sizeFlag 回傳把大小（可加單位，例如 16M）寫入 dst 的旗標函式。
+func sizeFlag(dst *uint64) func(string) error {
+	return func(s string) error {
+		n, err := parseSize(s)
+		if err != nil {
+			return err
+		}
+		*dst = uint64(n)
+		return nil
+	}
+}
+
+// validate 檢查初始視窗不超過上限；只指定初始視窗且超過預設上限時，上限隨之提高。
+func (o *transportOptions) validate() error {
+	check := func(name string, initial uint64, limit *uint64, def uint64) error {
+		switch {
+		case *limit == 0 && initial > def:
+			*limit = initial
+		case *limit != 0 && initial > *limit:
+			return fmt.Errorf("--%s 不能大於 --max-%s", name, name)
+		}
+		return nil
+	}
+	if err := check("stream-window", o.streamWindow, &o.maxStreamWindow, defaultMaxStreamWindow); err != nil {
+		return err
+	}
+	return check("conn-window", o.connWindow, &o.maxConnWindow, defaultMaxConnWindow)
+}
+
+// apply 把有設定的值寫入 conf。
+func (o *transportOptions) apply(conf *quic.Config) {
+	if o.streamWindow > 0 {
+		conf.InitialStreamReceiveWindow = o.streamWindow
+	}
+	if o.maxStreamWindow > 0 {
+		conf.MaxStreamReceiveWindow = o.maxStreamWindow
+	}
+	if o.connWindow > 0 {
+		conf.InitialConnectionReceiveWindow = o.connWindow
+	}
+	if o.maxConnWindow > 0 {
+		conf.MaxConnectionReceiveWindow = o.maxConnWindow
+	}
+	if o.incomingStreams != 0 {
+		conf.MaxIncomingStreams = o.incomingStreams
+	}
+	if o.datagrams {
+		conf.EnableDatagrams = true
+	}
+}
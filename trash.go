@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// trashEntry 是伺服器垃圾桶中的一個項目；保留期限過後伺服器會永久刪除。
+type trashEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Deleted time.Time `json:"deleted"`
+	Expires time.Time `json:"expires"`
+}
+
+// runRm 送出 `rm <path>`：伺服器把檔案移到垃圾桶，保留期限內可用 restore 還原。
+// dryRun 時只確認路徑存在並列出，不刪除。
+func runRm(ctx context.Context, session *quic.Conn, args []string, dryRun bool) error {
+	if len(args) == 0 {
+		return errors.New("用法: data_cli <ip:port> rm <path>...")
+	}
+	if dryRun {
+		// 確認每個路徑都存在，與實際執行時一樣遇到第一個錯誤就停止
+		for _, p := range args {
+			if _, err := statRemote(ctx, session, p); err != nil {
+				return fmt.Errorf("無法刪除 %s: %w", p, err)
+			}
+			dryRunAction{Action: "delete", Remote: p}.print()
+		}
+		con.Printf("試執行: 會把 %d 個路徑移到垃圾桶\n", len(args))
+		return nil
+	}
+	for _, p := range args {
+		if err := client.New(session).Remove(ctx, p); err != nil {
+			return fmt.Errorf("無法刪除 %s: %w", p, err)
+		}
+		con.Println("已移到垃圾桶:", p)
+	}
+	return nil
+}
+
+// runRestore 送出 `restore <path>` 把垃圾桶中的檔案放回原位置。
+func runRestore(ctx context.Context, session *quic.Conn, args []string) error {
+	if len(args) == 0 {
+		return errors.New("用法: data_cli <ip:port> restore <path>...")
+	}
+	c := client.New(session)
+	for _, p := range args {
+		if _, err := c.Request(ctx, "restore "+c.EncodePath(p)); err != nil {
+			return fmt.Errorf("無法還原 %s: %w", p, err)
+		}
+		con.Println("已還原:", p)
+	}
+	return nil
+}
+
+// runTrash 列出垃圾桶內容；伺服器對 `trash` 每行回傳一個 JSON 物件。
+func runTrash(ctx context.Context, session *quic.Conn) error {
+	stream, err := session.OpenStreamSync(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	fmt.Fprintln(stream, "trash")
+
+	w := tabwriter.NewWriter(con.Text(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSIZE\tDELETED\tEXPIRES")
+	r := bufio.NewReader(stream)
+	for {
+		line, err := client.ReadHeaderLine(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := client.CheckServerError(line); err != nil {
+			return err
+		}
+		var e trashEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return fmt.Errorf("無效的 trash 回應 %q", client.Snippet(line))
+		}
+		con.Result(e)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Name, humanSize(e.Size), e.Deleted.Local().Format(time.DateTime), e.Expires.Local().Format(time.DateTime))
+	}
+	return w.Flush()
+}
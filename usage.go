@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// usageFlushInterval 是把累計的用量寫入 usage.jsonl 的間隔；usageCompactSize 是檔案超過後
+// 合併成每天一行的大小，usageKeepDays 是合併時保留的天數。
+const (
+	usageFlushInterval = 10 * time.Second
+	usageCompactSize   = 1 << 20
+	usageKeepDays      = 400
+)
+
+// usageRecord 是 usage.jsonl 的一行：某天（本地時間）經由某個伺服器或 profile 傳輸的位元組數。
+// 同一天可以有多行，讀取時加總；多個行程只會附加，不會互相覆寫。
+type usageRecord struct {
+	Day   string `json:"day"` // 2006-01-02
+	Key   string `json:"key"`
+	Bytes int64  `json:"bytes"`
+}
+
+func usagePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "usage.jsonl"), nil
+}
+
+// readUsage 讀取所有用量紀錄，檔案不存在時回傳空的結果。
+func readUsage() ([]usageRecord, error) {
+	path, err := usagePath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []usageRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r usageRecord
+		if json.Unmarshal(scanner.Bytes(), &r) == nil {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// appendUsage 把一筆紀錄附加到 usage.jsonl。
+func appendUsage(r usageRecord) error {
+	path, err := usagePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// compactUsage 在 usage.jsonl 超過 usageCompactSize 時把紀錄合併成每個 key 每天一行，
+// 並丟掉 usageKeepDays 天以前的紀錄。合併期間其他行程附加的紀錄可能遺失，只影響統計的精確度。
+func compactUsage() error {
+	path, err := usagePath()
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() < usageCompactSize {
+		return nil
+	}
+	records, err := readUsage()
+	if err != nil {
+		return err
+	}
+	oldest := time.Now().AddDate(0, 0, -usageKeepDays).Format(time.DateOnly)
+	type dayKey struct{ day, key string }
+	sums := make(map[dayKey]int64)
+	for _, r := range records {
+		if r.Day >= oldest {
+			sums[dayKey{r.Day, r.Key}] += r.Bytes
+		}
+	}
+	keys := slices.SortedFunc(maps.Keys(sums), func(a, b dayKey) int {
+		return cmp.Or(strings.Compare(a.day, b.day), strings.Compare(a.key, b.key))
+	})
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, k := range keys {
+		if err := enc.Encode(usageRecord{Day: k.day, Key: k.key, Bytes: sums[k]}); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// quotaError 表示本期的流量配額（--quota）已經用完，結束碼為 exitQuota。
+type quotaError struct {
+	key         string
+	used, quota int64
+	period      string
+}
+
+func (e *quotaError) Error() string {
+	return fmt.Sprintf("%s %s的流量已達配額 %s（已用 %s）", e.key, periodLabel(e.period), humanSize(e.quota), humanSize(e.used))
+}
+
+func periodLabel(period string) string {
+	if period == "day" {
+		return "今天"
+	}
+	return "本月"
+}
+
+// usageMeter 累計經由一個伺服器（或 profile）傳輸的內容位元組數，定期寫入 usage.jsonl。
+// 設定 --quota 時，本期（日或月，本地時間）的用量達到上限後，依 --quota-action 暫停到下一期開始，
+// 或以 quotaError 中止傳輸。用量包含其他行程先前記錄的量，但不含它們尚未寫入的部分。
+type usageMeter struct {
+	key    string
+	quota  int64  // 0 表示只記錄
+	period string // day | month
+	pause  bool
+
+	mu      sync.Mutex
+	day     string // pending 所屬的日期
+	current string // 目前的期間，例如 2026-10-15 或 2026-10
+	used    int64  // 本期已用
+	pending int64  // 尚未寫入檔案的量
+	flushed time.Time
+}
+
+func newUsageMeter(key string, quota int64, period string, pause bool) (*usageMeter, error) {
+	if period != "day" && period != "month" {
+		return nil, fmt.Errorf("無效的 --quota-period %q，可用 day 或 month", period)
+	}
+	if err := compactUsage(); err != nil {
+		slog.Warn("無法整理用量紀錄", "err", err)
+	}
+	m := &usageMeter{key: key, quota: quota, period: period, pause: pause, flushed: time.Now()}
+	m.roll(time.Now())
+	return m, nil
+}
+
+// periodOf 回傳 t 所在的期間。day 的期間是日期本身，month 的期間是日期的前綴。
+func (m *usageMeter) periodOf(t time.Time) string {
+	if m.period == "day" {
+		return t.Format(time.DateOnly)
+	}
+	return t.Format("2006-01")
+}
+
+// next 回傳 t 之後下一期開始的時間。
+func (m *usageMeter) next(t time.Time) time.Time {
+	y, mo, d := t.Date()
+	if m.period == "day" {
+		return time.Date(y, mo, d+1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(y, mo+1, 1, 0, 0, 0, 0, t.Location())
+}
+
+// roll 在日期或期間改變時寫出累計的用量，並從檔案重新計算新一期的用量。呼叫時須持有 mu。
+func (m *usageMeter) roll(now time.Time) {
+	if day := now.Format(time.DateOnly); day != m.day {
+		m.flush(now)
+		m.day = day
+	}
+	current := m.periodOf(now)
+	if current == m.current {
+		return
+	}
+	m.current, m.used = current, 0
+	records, err := readUsage()
+	if err != nil {
+		slog.Warn("無法讀取用量紀錄", "err", err)
+	}
+	for _, r := range records {
+		if r.Key == m.key && strings.HasPrefix(r.Day, current) {
+			m.used += r.Bytes
+		}
+	}
+}
+
+// flush 把尚未寫入的用量附加到 usage.jsonl。呼叫時須持有 mu。
+func (m *usageMeter) flush(now time.Time) {
+	m.flushed = now
+	if m.pending == 0 {
+		return
+	}
+	if err := appendUsage(usageRecord{Day: m.day, Key: m.key, Bytes: m.pending}); err != nil {
+		slog.Warn("無法記錄用量", "err", err)
+		return
+	}
+	m.pending = 0
+}
+
+// wait 在讀取前呼叫：本期的配額已經用完時暫停到下一期開始（中斷時返回），或回傳 quotaError。
+func (m *usageMeter) wait() error {
+	if m == nil || m.quota <= 0 {
+		return nil
+	}
+	for {
+		m.mu.Lock()
+		now := time.Now()
+		m.roll(now)
+		used := m.used
+		m.mu.Unlock()
+		if used < m.quota {
+			return nil
+		}
+		err := &quotaError{key: m.key, used: used, quota: m.quota, period: m.period}
+		if !m.pause {
+			return err
+		}
+		next := m.next(now)
+		slog.Warn(err.Error()+"，暫停到下一期", "until", next.Format(time.DateTime))
+		con.Event(transferEvent{Event: "quota", File: m.key})
+		ctx := signalContext()
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// add 記錄讀取的 n 個位元組。
+func (m *usageMeter) add(n int) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.roll(now)
+	m.used += int64(n)
+	m.pending += int64(n)
+	if now.Sub(m.flushed) >= usageFlushInterval {
+		m.flush(now)
+	}
+}
+
+// Close 寫出尚未記錄的用量。
+func (m *usageMeter) Close() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flush(time.Now())
+}
+
+// usageSummary 是 `usage` 列出的一列。
+type usageSummary struct {
+	Key   string `json:"key"`
+	Today int64  `json:"today"`
+	Month int64  `json:"month"`
+	Total int64  `json:"total"` // 保留的紀錄中的總量
+}
+
+// runUsage 列出各伺服器與 profile 今天、本月與全部保留紀錄中的用量，key 不為空時只列出該 key。
+func runUsage(key string) error {
+	records, err := readUsage()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	today, month := now.Format(time.DateOnly), now.Format("2006-01")
+	sums := make(map[string]*usageSummary)
+	var keys []string
+	for _, r := range records {
+		if key != "" && r.Key != key {
+			continue
+		}
+		s := sums[r.Key]
+		if s == nil {
+			s = &usageSummary{Key: r.Key}
+			sums[r.Key] = s
+			keys = append(keys, r.Key)
+		}
+		s.Total += r.Bytes
+		if r.Day == today {
+			s.Today += r.Bytes
+		}
+		if strings.HasPrefix(r.Day, month) {
+			s.Month += r.Bytes
+		}
+	}
+	if key != "" && len(keys) == 0 {
+		return fmt.Errorf("沒有 %s 的用量紀錄", key)
+	}
+	slices.Sort(keys)
+	w := tabwriter.NewWriter(con.Text(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tTODAY\tMONTH\tTOTAL")
+	for _, k := range keys {
+		s := sums[k]
+		con.Result(s)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Key, humanSize(s.Today), humanSize(s.Month), humanSize(s.Total))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-client/client"
+)
+
+// runVerify 依 SHA256SUMS 格式的 manifest 檢查本機檔案，不需連線到伺服器。
+// 路徑相對於 manifest 所在目錄。
+func runVerify(manifestPath string) error {
+	f, err := os.Open(manifestPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	base := filepath.Dir(manifestPath)
+
+	var missing, modified, ok int
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		want, name, found := strings.Cut(text, " ")
+		name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*") // "*" 為二進位模式標記
+		if !found || len(want) != sha256.Size*2 || name == "" {
+			return fmt.Errorf("%s:%d: 格式錯誤", manifestPath, line)
+		}
+
+		got, err := fileSHA256(filepath.Join(base, filepath.FromSlash(name)))
+		status := "ok"
+		switch {
+		case os.IsNotExist(err):
+			missing++
+			status = "missing"
+		case err != nil:
+			return err
+		case !strings.EqualFold(got, want):
+			modified++
+			status = "modified"
+		default:
+			ok++
+		}
+		con.Printf("%-8s %s\n", strings.ToUpper(status), name)
+		con.Result(struct {
+			Path   string `json:"path"`
+			Status string `json:"status"`
+		}{name, status})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if missing+modified > 0 {
+		return fmt.Errorf("%d 個檔案遺失，%d 個檔案不符（%d 個正確）", missing, modified, ok)
+	}
+	return nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// quarantine 把校驗失敗的下載暫存檔改名為 <local>.corrupt，避免損毀的內容被當成完整檔案使用。
+func quarantine(out *os.File, local string, err error) error {
+	out.Close()
+	removeState(local)
+	if rerr := os.Rename(out.Name(), local+".corrupt"); rerr != nil {
+		return fmt.Errorf("%s: %w", local, err)
+	}
+	return fmt.Errorf("%s: %w（已另存為 %s.corrupt）", local, err, local)
+}
+
+// verifyFile 以伺服器提供的雜湊檢查已下載的檔案；sha256sum 是已算好的 SHA-256，演算法相同時不必重讀檔案。
+func verifyFile(path, sha256sum string, c *client.Checksum) error {
+	if c.Algorithm == "sha256" {
+		if sha256sum != c.Sum {
+			return &client.ChecksumError{Algorithm: c.Algorithm, Want: c.Sum, Got: sha256sum}
+		}
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := c.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	return c.Verify(h)
+}
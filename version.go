@@ -0,0 +1,67 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+
+	"go-client/client"
+)
+
+// version 與 commit 可在建置時以 -ldflags "-X main.version=v1.2.3 -X main.commit=abc123" 指定。
+var (
+	version = "dev"
+	commit  = ""
+)
+
+type versionInfo struct {
+	Version       string   `json:"version"`
+	Commit        string   `json:"commit"`
+	GoVersion     string   `json:"go_version"`
+	QuicGoVersion string   `json:"quic_go_version"`
+	ALPN          []string `json:"alpn"`
+	QUICVersions  []string `json:"quic_versions"`
+}
+
+func buildVersionInfo() versionInfo {
+	info := versionInfo{
+		Version:       version,
+		Commit:        commit,
+		GoVersion:     runtime.Version(),
+		QuicGoVersion: "unknown",
+		ALPN:          append(slices.Clone(client.DefaultALPN), client.ALPNHTTP3),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range bi.Deps {
+			if dep.Path == "github.com/quic-go/quic-go" {
+				info.QuicGoVersion = dep.Version
+			}
+		}
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" && info.Commit == "" {
+				info.Commit = s.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	for _, v := range quic.SupportedVersions() {
+		info.QUICVersions = append(info.QUICVersions, v.String())
+	}
+	return info
+}
+
+func runVersion() error {
+	info := buildVersionInfo()
+	con.Printf("data_cli %s (commit %s)\n", info.Version, info.Commit)
+	con.Printf("go:      %s\n", info.GoVersion)
+	con.Printf("quic-go: %s\n", info.QuicGoVersion)
+	con.Printf("ALPN:    %s\n", strings.Join(info.ALPN, ", "))
+	con.Printf("QUIC:    %s\n", strings.Join(info.QUICVersions, ", "))
+	con.Result(info)
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// enableVT 在 Windows 以外的終端機上不需要設定。
+func enableVT(f *os.File) bool { return true }
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-client/client"
+)
+
+// watchState 是 watch 的狀態檔：已經下載過的檔案與它們當時在遠端的大小、修改時間與雜湊。
+// 重新啟動時只下載狀態檔中沒有或已變更的檔案。
+type watchState struct {
+	Server string                 `json:"server"`
+	Remote string                 `json:"remote"`
+	Local  string                 `json:"local"`
+	Files  map[string]watchedFile `json:"files"` // 相對於 Remote 的路徑
+	path   string
+}
+
+type watchedFile struct {
+	Size  int64     `json:"size"`
+	Mtime time.Time `json:"mtime"`
+	Hash  string    `json:"hash,omitempty"`
+}
+
+func (f watchedFile) matches(e client.Entry) bool {
+	if f.Size != e.Size {
+		return false
+	}
+	if f.Hash != "" && e.Hash != "" {
+		return f.Hash == e.Hash
+	}
+	return f.Mtime.Truncate(time.Second).Equal(e.Mtime.Truncate(time.Second))
+}
+
+// loadWatchState 讀取 server、remote 與 local 這組設定的狀態檔，不存在時回傳空的狀態。
+func loadWatchState(server, remote, local string) (*watchState, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(server + "\x00" + remote + "\x00" + local))
+	st := &watchState{Server: server, Remote: remote, Local: local, Files: make(map[string]watchedFile),
+		path: filepath.Join(dir, "watch", hex.EncodeToString(sum[:8])+".json")}
+	data, err := os.ReadFile(st.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("watch 狀態檔 %s 已損毀: %v", st.path, err)
+	}
+	if st.Files == nil {
+		st.Files = make(map[string]watchedFile)
+	}
+	return st, nil
+}
+
+func (st *watchState) save() error {
+	if err := os.MkdirAll(filepath.Dir(st.path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := st.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, st.path)
+}
+
+// runWatch 實作 `watch [-i interval] [-match pattern] [-j N] <remotedir> [localdir]`：定期列出遠端目錄，
+// 下載新增或變更、且檔名符合 pattern 的檔案。已下載的檔案記在狀態檔中，重新啟動後不會重新下載；
+// 內容（雜湊）與已下載的檔案相同時直接從本機複製。連線中斷時下一輪重新連線，Ctrl-C 結束。
+func runWatch(ctx context.Context, live *liveSession, args []string, filters filterRules, maxDepth int, weights weightRules, opts getOptions) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := flags.Duration("i", 30*time.Second, "檢查遠端目錄的間隔")
+	match := flags.String("match", "*", "只下載檔名符合此樣式的檔案，例如 '*.csv'")
+	jobs := flags.Int("j", opts.concurrency, "同時下載的檔案數")
+	flags.Parse(args)
+	if flags.NArg() < 1 || flags.NArg() > 2 || *jobs < 1 || *interval <= 0 {
+		return errors.New("用法: data_cli <ip:port> watch [-i interval] [-match pattern] [-j N] <remotedir> [localdir]")
+	}
+	if _, err := path.Match(*match, ""); err != nil {
+		return fmt.Errorf("無效的樣式 %q: %v", *match, err)
+	}
+	dir := strings.Trim(path.Clean("/"+flags.Arg(0)), "/")
+	root := "."
+	if flags.NArg() == 2 {
+		root = flags.Arg(1)
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	st, err := loadWatchState(live.server, dir, abs)
+	if err != nil {
+		return err
+	}
+	// 變更的檔案一律覆寫，與 sync 相同
+	opts.clobber = clobberForce
+	con.Printf("監看 %s:/%s -> %s，每 %v 檢查一次（已記錄 %d 個檔案，Ctrl-C 結束）\n", live.server, dir, root, *interval, len(st.Files))
+
+	for {
+		if err := watchOnce(ctx, live, st, dir, root, *match, filters, maxDepth, *jobs, weights, opts); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			slog.Error("watch 本輪失敗，下一輪重試", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// watchOnce 列出遠端目錄一次並下載新的或已變更的檔案，成功的檔案寫入狀態檔。
+func watchOnce(ctx context.Context, live *liveSession, st *watchState, dir, root, match string, filters filterRules, maxDepth, jobs int, weights weightRules, opts getOptions) error {
+	session, err := live.get(ctx)
+	if err != nil {
+		return err
+	}
+	var candidates []string
+	entries := make(map[string]client.Entry)
+	err = walkRemote(ctx, session, dir, filters, maxDepth, func(rel string, e client.Entry) error {
+		if ok, _ := path.Match(match, path.Base(rel)); !ok {
+			return nil
+		}
+		if f, ok := st.Files[rel]; ok && f.matches(e) {
+			return nil
+		}
+		candidates = append(candidates, rel)
+		entries[rel] = e
+		return nil
+	})
+	if err != nil || len(candidates) == 0 {
+		return err
+	}
+
+	// 已下載檔案的雜湊 -> 本機路徑，內容相同的新檔案直接複製；只有大小相同時才需要向伺服器查詢雜湊
+	byHash := make(map[string]string)
+	sizes := make(map[int64]bool)
+	for rel, f := range st.Files {
+		if f.Hash != "" {
+			byHash[f.Hash] = filepath.Join(root, filepath.FromSlash(rel))
+			sizes[f.Size] = true
+		}
+	}
+	var files []remoteFile
+	for _, rel := range candidates {
+		e := entries[rel]
+		local := filepath.Join(root, filepath.FromSlash(rel))
+		if changed, err := syncChanged(local, e, false); err == nil && !changed {
+			// 本機已經有相同的檔案（例如狀態檔遺失），只補記錄
+			st.record(rel, local, e)
+			delete(entries, rel)
+			continue
+		}
+		if e.Hash == "" && sizes[e.Size] {
+			if se, err := client.New(session).Stat(ctx, path.Join(dir, rel)); err == nil {
+				e.Hash = se.Hash
+			}
+		}
+		if src, ok := byHash[e.Hash]; ok && e.Hash != "" {
+			if err := copyLocal(src, local, e.Mtime); err == nil {
+				con.Printf("%s 與 %s 內容相同，已從本機複製\n", rel, src)
+				st.record(rel, local, e)
+				delete(entries, rel)
+				continue
+			}
+		}
+		files = append(files, remoteFile{remote: path.Join(dir, rel), local: local, size: e.Size, mtime: e.Mtime})
+	}
+	if len(files) > 0 {
+		err = getFiles(ctx, session, dir, files, jobs, weights, opts)
+		// 部分檔案失敗時仍記錄已完成的檔案，失敗的在下一輪重試
+		for _, f := range files {
+			rel := strings.TrimPrefix(strings.TrimPrefix(f.remote, dir), "/")
+			if info, serr := os.Stat(f.local); serr == nil && info.Size() == f.size {
+				st.record(rel, f.local, entries[rel])
+			}
+		}
+	}
+	if serr := st.save(); serr != nil && err == nil {
+		err = serr
+	}
+	return err
+}
+
+// record 記錄 rel 已下載到 local。伺服器的列表沒有雜湊時以本機檔案計算，供之後比對重複的內容。
+func (st *watchState) record(rel, local string, e client.Entry) {
+	hash := e.Hash
+	if hash == "" {
+		if sum, err := fileSHA256(local); err == nil {
+			hash = "sha256:" + sum
+		}
+	}
+	st.Files[rel] = watchedFile{Size: e.Size, Mtime: e.Mtime, Hash: hash}
+}
+
+// copyLocal 把本機檔案 src 複製到 dst，並把修改時間設為 mtime。
+func copyLocal(src, dst string, mtime time.Time) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp := dst + ".partial"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if !mtime.IsZero() {
+		os.Chtimes(tmp, mtime, mtime)
+	}
+	return os.Rename(tmp, dst)
+}
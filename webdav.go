@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"golang.org/x/net/webdav"
+
+	"go-client/client"
+)
+
+// runWebDAV 在本機 addr 上以唯讀 WebDAV 提供遠端目錄樹，讓檔案管理員或系統內建的
+// WebDAV 掛載不需額外的核心模組就能存取伺服器。
+func runWebDAV(session *quic.Conn, server, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	handler := &webdav.Handler{
+		FileSystem: davFS{session: session},
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				con.Printf("%s %s: %v\n", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	con.Printf("以 WebDAV 在 http://%s/ 提供 %s（唯讀），按 Ctrl-C 結束\n", ln.Addr(), server)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		select {
+		case <-sig:
+		case <-session.Context().Done():
+			con.Printf("與 %s 的連線中斷\n", server)
+		}
+		srv.Close()
+	}()
+	if err := srv.Serve(ln); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// davFS 把遠端目錄樹轉成唯讀的 webdav.FileSystem；所有修改操作都回傳 os.ErrPermission。
+type davFS struct {
+	session *quic.Conn
+}
+
+func (d davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return os.ErrPermission
+}
+
+func (d davFS) RemoveAll(ctx context.Context, name string) error {
+	return os.ErrPermission
+}
+
+func (d davFS) Rename(ctx context.Context, oldName, newName string) error {
+	return os.ErrPermission
+}
+
+func (d davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	e, err := statRemote(ctx, d.session, name)
+	if err != nil {
+		return nil, err
+	}
+	return entryInfo{e}, nil
+}
+
+func (d davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, os.ErrPermission
+	}
+	e, err := statRemote(ctx, d.session, name)
+	if err != nil {
+		return nil, err
+	}
+	p := strings.TrimPrefix(path.Clean(name), "/")
+	return &davFile{
+		ctx:   ctx,
+		entry: e,
+		dir:   p,
+		rr:    remoteReader{session: d.session, path: p, size: e.Size},
+	}, nil
+}
+
+// davFile 是 davFS 開啟的檔案或目錄。
+type davFile struct {
+	ctx   context.Context
+	entry client.Entry
+	dir   string
+	rr    remoteReader
+	off   int64
+
+	listed []fs.FileInfo // Readdir 已取得但尚未回傳的項目
+	done   bool
+}
+
+func (f *davFile) Read(p []byte) (int, error) {
+	if f.entry.Type == "dir" {
+		return 0, errors.New("是目錄")
+	}
+	n, err := f.rr.ReadAt(f.ctx, p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+func (f *davFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.entry.Size
+	}
+	if offset < 0 {
+		return 0, errors.New("無效的 offset")
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *davFile) Readdir(count int) ([]fs.FileInfo, error) {
+	if !f.done {
+		err := client.New(f.rr.session).ListFunc(f.ctx, f.dir, true, func(e client.Entry) error {
+			f.listed = append(f.listed, entryInfo{e})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		f.done = true
+	}
+	if count <= 0 {
+		all := f.listed
+		f.listed = nil
+		return all, nil
+	}
+	if len(f.listed) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(f.listed))
+	out := f.listed[:n]
+	f.listed = f.listed[n:]
+	return out, nil
+}
+
+func (f *davFile) Stat() (fs.FileInfo, error) {
+	return entryInfo{f.entry}, nil
+}
+
+func (f *davFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (f *davFile) Close() error {
+	return f.rr.Close()
+}
+
+// entryInfo 讓 client.Entry 滿足 fs.FileInfo。
+type entryInfo struct {
+	e client.Entry
+}
+
+func (i entryInfo) Name() string       { return path.Base(i.e.Name) }
+func (i entryInfo) Size() int64        { return i.e.Size }
+func (i entryInfo) ModTime() time.Time { return i.e.Mtime }
+func (i entryInfo) IsDir() bool        { return i.e.Type == "dir" }
+func (i entryInfo) Sys() any           { return nil }
+
+func (i entryInfo) Mode() fs.FileMode {
+	if i.IsDir() {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
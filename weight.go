@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// limiterGroup 把總速度上限依權重分配給同時進行的傳輸，成員加入或離開時重新分配，
+// 因此先完成的檔案讓出的頻寬會由其餘傳輸依比例分享。
+type limiterGroup struct {
+	mu       sync.Mutex
+	total    int64 // bytes/sec，0 表示不限速
+	burst    int64
+	interval time.Duration
+	members  map[*tokenBucket]float64
+	meter    *usageMeter // 成員讀取的量記到這裡，見 --quota
+
+	start time.Time
+	read  int64 // 已離開的成員讀取的位元組數，用來估計目前的速度
+}
+
+// newLimiterGroup 建立總速度上限為 total 的群組，burst 與 interval 套用到每個成員的 pacing。
+// 群組會登記給 SIGUSR1/SIGUSR2，收到訊號時調降或調升總上限。
+func newLimiterGroup(total, burst int64, interval time.Duration) *limiterGroup {
+	g := &limiterGroup{total: total, burst: burst, interval: interval, members: make(map[*tokenBucket]float64), start: time.Now()}
+	watchLimitSignals(g)
+	return g
+}
+
+func (g *limiterGroup) Total() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.total
+}
+
+// SetTotal 在傳輸進行中調整總速度上限，0 表示不限速。
+func (g *limiterGroup) SetTotal(total int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.total = total
+	g.rebalance()
+}
+
+// Nudge 將總速度上限乘上 factor；原本不限速時以目前為止的平均速度為基準，
+// 因此只能調降。回傳新的上限，0 表示維持不限速。
+func (g *limiterGroup) Nudge(factor float64) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	total := g.total
+	if total <= 0 {
+		if factor > 1 {
+			return 0
+		}
+		read := g.read
+		for b := range g.members {
+			read += b.read.Load()
+		}
+		if read == 0 {
+			// 還沒有傳輸經過這個群組，無從估計
+			return 0
+		}
+		total = clampInt64(float64(read) / time.Since(g.start).Seconds())
+	}
+	g.total = max(clampInt64(float64(total)*factor), 1)
+	g.rebalance()
+	return g.total
+}
+
+func (g *limiterGroup) Join(b *tokenBucket, weight float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b.SetBurst(g.burst, g.interval)
+	b.meter = g.meter
+	g.members[b] = weight
+	g.rebalance()
+}
+
+func (g *limiterGroup) Leave(b *tokenBucket) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.read += b.read.Load()
+	delete(g.members, b)
+	g.rebalance()
+}
+
+func (g *limiterGroup) rebalance() {
+	var sum float64
+	for _, w := range g.members {
+		sum += w
+	}
+	for b, w := range g.members {
+		if g.total <= 0 {
+			b.SetLimit(0)
+			continue
+		}
+		b.SetLimit(max(clampInt64(float64(g.total)*w/sum), 1))
+	}
+}
+
+// weightRules 是 --weight pattern=N 的設定，第一條符合檔名的規則決定權重，預設為 1。
+type weightRules []struct {
+	re     *regexp.Regexp
+	weight float64
+}
+
+func (rules *weightRules) add(s string) error {
+	pattern, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("無效的 --weight %q，格式為 pattern=N", s)
+	}
+	w, err := strconv.ParseFloat(value, 64)
+	// !(w > 0) 同時排除 NaN；無限大的權重會讓其他傳輸分不到頻寬
+	if err != nil || !(w > 0) || math.IsInf(w, 0) {
+		return fmt.Errorf("無效的權重 %q", value)
+	}
+	re, err := regexp.Compile("(^|/)" + globToRegexp(pattern) + "$")
+	if err != nil {
+		return fmt.Errorf("無效的樣式 %q: %v", pattern, err)
+	}
+	*rules = append(*rules, struct {
+		re     *regexp.Regexp
+		weight float64
+	}{re, w})
+	return nil
+}
+
+func (rules weightRules) For(name string) float64 {
+	for _, r := range rules {
+		if r.re.MatchString(name) {
+			return r.weight
+		}
+	}
+	return 1
+}